@@ -4,12 +4,14 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -43,6 +45,8 @@ func main() {
 		runScan(os.Args[2:])
 	case "stats":
 		runStatsCmd(os.Args[2:])
+	case "dupes":
+		runDupesCmd(os.Args[2:])
 	case "config":
 		runConfigCmd(os.Args[2:])
 	case "version":
@@ -68,15 +72,18 @@ Usage:
   truespec scan [flags] --stdin
   truespec scan [flags] --pipe
   truespec stats [--json] [--reset]
+  truespec dupes [--json] <report> [report...]
   truespec config [--show] [--json] [--reset]
   truespec version
 
-Inputs can be info hashes, magnet links, .torrent files, or directories
+Inputs can be info hashes (v1 hex or base32, v2 hex), magnet links
+(urn:btih or urn:btmh), .torrent files (v1, v2 or hybrid), or directories
 containing .torrent files.
 
 Commands:
   scan     Partially download torrents and extract verified media metadata
   stats    Display accumulated scan statistics
+  dupes    Find re-uploads of the same encode across scan reports
   config   Configure TrueSpec features (interactive wizard)
   version  Show version
 
@@ -90,6 +97,7 @@ Examples:
   cat hashes.txt | truespec scan --pipe
   truespec stats
   truespec stats --json
+  truespec dupes results_*.json
   truespec config
   truespec config --show
 
@@ -190,6 +198,9 @@ func runConfigWizard() {
 	// If whisper enabled, download it
 	if cfg.WhisperEnabled {
 		fmt.Fprintln(os.Stderr, "")
+		dlCfg := internal.DefaultConfig()
+		cfg.ApplyToConfig(&dlCfg)
+		setupProxy(&dlCfg)
 		whisperPath, modelPath, err := internal.DownloadWhisper()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "\nWarning: Could not install whisper: %v\n", err)
@@ -360,6 +371,37 @@ func runStatsCmd(args []string) {
 	fmt.Print(internal.FormatStats(s))
 }
 
+func runDupesCmd(args []string) {
+	fs := flag.NewFlagSet("dupes", flag.ExitOnError)
+	var jsonOutput bool
+	fs.BoolVar(&jsonOutput, "json", false, "Output clusters as JSON")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: truespec dupes [--json] <report> [report...]")
+		os.Exit(1)
+	}
+
+	releases, err := internal.LoadDupeReleases(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading reports: %v\n", err)
+		os.Exit(1)
+	}
+	clusters := internal.FindDuplicates(releases)
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(clusters); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding clusters: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Print(internal.FormatDupes(clusters, len(releases)))
+}
+
 // ═══════════════════════════════════════════════════════════════════
 // INTERACTIVE MODE
 // ═══════════════════════════════════════════════════════════════════
@@ -569,12 +611,45 @@ func runScan(args []string) {
 
 	fs.StringVar(&cfg.FFprobePath, "ffprobe", cfg.FFprobePath, "Path to ffprobe binary (auto-detect if empty)")
 	fs.StringVar(&cfg.TempDir, "temp-dir", cfg.TempDir, "Temporary directory for downloads")
+	fs.IntVar(&cfg.MemoryCapMB, "memory-cap", cfg.MemoryCapMB, "Keep downloaded pieces in memory, capped at this many MB per worker (0 = write to temp dir)")
+	fs.IntVar(&cfg.DownloadRateKB, "download-rate", cfg.DownloadRateKB, "Global download rate limit in KB/s, split between workers (0 = unlimited)")
+	fs.IntVar(&cfg.UploadRateKB, "upload-rate", cfg.UploadRateKB, "Global upload rate limit in KB/s, split between workers (0 = unlimited)")
+	fs.IntVar(&cfg.MaxConns, "max-conns", cfg.MaxConns, "Max established peer connections per worker (0 = client default)")
+	fs.IntVar(&cfg.MaxHalfOpen, "max-half-open", cfg.MaxHalfOpen, "Max half-open peer connections per worker (0 = client default)")
+	fs.IntVar(&cfg.DiskBudgetMB, "disk-budget", cfg.DiskBudgetMB, "Temp-disk space in MB shared by all workers (0 = unlimited)")
+	fs.StringVar(&cfg.Proxy, "proxy", cfg.Proxy, "Proxy for all traffic: socks5://[user:pass@]host:port, http:// or https://")
+	fs.BoolVar(&cfg.ProxyFallback, "proxy-fallback", cfg.ProxyFallback, "Scan without the proxy if it is unreachable (default: refuse to scan)")
+	fs.StringVar(&cfg.BindAddress, "bind", cfg.BindAddress, "IP address or interface name to listen and connect from")
+	listenPorts := ""
+	if cfg.PortMin > 0 {
+		listenPorts = fmt.Sprintf("%d-%d", cfg.PortMin, cfg.PortMax)
+	}
+	fs.StringVar(&listenPorts, "listen-ports", listenPorts, "Listen port or range shared by workers, e.g. 6881-6889 (default: random port)")
+	fs.BoolVar(&cfg.DisableIPv6, "disable-ipv6", cfg.DisableIPv6, "Do not use IPv6 for peers")
+	fs.BoolVar(&cfg.DisableUTP, "disable-utp", cfg.DisableUTP, "Do not use uTP (TCP peers only)")
+	fs.BoolVar(&cfg.DisableTCP, "disable-tcp", cfg.DisableTCP, "Do not use TCP (uTP peers only)")
+	fs.StringVar(&cfg.Encryption, "encryption", cfg.Encryption, "Protocol encryption: prefer, require or off (default: prefer)")
+	fs.Func("tracker", "Extra tracker announce URL (repeatable)", func(s string) error {
+		cfg.ExtraTrackers = append(cfg.ExtraTrackers, s)
+		return nil
+	})
+	fs.StringVar(&cfg.TrackersFile, "trackers-file", cfg.TrackersFile, "Read extra tracker URLs from file (one per line)")
+	fs.BoolVar(&cfg.ReplaceTrackers, "replace-trackers", cfg.ReplaceTrackers, "Announce only to --tracker/--trackers-file, not the default public trackers")
+	fs.Func("dht-node", "DHT bootstrap node host:port (repeatable; default: public routers)", func(s string) error {
+		cfg.DHTNodes = append(cfg.DHTNodes, s)
+		return nil
+	})
+	fs.StringVar(&cfg.DHTStateFile, "dht-state", cfg.DHTStateFile, "File to persist the DHT routing table between runs (empty = don't persist)")
+	fs.StringVar(&cfg.Blocklist, "blocklist", cfg.Blocklist, "Peer blocklist file in P2P or eMule DAT format, optionally gzipped")
 	var verbose bool
 	fs.BoolVar(&verbose, "verbose", false, "Print all logs to stderr (overrides config verbose level)")
 	fs.BoolVar(&verbose, "v", false, "Print all logs to stderr (shorthand)")
 	fs.StringVar(&cfg.OutputFile, "output", "", "Output JSON file path (default: results_<timestamp>.json)")
 	fs.StringVar(&cfg.OutputFile, "o", "", "Output JSON file path (default: results_<timestamp>.json)")
 	fs.StringVar(&cfg.StatsFile, "stats-file", cfg.StatsFile, "Path to stats file")
+	fs.IntVar(&cfg.Thumbnails, "thumbnails", cfg.Thumbnails, "Keyframes per contact sheet (0 = disabled; needs ffmpeg)")
+	fs.StringVar(&cfg.ThumbnailFormat, "thumbnail-format", cfg.ThumbnailFormat, "Contact sheet format: jpg or webp")
+	fs.StringVar(&cfg.ThumbnailDir, "thumbnail-dir", "", "Directory for contact sheets (default: <output>_thumbs; base64 in pipe mode)")
 
 	var fromFile string
 	var fromStdin bool
@@ -595,6 +670,15 @@ func runScan(args []string) {
 		cfg.StatsFile = ""
 	}
 
+	if err := applyNetworkFlags(&cfg, listenPorts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !internal.ValidThumbnailFormat(cfg.ThumbnailFormat) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --thumbnail-format %q (want jpg or webp)\n", cfg.ThumbnailFormat)
+		os.Exit(1)
+	}
+
 	if verbose {
 		cfg.VerboseLevel = internal.VerboseVerbose
 	}
@@ -673,20 +757,35 @@ func executeScan(cfg internal.Config, hashes []string) {
 	if cfg.OutputFile == "" {
 		cfg.OutputFile = fmt.Sprintf("results_%s.json", time.Now().Format("2006-01-02_150405"))
 	}
+	// Contact sheets go next to the report
+	if cfg.Thumbnails > 0 && cfg.ThumbnailDir == "" {
+		cfg.ThumbnailDir = strings.TrimSuffix(cfg.OutputFile, filepath.Ext(cfg.OutputFile)) + "_thumbs"
+	}
 
 	logCloser := setupLogging(&cfg)
 	if logCloser != nil {
 		defer logCloser.Close()
 	}
 
-	// Validate hashes (should be 40-char hex strings)
+	// Validate hashes (40-char v1 or 64-char v2 hex strings)
 	for i, h := range hashes {
-		if len(h) != 40 {
-			fmt.Fprintf(os.Stderr, "Warning: hash #%d (%q) is not 40 characters, may be invalid\n", i+1, h)
+		if !internal.ValidInfoHash(h) {
+			fmt.Fprintf(os.Stderr, "Warning: hash #%d (%q) is not a 40-char v1 or 64-char v2 hex hash, may be invalid\n", i+1, h)
 		}
 	}
 
 	// Resolve ffprobe early so we fail fast
+	setupProxy(&cfg)
+	if err := cfg.ResolvePeerDiscovery(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	blocklist, err := internal.LoadBlocklist(cfg.Blocklist)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	ffprobePath, err := internal.ResolveFFprobe(cfg.FFprobePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -700,7 +799,21 @@ func executeScan(cfg internal.Config, hashes []string) {
 	log.Printf("  max timeout: %s", cfg.MaxTimeout)
 	log.Printf("  ffprobe: %s", cfg.FFprobePath)
 	log.Printf("  temp dir: %s", cfg.TempDir)
+	if cfg.MemoryCapMB > 0 {
+		log.Printf("  piece storage: memory (%dMB per worker)", cfg.MemoryCapMB)
+	}
+	logResourceLimits(cfg)
+	if cfg.Proxy != "" {
+		log.Printf("  proxy: %s (TCP peers only, no DHT/uTP)", internal.RedactProxy(cfg.Proxy))
+	}
+	logNetwork(cfg)
+	if blocklist != nil {
+		log.Printf("  blocklist: %s (%d ranges, %d lines skipped)", cfg.Blocklist, blocklist.NumRanges(), blocklist.Skipped)
+	}
 	log.Printf("  output: %s", cfg.OutputFile)
+	if cfg.Thumbnails > 0 {
+		log.Printf("  contact sheets: %d frame(s), %s → %s", cfg.Thumbnails, cfg.ThumbnailFormat, cfg.ThumbnailDir)
+	}
 
 	// Startup cleanup: remove leftover files from previous runs (crashes, OOM kills, etc.)
 	// Partial downloads are never resumable, so there's zero value in keeping them.
@@ -799,6 +912,17 @@ func executePipe(cfg internal.Config) {
 	}
 
 	// Resolve ffprobe early so we fail fast
+	setupProxy(&cfg)
+	if err := cfg.ResolvePeerDiscovery(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	blocklist, err := internal.LoadBlocklist(cfg.Blocklist)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	ffprobePath, err := internal.ResolveFFprobe(cfg.FFprobePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -811,6 +935,17 @@ func executePipe(cfg internal.Config) {
 	log.Printf("  max timeout: %s", cfg.MaxTimeout)
 	log.Printf("  ffprobe: %s", cfg.FFprobePath)
 	log.Printf("  temp dir: %s", cfg.TempDir)
+	if cfg.MemoryCapMB > 0 {
+		log.Printf("  piece storage: memory (%dMB per worker)", cfg.MemoryCapMB)
+	}
+	logResourceLimits(cfg)
+	if cfg.Proxy != "" {
+		log.Printf("  proxy: %s (TCP peers only, no DHT/uTP)", internal.RedactProxy(cfg.Proxy))
+	}
+	logNetwork(cfg)
+	if blocklist != nil {
+		log.Printf("  blocklist: %s (%d ranges, %d lines skipped)", cfg.Blocklist, blocklist.NumRanges(), blocklist.Skipped)
+	}
 
 	// Startup cleanup
 	cleanTempDir(cfg.TempDir)
@@ -1077,3 +1212,91 @@ func runWorker() {
 		os.Exit(1)
 	}
 }
+
+// logResourceLimits logs the bandwidth, connection and disk limits in effect.
+func logResourceLimits(cfg internal.Config) {
+	if cfg.DownloadRateKB > 0 || cfg.UploadRateKB > 0 {
+		log.Printf("  rate limits: down %d KB/s, up %d KB/s (0 = unlimited)", cfg.DownloadRateKB, cfg.UploadRateKB)
+	}
+	if cfg.MaxConns > 0 || cfg.MaxHalfOpen > 0 {
+		log.Printf("  peer connections per worker: %d established, %d half-open (0 = default)", cfg.MaxConns, cfg.MaxHalfOpen)
+	}
+	if cfg.DiskBudgetMB > 0 && cfg.MemoryCapMB <= 0 {
+		log.Printf("  temp disk budget: %dMB", cfg.DiskBudgetMB)
+	}
+}
+
+// setupProxy checks the configured proxy and routes all outbound traffic
+// through it. When the proxy is unreachable it exits rather than let
+// traffic leak past it, unless ProxyFallback allows going direct.
+func setupProxy(cfg *internal.Config) {
+	if cfg.Proxy == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	err := internal.CheckProxy(ctx, cfg.Proxy)
+	cancel()
+	if errors.Is(err, internal.ErrProxyUnavailable) {
+		if cfg.ProxyFallback {
+			log.Printf("Warning: %v — scanning without proxy (--proxy-fallback)", err)
+			cfg.Proxy = ""
+			return
+		}
+		err = fmt.Errorf("%w (use --proxy-fallback to scan without it)", err)
+	}
+	if err == nil {
+		err = internal.SetProxy(cfg.Proxy)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// applyNetworkFlags validates the network binding settings and stores the
+// parsed listen port range, which must hold a port for every concurrent worker.
+func applyNetworkFlags(cfg *internal.Config, listenPorts string) error {
+	lo, hi, err := internal.ParsePortRange(listenPorts)
+	if err != nil {
+		return err
+	}
+	cfg.PortMin, cfg.PortMax = lo, hi
+	if lo > 0 && hi-lo+1 < cfg.Concurrency {
+		return fmt.Errorf("--listen-ports %s has %d port(s) but --concurrency is %d: each worker needs its own port", listenPorts, hi-lo+1, cfg.Concurrency)
+	}
+	if !internal.ValidEncryption(cfg.Encryption) {
+		return fmt.Errorf("invalid --encryption %q (want prefer, require or off)", cfg.Encryption)
+	}
+	if cfg.DisableUTP && cfg.DisableTCP {
+		return errors.New("--disable-utp and --disable-tcp cannot be combined")
+	}
+	if cfg.Proxy != "" && cfg.DisableTCP {
+		return errors.New("--disable-tcp cannot be used with --proxy: peers are only reached over TCP through the proxy")
+	}
+	if _, err := internal.ResolveBindAddress(cfg.BindAddress, cfg.DisableIPv6); err != nil {
+		return err
+	}
+	return nil
+}
+
+// logNetwork logs the network binding settings that differ from the defaults.
+func logNetwork(cfg internal.Config) {
+	if cfg.BindAddress != "" {
+		log.Printf("  bind: %s", cfg.BindAddress)
+	}
+	if cfg.PortMin > 0 {
+		log.Printf("  listen ports: %d-%d", cfg.PortMin, cfg.PortMax)
+	}
+	if cfg.DisableIPv6 || cfg.DisableUTP || cfg.DisableTCP {
+		log.Printf("  disabled: ipv6=%t utp=%t tcp=%t", cfg.DisableIPv6, cfg.DisableUTP, cfg.DisableTCP)
+	}
+	if cfg.Encryption != "" {
+		log.Printf("  encryption: %s", cfg.Encryption)
+	}
+	if cfg.ReplaceTrackers || len(cfg.ExtraTrackers) > 0 || cfg.TrackersFile != "" {
+		log.Printf("  trackers: %d", len(cfg.Trackers))
+	}
+	if len(cfg.DHTNodes) > 0 {
+		log.Printf("  DHT bootstrap: %s", strings.Join(cfg.DHTNodes, ", "))
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"os/exec"
 	"strings"
 	"testing"
+
+	"github.com/torrentclaw/truespec/internal"
 )
 
 func TestEnsureClassicFileIO_ClassicReturnsImmediately(t *testing.T) {
@@ -68,3 +70,30 @@ func filterEnv(env []string, key string) []string {
 	}
 	return filtered
 }
+
+func TestApplyNetworkFlags_ProxyWithoutTCP(t *testing.T) {
+	cfg := internal.Config{Proxy: "socks5://127.0.0.1:1080", DisableTCP: true}
+	if err := applyNetworkFlags(&cfg, ""); err == nil {
+		t.Error("expected error for --proxy with --disable-tcp")
+	}
+	cfg.DisableTCP = false
+	if err := applyNetworkFlags(&cfg, ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestApplyNetworkFlags_PortRangeCoversConcurrency(t *testing.T) {
+	cfg := internal.Config{Concurrency: 4}
+	if err := applyNetworkFlags(&cfg, "6881-6883"); err == nil {
+		t.Error("expected error for 3 ports with concurrency 4")
+	}
+	if err := applyNetworkFlags(&cfg, "6881-6884"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if cfg.PortMin != 6881 || cfg.PortMax != 6884 {
+		t.Errorf("ports = %d-%d, want 6881-6884", cfg.PortMin, cfg.PortMax)
+	}
+	if err := applyNetworkFlags(&cfg, ""); err != nil {
+		t.Errorf("random port: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Audio track roles. Only RoleMain tracks count toward ScanResult.Languages.
+const (
+	RoleMain            = "main"             // main program audio (original or dub)
+	RoleCommentary      = "commentary"       // director/cast commentary
+	RoleDescription     = "description"      // audio description for the visually impaired
+	RoleHearingImpaired = "hearing_impaired" // dialogue-enhanced mix for the hearing impaired
+	RoleKaraoke         = "karaoke"          // sing-along / karaoke track
+	RoleMusic           = "music"            // isolated score, music & effects
+)
+
+// roleKeywords maps title keywords (lowercase) to audio roles.
+// Checked in order: the first matching role wins.
+var roleKeywords = []struct {
+	role     string
+	keywords []string
+}{
+	{RoleCommentary, []string{
+		"commentary", "commentaire", "comentario", "comentário", "kommentar", "commento",
+	}},
+	{RoleDescription, []string{
+		"audio description", "audio-description", "audiodescription", "audiodescripción",
+		"audiodescrição", "audiodeskription", "descriptive", "described video",
+		"visually impaired", "visual impaired",
+	}},
+	{RoleKaraoke, []string{"karaoke", "sing-along", "singalong", "sing along"}},
+	{RoleMusic, []string{
+		"isolated score", "score only", "music only", "music & effects", "music and effects",
+		"m&e", "instrumental",
+	}},
+}
+
+// adTokenRe matches a standalone "AD" token in a title (e.g. "English AD", "[AD]").
+var adTokenRe = regexp.MustCompile(`(^|[^a-z0-9])ad([^a-z0-9]|$)`)
+
+// ClassifyAudioRole determines the role of an audio track from its ffprobe
+// dispositions and title. Dispositions take precedence over title keywords,
+// and explicit dub/original flags force the track to RoleMain.
+func ClassifyAudioRole(disposition map[string]int, title string) string {
+	switch {
+	case disposition["comment"] == 1:
+		return RoleCommentary
+	case disposition["visual_impaired"] == 1:
+		return RoleDescription
+	case disposition["hearing_impaired"] == 1:
+		return RoleHearingImpaired
+	case disposition["karaoke"] == 1:
+		return RoleKaraoke
+	case disposition["dub"] == 1, disposition["original"] == 1:
+		return RoleMain
+	}
+
+	lower := strings.ToLower(title)
+	if lower == "" {
+		return RoleMain
+	}
+	for _, rk := range roleKeywords {
+		for _, kw := range rk.keywords {
+			if strings.Contains(lower, kw) {
+				return rk.role
+			}
+		}
+	}
+	if adTokenRe.MatchString(lower) {
+		return RoleDescription
+	}
+	return RoleMain
+}
+
+// IsMainProgram reports whether the track carries main program audio.
+// Tracks without a role (e.g. results produced before role classification)
+// are treated as main program audio.
+func (t AudioTrack) IsMainProgram() bool {
+	return t.Role == "" || t.Role == RoleMain
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyAudioRole_Dispositions(t *testing.T) {
+	tests := []struct {
+		name        string
+		disposition map[string]int
+		title       string
+		want        string
+	}{
+		{"no disposition", nil, "", RoleMain},
+		{"comment", map[string]int{"comment": 1}, "", RoleCommentary},
+		{"visual impaired", map[string]int{"visual_impaired": 1}, "English", RoleDescription},
+		{"hearing impaired", map[string]int{"hearing_impaired": 1}, "", RoleHearingImpaired},
+		{"karaoke", map[string]int{"karaoke": 1}, "", RoleKaraoke},
+		{"dub overrides title", map[string]int{"dub": 1}, "Commentary", RoleMain},
+		{"original", map[string]int{"original": 1, "default": 1}, "", RoleMain},
+		{"comment wins over dub", map[string]int{"comment": 1, "dub": 1}, "", RoleCommentary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyAudioRole(tt.disposition, tt.title); got != tt.want {
+				t.Errorf("ClassifyAudioRole(%v, %q) = %q, want %q", tt.disposition, tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyAudioRole_Titles(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"English", RoleMain},
+		{"Castellano DD 5.1", RoleMain},
+		{"Director's Commentary", RoleCommentary},
+		{"Commentaire du réalisateur", RoleCommentary},
+		{"Comentario del director", RoleCommentary},
+		{"Audio Description", RoleDescription},
+		{"English [AD]", RoleDescription},
+		{"Descriptive Audio", RoleDescription},
+		{"Karaoke", RoleKaraoke},
+		{"Isolated Score", RoleMusic},
+		{"M&E", RoleMusic},
+		{"Adventure Mix", RoleMain},      // "ad" inside a word is not a token
+		{"Director's Cut", RoleMain},     // no commentary keyword
+		{"Dolby Atmos (Road)", RoleMain}, // "ad" inside a word
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := ClassifyAudioRole(nil, tt.title); got != tt.want {
+				t.Errorf("ClassifyAudioRole(nil, %q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioTrack_IsMainProgram(t *testing.T) {
+	if !(AudioTrack{}).IsMainProgram() {
+		t.Error("track without role should be main program")
+	}
+	if !(AudioTrack{Role: RoleMain}).IsMainProgram() {
+		t.Error("main track should be main program")
+	}
+	if (AudioTrack{Role: RoleCommentary}).IsMainProgram() {
+		t.Error("commentary track should not be main program")
+	}
+}
+
+func TestComputeLanguages_IgnoresNonMainTracks(t *testing.T) {
+	audio := []AudioTrack{
+		{Lang: "en", Role: RoleMain},
+		{Lang: "en", Role: RoleCommentary},
+		{Lang: "es", Role: RoleCommentary},
+		{Lang: "fr", Role: RoleDescription},
+		{Lang: "de"}, // unclassified counts as main
+	}
+
+	got := ComputeLanguages(nil, audio)
+	want := []string{"de", "en"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputeLanguages = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/torrent/iplist"
+)
+
+// datAllowLevel is the eMule DAT access level from which a range is allowed
+// rather than blocked.
+const datAllowLevel = 128
+
+// Blocklist is an IPv4 peer blocklist. It implements iplist.Ranger and
+// records the distinct peers it blocked while each tracked scan runs. The
+// counts are client-wide: the client and its DHT server check the list
+// before a peer is tied to a torrent, so a block can't be attributed to one.
+type Blocklist struct {
+	ranges  []iplist.Range // sorted, non-overlapping
+	Skipped int            // malformed lines ignored while loading
+
+	mu    sync.Mutex
+	scans map[string]map[string]struct{} // peers blocked per tracked scan
+}
+
+// LoadBlocklist reads a PeerGuardian P2P ("description:first-last") or eMule
+// DAT ("first - last , level , description") blocklist, optionally gzipped.
+// The format is detected per line; # and // comments are skipped, as are DAT
+// ranges with an allow level (128 or more). Returns nil for an empty path.
+func LoadBlocklist(path string) (*Blocklist, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open blocklist: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var src io.Reader = r
+	if magic, _ := r.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("read blocklist: %w", err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	b, err := parseBlocklist(src)
+	if err != nil {
+		return nil, fmt.Errorf("read blocklist %s: %w", path, err)
+	}
+	if b.NumRanges() == 0 {
+		return nil, fmt.Errorf("blocklist %s has no IPv4 ranges", path)
+	}
+	return b, nil
+}
+
+// parseBlocklist parses blocklist lines into a sorted list of merged ranges.
+func parseBlocklist(r io.Reader) (*Blocklist, error) {
+	b := &Blocklist{scans: make(map[string]map[string]struct{})}
+	var ranges []iplist.Range
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		rng, block, ok := parseBlocklistLine(line)
+		if !ok {
+			b.Skipped++
+			continue
+		}
+		if block {
+			ranges = append(ranges, rng)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	b.ranges = mergeIPRanges(ranges)
+	return b, nil
+}
+
+// parseBlocklistLine parses a DAT line (it has commas) or a P2P line. block
+// is false for DAT ranges whose level allows them.
+func parseBlocklistLine(line string) (r iplist.Range, block, ok bool) {
+	var span string
+	block = true
+	if strings.Contains(line, ",") {
+		fields := strings.SplitN(line, ",", 3)
+		span = fields[0]
+		if len(fields) > 1 {
+			level, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+			if err != nil {
+				return r, false, false
+			}
+			block = level < datAllowLevel
+		}
+		if len(fields) > 2 {
+			r.Description = strings.TrimSpace(fields[2])
+		}
+	} else {
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			return r, false, false
+		}
+		r.Description, span = line[:colon], line[colon+1:]
+	}
+
+	first, last, found := strings.Cut(span, "-")
+	if !found {
+		return r, false, false
+	}
+	r.First, r.Last = parseIPv4(first), parseIPv4(last)
+	if r.First == nil || r.Last == nil || bytes.Compare(r.First, r.Last) > 0 {
+		return r, false, false
+	}
+	return r, block, true
+}
+
+// parseIPv4 parses a dotted IPv4 address, allowing the zero-padded octets
+// of DAT lists ("001.002.004.000"), which net.ParseIP rejects.
+func parseIPv4(s string) net.IP {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) != 4 {
+		return nil
+	}
+	ip := make(net.IP, 4)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > 255 {
+			return nil
+		}
+		ip[i] = byte(n)
+	}
+	return ip
+}
+
+// mergeIPRanges sorts ranges by first address and merges overlapping or
+// adjacent ones, so a lookup is a binary search.
+func mergeIPRanges(ranges []iplist.Range) []iplist.Range {
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].First, ranges[j].First) < 0
+	})
+	var out []iplist.Range
+	for _, r := range ranges {
+		if n := len(out); n > 0 && bytes.Compare(r.First, nextIPv4(out[n-1].Last)) <= 0 {
+			if bytes.Compare(r.Last, out[n-1].Last) > 0 {
+				out[n-1].Last = r.Last
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// nextIPv4 returns ip+1, or ip itself for 255.255.255.255.
+func nextIPv4(ip net.IP) net.IP {
+	next := make(net.IP, 4)
+	copy(next, ip)
+	for i := 3; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next
+		}
+	}
+	return ip
+}
+
+// Lookup implements iplist.Ranger. Blocked peers are recorded for BlockedFor.
+// IPv6 peers are never blocked. (iplist.IPList is not used: it retries IPv4
+// misses in their 16-byte form, which 0.0.0.0/8 ranges then match.)
+func (b *Blocklist) Lookup(ip net.IP) (iplist.Range, bool) {
+	v4 := ip.To4()
+	if v4 == nil {
+		return iplist.Range{}, false
+	}
+	i := sort.Search(len(b.ranges), func(i int) bool {
+		return bytes.Compare(b.ranges[i].Last, v4) >= 0
+	})
+	if i == len(b.ranges) || bytes.Compare(b.ranges[i].First, v4) > 0 {
+		return iplist.Range{}, false
+	}
+	b.mu.Lock()
+	for _, peers := range b.scans {
+		peers[v4.String()] = struct{}{}
+	}
+	b.mu.Unlock()
+	return b.ranges[i], true
+}
+
+// NumRanges implements iplist.Ranger.
+func (b *Blocklist) NumRanges() int {
+	return len(b.ranges)
+}
+
+// Track starts counting the peers blocked while scan key runs. The count
+// holds every peer the client refused in that time, including peers of
+// other scans sharing the client.
+func (b *Blocklist) Track(key string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scans[key] = make(map[string]struct{})
+}
+
+// Untrack stops counting for scan key.
+func (b *Blocklist) Untrack(key string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.scans, key)
+}
+
+// BlockedFor returns the number of distinct peer IPs refused since Track(key).
+func (b *Blocklist) BlockedFor(key string) int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.scans[key])
+}
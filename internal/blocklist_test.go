@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testBlocklist = `# PeerGuardian
+Some Org:1.2.3.0-1.2.3.255
+Overlap:1.2.3.128-1.2.4.10
+// eMule DAT
+010.000.000.000 - 010.000.000.255 , 000 , Private range
+020.000.000.000 - 020.000.000.255 , 200 , Allowed range
+garbage line
+`
+
+func TestLoadBlocklist(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "list.p2p")
+	os.WriteFile(plain, []byte(testBlocklist), 0o644)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(testBlocklist))
+	gz.Close()
+	gzipped := filepath.Join(dir, "list.p2p.gz")
+	os.WriteFile(gzipped, buf.Bytes(), 0o644)
+
+	for _, path := range []string{plain, gzipped} {
+		b, err := LoadBlocklist(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if b.NumRanges() != 2 {
+			t.Errorf("%s: expected 2 merged ranges, got %d", path, b.NumRanges())
+		}
+		if b.Skipped != 1 {
+			t.Errorf("%s: expected 1 skipped line, got %d", path, b.Skipped)
+		}
+	}
+}
+
+func TestLoadBlocklist_Errors(t *testing.T) {
+	if b, err := LoadBlocklist(""); b != nil || err != nil {
+		t.Errorf("empty path should return nil, nil; got %v, %v", b, err)
+	}
+	if _, err := LoadBlocklist(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.dat")
+	os.WriteFile(empty, []byte("# nothing\n"), 0o644)
+	if _, err := LoadBlocklist(empty); err == nil {
+		t.Error("expected error for a list without ranges")
+	}
+}
+
+func TestBlocklistLookup(t *testing.T) {
+	b, err := parseBlocklist(bytes.NewBufferString(testBlocklist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"1.2.3.0", true},
+		{"1.2.4.10", true}, // merged from the overlapping range
+		{"1.2.4.11", false},
+		{"10.0.0.7", true},
+		{"20.0.0.7", false}, // DAT allow level
+		{"9.255.255.255", false},
+		{"::ffff:10.0.0.1", true},
+		{"2001:db8::1", false},
+	}
+	b.Track("scan")
+	for _, tt := range tests {
+		if _, ok := b.Lookup(net.ParseIP(tt.ip)); ok != tt.blocked {
+			t.Errorf("Lookup(%s) = %v, want %v", tt.ip, ok, tt.blocked)
+		}
+	}
+	b.Lookup(net.ParseIP("1.2.3.0")) // already counted
+	if got := b.BlockedFor("scan"); got != 4 {
+		t.Errorf("expected 4 distinct blocked peers, got %d", got)
+	}
+}
+
+func TestBlocklist_PerScanCounts(t *testing.T) {
+	b, err := parseBlocklist(bytes.NewBufferString(testBlocklist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Lookup(net.ParseIP("10.0.0.1")) // before any scan: not counted
+	b.Track("first")
+	b.Lookup(net.ParseIP("10.0.0.2"))
+	b.Track("second")
+	b.Lookup(net.ParseIP("10.0.0.3"))
+	b.Untrack("first")
+	b.Lookup(net.ParseIP("10.0.0.4"))
+
+	if got := b.BlockedFor("first"); got != 0 {
+		t.Errorf("untracked scan should report 0, got %d", got)
+	}
+	if got := b.BlockedFor("second"); got != 2 {
+		t.Errorf("second scan: expected 2 peers blocked while it ran, got %d", got)
+	}
+
+	// A later scan of the same torrent starts from zero, not a running total.
+	b.Track("second")
+	if got := b.BlockedFor("second"); got != 0 {
+		t.Errorf("re-tracked scan should start at 0, got %d", got)
+	}
+
+	var none *Blocklist
+	none.Track("x")
+	none.Untrack("x")
+	if none.BlockedFor("x") != 0 {
+		t.Error("nil blocklist should report 0 blocked peers")
+	}
+}
+
+func TestBlocklistLookup_ZeroRange(t *testing.T) {
+	b, err := parseBlocklist(bytes.NewBufferString("Bogon:0.0.0.0-0.255.255.255\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Lookup(net.ParseIP("8.8.8.8")); ok {
+		t.Error("0.0.0.0/8 must not match other IPv4 addresses")
+	}
+}
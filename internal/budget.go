@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// diskBudget is the temp-disk space shared by all scans. A scan reserves
+// what it is about to write once the torrent's metadata tells which pieces
+// that is, and grows the reservation when a retry fetches more, so the sum
+// of what concurrent scans may write never exceeds the budget.
+type diskBudget struct {
+	total int64
+
+	mu      sync.Mutex
+	used    int64
+	held    map[string]int64 // reservation per scan
+	waiting map[string]bool  // scans blocked in grow
+	changed chan struct{}    // closed and replaced on every release
+}
+
+func newDiskBudget(total int64) *diskBudget {
+	return &diskBudget{
+		total:   total,
+		held:    make(map[string]int64),
+		waiting: make(map[string]bool),
+		changed: make(chan struct{}),
+	}
+}
+
+// grow raises the reservation of scan key to n bytes, blocking until the
+// space is free. It fails when n exceeds the whole budget, or instead of
+// waiting when every other scan holding space is itself blocked in grow, as
+// none of them could finish to make room.
+func (b *diskBudget) grow(ctx context.Context, key string, n int64) error {
+	if n > b.total {
+		return diskQuotaError(key, n, b.total)
+	}
+	for {
+		b.mu.Lock()
+		extra := n - b.held[key]
+		if extra <= 0 {
+			b.mu.Unlock()
+			return nil
+		}
+		if b.used+extra <= b.total {
+			b.used += extra
+			b.held[key] = n
+			b.mu.Unlock()
+			return nil
+		}
+		if b.othersWaiting(key) {
+			b.mu.Unlock()
+			return fmt.Errorf("disk budget exhausted for %s: need %dMB more and every other scan is waiting for space",
+				TruncHash(key), extra/1024/1024)
+		}
+		b.waiting[key] = true
+		wait := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			b.mu.Lock()
+			delete(b.waiting, key)
+			b.mu.Unlock()
+			return ctx.Err()
+		case <-wait:
+		}
+		b.mu.Lock()
+		delete(b.waiting, key)
+		b.mu.Unlock()
+	}
+}
+
+// othersWaiting reports whether every scan other than key that holds space
+// is blocked in grow. Callers hold b.mu.
+func (b *diskBudget) othersWaiting(key string) bool {
+	for k := range b.held {
+		if k != key && !b.waiting[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// release returns everything scan key reserved.
+func (b *diskBudget) release(key string) {
+	b.mu.Lock()
+	b.used -= b.held[key]
+	delete(b.held, key)
+	close(b.changed)
+	b.changed = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// Worker subprocesses reserve from the parent's budget over two extra
+// pipes: the worker writes the bytes its torrent needs, one request per
+// line, to fd 3 and reads "ok" or an error message back from fd 4.
+const (
+	budgetRequestFD = 3
+	budgetReplyFD   = 4
+)
+
+// serveDiskBudget wires the budget pipes into cmd, which must not have been
+// started yet, and grows the reservation of key from budget for each
+// request. The returned stop function must be called once the worker has
+// exited.
+func serveDiskBudget(ctx context.Context, cmd *exec.Cmd, budget *diskBudget, key string) (stop func(), err error) {
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	replyR, replyW, err := os.Pipe()
+	if err != nil {
+		reqR.Close()
+		reqW.Close()
+		return nil, err
+	}
+	cmd.ExtraFiles = []*os.File{reqW, replyR}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sc := bufio.NewScanner(reqR)
+		for sc.Scan() {
+			need, err := strconv.ParseInt(sc.Text(), 10, 64)
+			if err == nil {
+				err = budget.grow(ctx, key, need)
+			}
+			reply := "ok"
+			if err != nil {
+				reply = err.Error()
+			}
+			fmt.Fprintln(replyW, reply)
+		}
+	}()
+
+	return func() {
+		cancel()
+		reqW.Close() // with the worker gone, the request pipe reaches EOF
+		<-done
+		reqR.Close()
+		replyR.Close()
+		replyW.Close()
+	}, nil
+}
+
+// workerDiskReserve returns the DownloadConfig.DiskReserve of a worker
+// subprocess: requests go to the parent's budget over the budget pipes.
+// Returns nil when the worker has no budget.
+func workerDiskReserve(enabled bool) func(ctx context.Context, infoHash string, need int64) error {
+	if !enabled {
+		return nil
+	}
+	req := os.NewFile(budgetRequestFD, "budget-request")
+	reply := bufio.NewReader(os.NewFile(budgetReplyFD, "budget-reply"))
+	var mu sync.Mutex
+	var broken error
+	return func(ctx context.Context, infoHash string, need int64) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if broken != nil {
+			return broken
+		}
+		if _, err := fmt.Fprintln(req, need); err != nil {
+			broken = fmt.Errorf("disk budget request: %w", err)
+			return broken
+		}
+		answer := make(chan error, 1)
+		go func() {
+			line, err := reply.ReadString('\n')
+			if err != nil {
+				answer <- fmt.Errorf("disk budget reply: %w", err)
+				return
+			}
+			if line = strings.TrimSpace(line); line != "ok" {
+				answer <- errors.New(line)
+				return
+			}
+			answer <- nil
+		}()
+		select {
+		case err := <-answer:
+			return err
+		case <-ctx.Done():
+			// The reply still in flight would answer the next request.
+			broken = ctx.Err()
+			return broken
+		}
+	}
+}
+
+// perWorker splits a global limit between concurrent workers, each of which
+// runs its own BitTorrent client. Returns 0 (unlimited) when limit is 0.
+func perWorker(limit, workers int) int {
+	if limit <= 0 {
+		return 0
+	}
+	return max(1, limit/max(workers, 1))
+}
+
+// newRateLimiter returns a limiter for bytesPerSec, or nil when unlimited.
+// The burst is left at 0 so the client picks one that fits its chunk size.
+func newRateLimiter(bytesPerSec int) *rate.Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSec), 0)
+}
+
+// diskQuotaError reports a download that needs more than the whole disk
+// budget, so no amount of waiting for other scans would make room.
+func diskQuotaError(infoHash string, need, total int64) error {
+	return fmt.Errorf("%s needs %dMB, which exceeds disk budget of %dMB",
+		TruncHash(infoHash), need/1024/1024, total/1024/1024)
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestDiskBudget_GrowRelease(t *testing.T) {
+	b := newDiskBudget(100)
+	ctx := context.Background()
+
+	if err := b.grow(ctx, "a", 40); err != nil {
+		t.Fatalf("grow(a, 40): %v", err)
+	}
+	if err := b.grow(ctx, "a", 60); err != nil || b.used != 60 {
+		t.Fatalf("retry growth: used=%d, %v", b.used, err)
+	}
+	if err := b.grow(ctx, "a", 30); err != nil || b.used != 60 {
+		t.Fatalf("smaller request must keep the reservation: used=%d, %v", b.used, err)
+	}
+
+	got := make(chan error)
+	go func() { got <- b.grow(ctx, "b", 60) }()
+	select {
+	case <-got:
+		t.Fatal("second reservation should block until the first is released")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.release("a")
+	select {
+	case err := <-got:
+		if err != nil || b.used != 60 {
+			t.Errorf("expected b to hold 60, used=%d, %v", b.used, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reservation not granted after release")
+	}
+	b.release("b")
+	if b.used != 0 || len(b.held) != 0 {
+		t.Errorf("expected budget empty after release, used=%d held=%v", b.used, b.held)
+	}
+}
+
+func TestDiskBudget_LargerThanTotal(t *testing.T) {
+	b := newDiskBudget(100)
+	if err := b.grow(context.Background(), "a", 500); err == nil {
+		t.Fatal("a reservation larger than the budget should fail")
+	}
+	if b.used != 0 {
+		t.Errorf("failed reservation must not take space, used=%d", b.used)
+	}
+}
+
+func TestDiskBudget_NoDeadlock(t *testing.T) {
+	b := newDiskBudget(100)
+	ctx := context.Background()
+	b.grow(ctx, "a", 60)
+	b.grow(ctx, "b", 40)
+
+	// a waits for b to finish; b then needs more too and must fail rather
+	// than wait for a forever.
+	got := make(chan error)
+	go func() { got <- b.grow(ctx, "a", 70) }()
+	time.Sleep(50 * time.Millisecond)
+	if err := b.grow(ctx, "b", 50); err == nil {
+		t.Fatal("expected b's growth to fail while a is waiting on it")
+	}
+	b.release("b")
+	if err := <-got; err != nil {
+		t.Errorf("a should get its space once b is released: %v", err)
+	}
+}
+
+func TestDiskBudget_ContextCanceled(t *testing.T) {
+	b := newDiskBudget(100)
+	b.grow(context.Background(), "a", 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.grow(ctx, "b", 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if b.used != 100 || b.waiting["b"] {
+		t.Errorf("canceled reservation must not take space, used=%d", b.used)
+	}
+}
+
+func TestDiskBudget_WorkerPipes(t *testing.T) {
+	if os.Getenv("GO_TEST_SUBPROCESS") == "1" {
+		reserve := workerDiskReserve(true)
+		ctx := context.Background()
+		if reserve(ctx, "ignored", 40) != nil || reserve(ctx, "ignored", 500) == nil || reserve(ctx, "ignored", 60) != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	b := newDiskBudget(100)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDiskBudget_WorkerPipes$")
+	cmd.Env = append(os.Environ(), "GO_TEST_SUBPROCESS=1")
+	stop, err := serveDiskBudget(context.Background(), cmd, b, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := cmd.Run()
+	stop()
+	if runErr != nil {
+		t.Fatalf("worker side of the budget protocol failed: %v", runErr)
+	}
+	if b.held["hash"] != 60 {
+		t.Errorf("expected the worker's reservation of 60 under its key, got %v", b.held)
+	}
+}
+
+func TestDiskBudget_DuplicateHashKeys(t *testing.T) {
+	var cfg Config
+	k1 := budgetKey(cfg.ToWorkerInput("hash", 1, 2))
+	k2 := budgetKey(cfg.ToWorkerInput("hash", 2, 2))
+	if k1 == k2 {
+		t.Fatalf("two scans of one hash share the key %q", k1)
+	}
+
+	b := newDiskBudget(100)
+	ctx := context.Background()
+	if err := b.grow(ctx, k1, 40); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.grow(ctx, k2, 30); err != nil {
+		t.Fatal(err)
+	}
+	b.release(k1)
+	if b.used != 30 || b.held[k2] != 30 {
+		t.Errorf("releasing one scan freed the other: used=%d held=%v", b.used, b.held)
+	}
+}
+
+func TestPerWorker(t *testing.T) {
+	tests := []struct {
+		limit, workers, want int
+	}{
+		{0, 5, 0},
+		{-1, 5, 0},
+		{1000, 5, 200},
+		{1000, 0, 1000},
+		{3, 5, 1},
+	}
+	for _, tt := range tests {
+		if got := perWorker(tt.limit, tt.workers); got != tt.want {
+			t.Errorf("perWorker(%d, %d) = %d, want %d", tt.limit, tt.workers, got, tt.want)
+		}
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	if newRateLimiter(0) != nil {
+		t.Error("expected nil limiter for 0 (unlimited)")
+	}
+	if l := newRateLimiter(1024); l == nil || l.Limit() != 1024 {
+		t.Errorf("expected 1024 B/s limiter, got %v", l)
+	}
+}
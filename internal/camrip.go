@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"math"
+	"sort"
+)
+
+// Source quality signals reported in ScanResult.SourceQualitySignals.
+const (
+	SignalLetterboxDrift  = "letterbox_drift"   // black bars change size between frames (handheld/tripod drift)
+	SignalLowContrast     = "low_contrast"      // washed-out blacks and highlights of a filmed screen
+	SignalKeystone        = "keystone"          // picture edges form a trapezoid (screen filmed off-axis)
+	SignalAudioNoiseFloor = "audio_noise_floor" // audience/room noise never drops to digital silence
+	SignalMonoAudio       = "mono_audio"        // camcorder microphone
+	SignalLowBitrateAudio = "low_bitrate_audio"
+)
+
+const (
+	camDarkLuma        = 24    // luma at or below this is treated as black
+	camMinFrames       = 3     // usable frames needed for visual signals
+	camDriftRatio      = 0.02  // bar size change (fraction of height) counted as drift
+	camLowContrast     = 110.0 // median p1–p99 luma range below this is low contrast
+	camKeystoneSkew    = 0.015 // edge offset (fraction of width) between upper and lower picture
+	camNoiseFloorDB    = -45.0 // 10th percentile loudness above this is a noisy source
+	camNoiseWindowSecs = 0.05
+	camAudioSeconds    = 30    // seconds of audio decoded for the noise floor
+	camAudioRate       = 16000 // sample rate the audio is decoded at
+	camLowAudioBitrate = 112000
+)
+
+// blackBars returns the number of black rows at the top and bottom of a frame.
+func blackBars(f grayFrame) (top, bottom int) {
+	rowDark := func(y int) bool {
+		var sum float64
+		for x := 0; x < f.width; x++ {
+			sum += f.at(x, y)
+		}
+		return sum/float64(f.width) <= camDarkLuma
+	}
+	limit := f.height / 3
+	for top < limit && rowDark(top) {
+		top++
+	}
+	for bottom < limit && rowDark(f.height-1-bottom) {
+		bottom++
+	}
+	return top, bottom
+}
+
+// lumaRange returns the spread between the 1st and 99th luma percentiles of
+// the lit pixels between the black bars. Black bars and the dark surround
+// of a filmed screen are excluded so they don't count as deep blacks.
+func lumaRange(f grayFrame, top, bottom int) float64 {
+	var hist [256]int
+	n := 0
+	for _, p := range f.pix[top*f.width : (f.height-bottom)*f.width] {
+		if p > camDarkLuma {
+			hist[p]++
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	lo, hi := -1, -1
+	acc := 0
+	for v, c := range hist {
+		acc += c
+		if lo < 0 && acc >= n/100 {
+			lo = v
+		}
+		if hi < 0 && acc >= n*99/100 {
+			hi = v
+		}
+	}
+	return float64(hi - lo)
+}
+
+// rowEdges returns the first and last non-black columns of row y,
+// averaging 4-pixel runs to ignore noise.
+func rowEdges(f grayFrame, y int) (left, right int) {
+	lit := func(x int) bool {
+		var sum float64
+		for i := 0; i < 4; i++ {
+			sum += f.at(min(f.width-1, x+i), y)
+		}
+		return sum/4 > camDarkLuma
+	}
+	left, right = 0, f.width-1
+	for left < f.width/3 && !lit(left) {
+		left++
+	}
+	for right > f.width*2/3 && !lit(right-3) {
+		right--
+	}
+	return left, right
+}
+
+// keystoneSkew measures how far the picture's left/right edges shift between
+// the upper and lower quarter of the active area, as a fraction of width.
+// A filmed screen seen off-axis is a trapezoid; a real video is a rectangle.
+func keystoneSkew(f grayFrame, top, bottom int) float64 {
+	active := f.height - top - bottom
+	if active < f.height/3 {
+		return 0
+	}
+	l1, r1 := rowEdges(f, top+active/4)
+	l2, r2 := rowEdges(f, f.height-1-bottom-active/4)
+	if l1 == 0 && l2 == 0 && r1 == f.width-1 && r2 == f.width-1 {
+		return 0 // picture fills the width
+	}
+	skew := max(math.Abs(float64(l1-l2)), math.Abs(float64(r1-r2)))
+	return skew / float64(f.width)
+}
+
+// audioNoiseFloor returns the 10th percentile of short-window loudness in
+// dBFS. Digital sources have near-silent moments far below a camcorder's
+// room noise.
+func audioNoiseFloor(samples []float64, sampleRate int) (float64, bool) {
+	win := int(float64(sampleRate) * camNoiseWindowSecs)
+	if win <= 0 || len(samples) < win*20 {
+		return 0, false
+	}
+	var levels []float64
+	for off := 0; off+win <= len(samples); off += win {
+		var sum float64
+		for _, s := range samples[off : off+win] {
+			sum += s * s
+		}
+		levels = append(levels, 10*math.Log10(sum/float64(win)+1e-12))
+	}
+	sort.Float64s(levels)
+	return levels[len(levels)/10], true
+}
+
+// median returns the median of values (0 if empty). values is sorted in place.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	return values[len(values)/2]
+}
+
+// assessSourceQuality combines picture and audio signals typical of CAM and
+// telesync recordings. Audio signals alone are common in old or low-budget
+// releases, so a suspect verdict needs two visual signals, or one visual and
+// one audio signal. noiseFloor is ignored when hasNoiseFloor is false.
+func assessSourceQuality(frames []grayFrame, audio []AudioTrack, noiseFloor float64, hasNoiseFloor bool) (bool, []string) {
+	var visual, aural []string
+
+	var bars, ranges []float64
+	skewed, usable := 0, 0
+	for _, f := range frames {
+		if f.stddev() < upscaleMinStddev {
+			continue // black or fade frame
+		}
+		usable++
+		top, bottom := blackBars(f)
+		bars = append(bars, float64(top+bottom)/float64(f.height))
+		ranges = append(ranges, lumaRange(f, top, bottom))
+		if keystoneSkew(f, top, bottom) > camKeystoneSkew {
+			skewed++
+		}
+	}
+
+	if usable >= camMinFrames {
+		lo, hi := bars[0], bars[0]
+		for _, b := range bars {
+			lo, hi = min(lo, b), max(hi, b)
+		}
+		if hi-lo > camDriftRatio {
+			visual = append(visual, SignalLetterboxDrift)
+		}
+		if median(ranges) < camLowContrast {
+			visual = append(visual, SignalLowContrast)
+		}
+		if skewed*2 >= usable {
+			visual = append(visual, SignalKeystone)
+		}
+	}
+
+	if hasNoiseFloor && noiseFloor > camNoiseFloorDB {
+		aural = append(aural, SignalAudioNoiseFloor)
+	}
+	for _, t := range audio {
+		if !t.IsMainProgram() {
+			continue
+		}
+		if t.Channels == 1 {
+			aural = append(aural, SignalMonoAudio)
+		}
+		if t.Bitrate > 0 && t.Bitrate < camLowAudioBitrate {
+			aural = append(aural, SignalLowBitrateAudio)
+		}
+		break // judge the first main-program track
+	}
+
+	suspect := len(visual) >= 2 || (len(visual) >= 1 && len(aural) >= 1)
+	return suspect, append(visual, aural...)
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// texturedFrame fills a width×height frame with random texture in [lo, hi].
+// When inset > 0 the picture is a trapezoid: rows are black outside
+// [inset*y/height, width-inset*y/height), like a screen filmed off-axis.
+// barRows black rows are added at the top and bottom.
+func texturedFrame(rng *rand.Rand, width, height int, lo, hi float64, inset, barRows int) grayFrame {
+	pix := make([]byte, width*height)
+	for y := barRows; y < height-barRows; y++ {
+		shift := inset * y / height
+		for x := shift; x < width-shift; x++ {
+			pix[y*width+x] = byte(lo + rng.Float64()*(hi-lo))
+		}
+	}
+	return grayFrame{width: width, height: height, pix: pix}
+}
+
+func TestAssessSourceQuality_Clean(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var frames []grayFrame
+	for i := 0; i < 4; i++ {
+		frames = append(frames, texturedFrame(rng, 320, 180, 16, 235, 0, 20))
+	}
+	audio := []AudioTrack{{Channels: 6, Bitrate: 640000, Role: RoleMain}}
+
+	suspect, signals := assessSourceQuality(frames, audio, -70, true)
+	if suspect || len(signals) != 0 {
+		t.Errorf("expected clean source, got suspect=%v signals=%v", suspect, signals)
+	}
+}
+
+func TestAssessSourceQuality_Cam(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	var frames []grayFrame
+	for i := 0; i < 4; i++ {
+		// Low contrast, trapezoid picture, bars drifting by 4 rows per frame
+		frames = append(frames, texturedFrame(rng, 320, 180, 60, 150, 24, 10+4*i))
+	}
+	audio := []AudioTrack{{Channels: 1, Bitrate: 96000, Role: RoleMain}}
+
+	suspect, signals := assessSourceQuality(frames, audio, -35, true)
+	if !suspect {
+		t.Fatalf("expected CAM suspect, signals=%v", signals)
+	}
+	for _, want := range []string{SignalLetterboxDrift, SignalLowContrast, SignalKeystone, SignalAudioNoiseFloor, SignalMonoAudio, SignalLowBitrateAudio} {
+		if !slices.Contains(signals, want) {
+			t.Errorf("missing signal %s in %v", want, signals)
+		}
+	}
+}
+
+func TestAssessSourceQuality_AudioOnlySignals(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	var frames []grayFrame
+	for i := 0; i < 4; i++ {
+		frames = append(frames, texturedFrame(rng, 320, 180, 16, 235, 0, 0))
+	}
+	// An old mono film: audio signals alone must not make it a CAM
+	audio := []AudioTrack{{Channels: 1, Bitrate: 96000, Role: RoleMain}}
+
+	suspect, signals := assessSourceQuality(frames, audio, -40, true)
+	if suspect {
+		t.Errorf("audio signals alone should not be suspect, got %v", signals)
+	}
+	if len(signals) != 3 {
+		t.Errorf("expected 3 audio signals, got %v", signals)
+	}
+}
+
+func TestAudioNoiseFloor(t *testing.T) {
+	rate := 16000
+	samples := make([]float64, rate*5)
+	for i := range samples {
+		// Loud tone for the first half, silence after
+		if i < len(samples)/2 {
+			samples[i] = 0.5 * math.Sin(2*math.Pi*440*float64(i)/float64(rate))
+		}
+	}
+	floor, ok := audioNoiseFloor(samples, rate)
+	if !ok || floor > -100 {
+		t.Errorf("expected digital silence floor, got %.1f (ok=%v)", floor, ok)
+	}
+
+	if _, ok := audioNoiseFloor(samples[:100], rate); ok {
+		t.Error("expected no result for a too-short clip")
+	}
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -29,11 +30,46 @@ type Config struct {
 	MinBytesMKV int // bytes to download for MKV (headers at start)
 	MinBytesMP4 int // bytes to download for MP4 (moov can be at end)
 
+	// Piece storage
+	MemoryCapMB int // >0: keep pieces in memory, capped at this many MB per worker (0 = disk)
+
+	// Resource budget (0 = unlimited)
+	DownloadRateKB int // global download rate limit in KB/s, split between workers
+	UploadRateKB   int // global upload rate limit in KB/s, split between workers
+	MaxConns       int // established peer connections per worker
+	MaxHalfOpen    int // half-open peer connections per worker
+	DiskBudgetMB   int // temp-disk space shared by all workers
+
+	// Network
+	Proxy         string // socks5://, http:// or https:// proxy for all outbound traffic
+	ProxyFallback bool   // scan without the proxy when it is unreachable (default: refuse)
+	BindAddress   string // IP or interface name to listen and dial from
+	PortMin       int    // listen port range shared by workers (0 = random port)
+	PortMax       int
+	DisableIPv6   bool
+	DisableUTP    bool
+	DisableTCP    bool
+	Encryption    string // "", "prefer", "require" or "off"
+
+	// Peer discovery
+	ExtraTrackers   []string // announce URLs added to (or replacing) the defaults
+	TrackersFile    string   // file with one announce URL per line
+	ReplaceTrackers bool     // announce only to the extra trackers
+	Trackers        []string // effective announce list, set by ResolvePeerDiscovery (nil = defaults)
+	DHTNodes        []string // DHT bootstrap nodes (host:port); empty = library defaults
+	DHTStateFile    string   // persisted DHT routing table ("" = not persisted)
+	Blocklist       string   // P2P or DAT peer blocklist file, optionally gzipped
+
 	// Retry
 	MaxFFprobeRetries int
 
 	// Stats
 	StatsFile string // path to persistent stats JSON file
+
+	// Contact sheet
+	Thumbnails      int    // keyframes per contact sheet (0 = disabled)
+	ThumbnailFormat string // jpg or webp
+	ThumbnailDir    string // where sheets are written; empty = base64 in the result
 }
 
 // IsVerbose returns true when the verbose level is set to full verbose output.
@@ -53,6 +89,7 @@ func VerboseLevelLabel(level int) string {
 
 // DefaultConfig returns a Config with sensible defaults, overridden by env vars.
 func DefaultConfig() Config {
+	portMin, portMax := envPortRange("TRUESPEC_LISTEN_PORTS")
 	return Config{
 		Concurrency:       envInt("TRUESPEC_CONCURRENCY", 5),
 		StallTimeout:      time.Duration(envInt("TRUESPEC_STALL_TIMEOUT", 90)) * time.Second,
@@ -61,11 +98,44 @@ func DefaultConfig() Config {
 		TempDir:           envString("TRUESPEC_TEMP_DIR", os.TempDir()+"/truespec"),
 		MinBytesMKV:       envInt("TRUESPEC_MIN_BYTES_MKV", 10*1024*1024), // 10MB
 		MinBytesMP4:       envInt("TRUESPEC_MIN_BYTES_MP4", 20*1024*1024), // 20MB
+		MemoryCapMB:       envInt("TRUESPEC_MEMORY_CAP", 0),
+		DownloadRateKB:    envInt("TRUESPEC_DOWNLOAD_RATE", 0),
+		UploadRateKB:      envInt("TRUESPEC_UPLOAD_RATE", 0),
+		MaxConns:          envInt("TRUESPEC_MAX_CONNS", 0),
+		MaxHalfOpen:       envInt("TRUESPEC_MAX_HALF_OPEN", 0),
+		DiskBudgetMB:      envInt("TRUESPEC_DISK_BUDGET", 0),
+		Proxy:             os.Getenv("TRUESPEC_PROXY"),
+		ProxyFallback:     os.Getenv("TRUESPEC_PROXY_FALLBACK") == "1",
+		BindAddress:       os.Getenv("TRUESPEC_BIND_ADDRESS"),
+		PortMin:           portMin,
+		PortMax:           portMax,
+		DisableIPv6:       os.Getenv("TRUESPEC_DISABLE_IPV6") == "1",
+		DisableUTP:        os.Getenv("TRUESPEC_DISABLE_UTP") == "1",
+		DisableTCP:        os.Getenv("TRUESPEC_DISABLE_TCP") == "1",
+		Encryption:        os.Getenv("TRUESPEC_ENCRYPTION"),
+		ExtraTrackers:     SplitList(os.Getenv("TRUESPEC_TRACKERS")),
+		TrackersFile:      os.Getenv("TRUESPEC_TRACKERS_FILE"),
+		ReplaceTrackers:   os.Getenv("TRUESPEC_REPLACE_TRACKERS") == "1",
+		DHTNodes:          SplitList(os.Getenv("TRUESPEC_DHT_NODES")),
+		DHTStateFile:      envString("TRUESPEC_DHT_STATE", filepath.Join(TrueSpecDir(), "dht_nodes.dat")),
+		Blocklist:         os.Getenv("TRUESPEC_BLOCKLIST"),
 		MaxFFprobeRetries: 3,
 		StatsFile:         envString("TRUESPEC_STATS_FILE", defaultStatsPath()),
+		Thumbnails:        envInt("TRUESPEC_THUMBNAILS", 0),
+		ThumbnailFormat:   envString("TRUESPEC_THUMBNAIL_FORMAT", "jpg"),
 	}
 }
 
+// envPortRange reads a port range ("6881-6889") from an env var. Invalid
+// values are ignored.
+func envPortRange(key string) (lo, hi int) {
+	lo, hi, err := ParsePortRange(os.Getenv(key))
+	if err != nil {
+		return 0, 0
+	}
+	return lo, hi
+}
+
 func defaultStatsPath() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -105,5 +175,74 @@ func (c Config) ToWorkerInput(infoHash string, index, total int) WorkerInput {
 		MinBytesMKV:    c.MinBytesMKV,
 		MinBytesMP4:    c.MinBytesMP4,
 		MaxRetries:     c.MaxFFprobeRetries,
+		MemoryCapMB:    c.MemoryCapMB,
+		Thumbnails:     c.Thumbnails,
+		ThumbFormat:    c.ThumbnailFormat,
+		ThumbDir:       c.ThumbnailDir,
+		DownloadRate:   perWorker(c.DownloadRateKB*1024, c.Concurrency),
+		UploadRate:     perWorker(c.UploadRateKB*1024, c.Concurrency),
+		MaxConns:       c.MaxConns,
+		MaxHalfOpen:    c.MaxHalfOpen,
+		Proxy:          c.Proxy,
+		BindAddress:    c.BindAddress,
+		PortMin:        c.PortMin,
+		PortMax:        c.PortMax,
+		DisableIPv6:    c.DisableIPv6,
+		DisableUTP:     c.DisableUTP,
+		DisableTCP:     c.DisableTCP,
+		Encryption:     c.Encryption,
+		Trackers:       c.Trackers,
+		DHTNodes:       c.DHTNodes,
+		DHTStateFile:   c.DHTStateFile,
+		Blocklist:      c.Blocklist,
+	}
+}
+
+// downloadConfig returns the Downloader settings for an in-process client
+// shared by all scans, which gets the whole rate budget.
+func (c Config) downloadConfig() DownloadConfig {
+	return DownloadConfig{
+		TempDir:      c.TempDir,
+		StallTimeout: c.StallTimeout,
+		MaxTimeout:   c.MaxTimeout,
+		MinBytesMKV:  c.MinBytesMKV,
+		MinBytesMP4:  c.MinBytesMP4,
+		MemoryCap:    int64(c.MemoryCapMB) * 1024 * 1024,
+		DownloadRate: c.DownloadRateKB * 1024,
+		UploadRate:   c.UploadRateKB * 1024,
+		MaxConns:     c.MaxConns,
+		MaxHalfOpen:  c.MaxHalfOpen,
+		Proxy:        c.Proxy,
+		BindAddress:  c.BindAddress,
+		PortMin:      c.PortMin,
+		PortMax:      c.PortMax,
+		DisableIPv6:  c.DisableIPv6,
+		DisableUTP:   c.DisableUTP,
+		DisableTCP:   c.DisableTCP,
+		Encryption:   c.Encryption,
+		Trackers:     c.Trackers,
+		DHTNodes:     c.DHTNodes,
+		DHTStateFile: c.DHTStateFile,
+		Blocklist:    c.Blocklist,
+	}
+}
+
+// ResolvePeerDiscovery builds the effective tracker list from the defaults,
+// ExtraTrackers and TrackersFile, and validates the DHT bootstrap nodes.
+// Behind a proxy only HTTP(S) trackers can be reached (DHT and UDP are
+// disabled), so it fails when none is left.
+func (c *Config) ResolvePeerDiscovery() error {
+	trackers, err := ResolveTrackers(c.ExtraTrackers, c.TrackersFile, c.ReplaceTrackers)
+	if err != nil {
+		return err
+	}
+	if c.Proxy != "" && !hasHTTPTracker(trackers) {
+		return errors.New("no HTTP(S) tracker to announce to through the proxy: the default trackers are UDP, add one with --tracker or --trackers-file")
+	}
+	nodes, err := NormalizeDHTNodes(c.DHTNodes)
+	if err != nil {
+		return err
 	}
+	c.Trackers, c.DHTNodes = trackers, nodes
+	return nil
 }
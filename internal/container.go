@@ -0,0 +1,260 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/anacrolix/torrent"
+)
+
+// mediaSampleBytes caps the media data fetched after the container headers
+// when their layout is known: enough for the frame and audio analyses.
+const mediaSampleBytes = 4 * 1024 * 1024
+
+// containerStrategy is the byte-range plan for a container format.
+type containerStrategy struct {
+	Name string
+
+	// Layout parses the container headers through r and returns the exact
+	// byte ranges ffprobe needs. Nil when the format has no parser.
+	Layout func(r io.ReaderAt, size, sample int64) ([]byteRange, error)
+
+	// Duration reads the playback length in seconds from the container
+	// headers, to size the media sample for the analyzers. Nil, or 0 from
+	// the call, when the headers don't say.
+	Duration func(r io.ReaderAt, size int64) float64
+
+	// Fallback when Layout is nil or fails: the first bytes are always
+	// fetched, the last ones too when Tail is set (index or duration at the
+	// end), plus Spread single-piece probes evenly spaced across the file.
+	Tail   bool
+	Spread int
+}
+
+var (
+	matroskaContainer = containerStrategy{Name: "Matroska", Layout: mkvByteRanges, Duration: mkvDuration}
+	mp4Container      = containerStrategy{Name: "MP4", Layout: mp4ByteRanges, Duration: mp4Duration, Tail: true}
+	aviContainer      = containerStrategy{Name: "AVI", Layout: aviByteRanges, Tail: true}
+	tsContainer       = containerStrategy{Name: "MPEG-TS", Layout: tsByteRanges, Tail: true, Spread: tsProbes}
+	asfContainer      = containerStrategy{Name: "ASF", Layout: asfByteRanges, Tail: true}
+	flvContainer      = containerStrategy{Name: "FLV"}                 // onMetaData with duration at the start
+	mpegPSContainer   = containerStrategy{Name: "MPEG-PS", Tail: true} // duration from the last SCR
+	oggContainer      = containerStrategy{Name: "Ogg", Tail: true}     // duration from the last granule position
+)
+
+// containerStrategies maps file extensions to their container strategy.
+// Every extension in videoExts must have an entry.
+var containerStrategies = map[string]containerStrategy{
+	".mkv": matroskaContainer, ".mk3d": matroskaContainer, ".webm": matroskaContainer, ".mka": matroskaContainer,
+	".mp4": mp4Container, ".m4v": mp4Container, ".mov": mp4Container, ".m4a": mp4Container, ".3gp": mp4Container,
+	".avi": aviContainer, ".divx": aviContainer,
+	".ts": tsContainer, ".m2ts": tsContainer, ".mts": tsContainer,
+	".wmv": asfContainer, ".asf": asfContainer,
+	".flv": flvContainer,
+	".mpg": mpegPSContainer, ".mpeg": mpegPSContainer, ".vob": mpegPSContainer,
+	".ogv": oggContainer,
+}
+
+// containerFor returns the strategy for a lowercase extension. Unknown
+// formats get a strategy that fetches the first bytes only.
+func containerFor(ext string) containerStrategy {
+	return containerStrategies[ext]
+}
+
+// planRanges parses the container headers of a file through r and returns
+// the byte ranges to fetch. The media sample after the headers is the
+// smaller of minBytes and mediaSampleBytes, enough for ffprobe. With analyze
+// set it instead holds up to analysisHeadSeconds of playback (at most
+// analysisSampleCap), so the ffmpeg analyzers, which only read the gap-free
+// head, have more than a few seconds to decode; maxSample bounds it further
+// (0 = no bound).
+func planRanges(r io.ReaderAt, size int64, s containerStrategy, minBytes int, analyze bool, maxSample int64) ([]byteRange, error) {
+	if s.Layout == nil {
+		return nil, fmt.Errorf("no %s layout parser", s.Name)
+	}
+	sample := int64(min(minBytes, mediaSampleBytes))
+	if analyze {
+		var duration float64
+		if s.Duration != nil {
+			duration = s.Duration(r, size)
+		}
+		want := analysisSampleBytes(size, duration)
+		if maxSample > 0 {
+			want = min(want, maxSample)
+		}
+		sample = max(sample, want)
+	}
+	return s.Layout(r, size, sample)
+}
+
+// fallbackPieces returns the pieces covering the first minBytes of a file,
+// plus the last minBytes and the spread probes the strategy asks for.
+func fallbackPieces(t *torrent.Torrent, file *torrent.File, minBytes int, s containerStrategy) map[int]bool {
+	pieceLength := int(t.Info().PieceLength)
+	fileStartPiece := file.BeginPieceIndex()
+	fileEndPiece := file.EndPieceIndex() // exclusive
+
+	piecesNeeded := (minBytes + pieceLength - 1) / pieceLength
+	startEnd := min(fileStartPiece+piecesNeeded, fileEndPiece)
+
+	required := make(map[int]bool)
+	for i := fileStartPiece; i < startEnd; i++ {
+		required[i] = true
+	}
+	if s.Tail {
+		endStart := max(fileEndPiece-piecesNeeded, startEnd) // avoid overlap
+		for i := endStart; i < fileEndPiece; i++ {
+			required[i] = true
+		}
+	}
+	for k := 1; k <= s.Spread; k++ {
+		off := file.Length() * int64(k) / int64(s.Spread+1)
+		for i := range piecesForRanges(t, file, []byteRange{{off, off + 1}}) {
+			required[i] = true
+		}
+	}
+	return required
+}
+
+// MPEG-TS: PAT/PMT and the first PTS come from the start, the duration
+// from the last PTS, and the probes catch streams that only start later.
+const (
+	tsProbes     = 4
+	tsProbeBytes = 256 * 1024
+	tsTailBytes  = 2 * 1024 * 1024
+	tsSyncByte   = 0x47
+	tsSyncChecks = 4 // consecutive sync bytes required to accept a packet size
+)
+
+// tsPacketSize detects the packet size of a transport stream from its first
+// bytes: 188 (broadcast TS), 192 (M2TS, 4-byte timestamp prefix) or 204
+// (TS with Reed-Solomon parity). Returns 0 when there is no sync pattern.
+func tsPacketSize(head []byte) int {
+	for _, p := range []struct{ size, sync int }{{188, 0}, {192, 4}, {204, 0}} {
+		ok := true
+		for i := 0; i < tsSyncChecks; i++ {
+			off := p.sync + i*p.size
+			if off >= len(head) || head[off] != tsSyncByte {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return p.size
+		}
+	}
+	return 0
+}
+
+// tsByteRanges returns the start of a transport stream, evenly spaced
+// probes across it and its tail. TS has no index to parse; the packet size
+// is only checked to reject files that are not transport streams.
+func tsByteRanges(r io.ReaderAt, size, sample int64) ([]byteRange, error) {
+	head := make([]byte, min(size, 1024))
+	n, _ := r.ReadAt(head, 0)
+	pkt := int64(tsPacketSize(head[:n]))
+	if pkt == 0 {
+		return nil, errors.New("no MPEG-TS sync pattern")
+	}
+
+	ranges := []byteRange{{0, min(size, sample)}}
+	for k := int64(1); k <= tsProbes; k++ {
+		off := size * k / (tsProbes + 1)
+		off -= off % pkt
+		ranges = append(ranges, byteRange{off, min(size, off+tsProbeBytes)})
+	}
+	ranges = append(ranges, byteRange{max(0, size-tsTailBytes), size})
+	return mergeRanges(ranges), nil
+}
+
+const (
+	aviMaxChunks = 64               // top-level RIFF chunks walked before giving up
+	aviHeaderCap = 4 * 1024 * 1024  // guard against corrupt hdrl sizes
+	aviIndexCap  = 32 * 1024 * 1024 // idx1 holds 16 bytes per chunk
+)
+
+// aviByteRanges walks the top-level chunks of an AVI file's first RIFF
+// list and returns the hdrl header list, the start of the movi list and the
+// idx1 index, which muxers write after the media data at the end of the file.
+func aviByteRanges(r io.ReaderAt, size, sample int64) ([]byteRange, error) {
+	var hdr [12]byte
+	if n, _ := r.ReadAt(hdr[:min(12, size)], 0); n < 12 || string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "AVI " {
+		return nil, errors.New("not an AVI file")
+	}
+	riffEnd := min(size, 8+int64(binary.LittleEndian.Uint32(hdr[4:8])))
+
+	ranges := []byteRange{{0, 12}}
+	hdrl := false
+	for off, chunks := int64(12), 0; off+8 <= riffEnd && chunks < aviMaxChunks; chunks++ {
+		var ch [12]byte
+		n, _ := r.ReadAt(ch[:min(12, size-off)], off)
+		if n < 8 {
+			break
+		}
+		id := string(ch[0:4])
+		dataSize := int64(binary.LittleEndian.Uint32(ch[4:8]))
+		total := 8 + dataSize + dataSize&1 // chunks are padded to an even size
+		listType := ""
+		if id == "LIST" && n == 12 {
+			listType = string(ch[8:12])
+		}
+		switch {
+		case listType == "hdrl":
+			if total > aviHeaderCap {
+				return nil, fmt.Errorf("hdrl list too large (%d bytes)", total)
+			}
+			ranges = append(ranges, byteRange{off, min(size, off+total)})
+			hdrl = true
+		case listType == "movi":
+			ranges = append(ranges, byteRange{off, min(size, off+12+sample)})
+		case id == "idx1":
+			ranges = append(ranges, byteRange{off, min(size, off+min(total, aviIndexCap))})
+		}
+		off += total
+	}
+	if !hdrl {
+		return nil, errors.New("hdrl list not found")
+	}
+	return mergeRanges(ranges), nil
+}
+
+// ASF object GUIDs, in their on-disk byte order.
+var (
+	asfHeaderGUID = []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11, 0xA6, 0xD9, 0x00, 0xAA, 0x00, 0x62, 0xCE, 0x6C}
+	asfDataGUID   = []byte{0x36, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11, 0xA6, 0xD9, 0x00, 0xAA, 0x00, 0x62, 0xCE, 0x6C}
+)
+
+const (
+	asfHeaderCap  = 4 * 1024 * 1024  // guard against corrupt header sizes
+	asfIndexCap   = 16 * 1024 * 1024 // index objects after the data object
+	asfDataHeader = 50               // Data object header length
+)
+
+// asfByteRanges returns the Header object of a WMV/ASF file (stream
+// properties and duration), the start of the Data object and the index
+// objects that follow it at the end of the file.
+func asfByteRanges(r io.ReaderAt, size, sample int64) ([]byteRange, error) {
+	var obj [24]byte
+	if n, _ := r.ReadAt(obj[:min(24, size)], 0); n < 24 || !bytes.Equal(obj[:16], asfHeaderGUID) {
+		return nil, errors.New("not an ASF file")
+	}
+	headerSize := int64(binary.LittleEndian.Uint64(obj[16:24]))
+	if headerSize < 30 || headerSize > min(size, asfHeaderCap) {
+		return nil, fmt.Errorf("invalid ASF header size %d", headerSize)
+	}
+	ranges := []byteRange{{0, headerSize}}
+
+	n, _ := r.ReadAt(obj[:min(24, size-headerSize)], headerSize)
+	dataSize := int64(0)
+	if n == 24 && bytes.Equal(obj[:16], asfDataGUID) {
+		dataSize = int64(binary.LittleEndian.Uint64(obj[16:24]))
+	}
+	ranges = append(ranges, byteRange{headerSize, min(size, headerSize+asfDataHeader+sample)})
+	if dataEnd := headerSize + dataSize; dataSize >= asfDataHeader && dataSize < size && dataEnd < size {
+		ranges = append(ranges, byteRange{dataEnd, min(size, dataEnd+asfIndexCap)})
+	}
+	return mergeRanges(ranges), nil
+}
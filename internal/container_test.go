@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestContainerStrategies_CoverVideoExts(t *testing.T) {
+	for ext := range videoExts {
+		if containerFor(ext).Name == "" {
+			t.Errorf("no container strategy for video extension %s", ext)
+		}
+	}
+	if s := containerFor(".webm"); s.Layout == nil {
+		t.Error("WebM should use the Matroska layout parser")
+	}
+	if s := containerFor(".flv"); s.Tail || s.Layout != nil {
+		t.Errorf("FLV should fetch the head only, got %+v", s)
+	}
+}
+
+func riffChunk(id string, body []byte) []byte {
+	out := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	out = append(out, body...)
+	if len(body)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func TestAVIByteRanges(t *testing.T) {
+	hdrl := riffChunk("LIST", append([]byte("hdrl"), riffChunk("avih", make([]byte, 56))...))
+	junk := riffChunk("JUNK", make([]byte, 1001))
+	movi := riffChunk("LIST", append([]byte("movi"), make([]byte, 10*1024*1024)...))
+	idx1 := riffChunk("idx1", make([]byte, 16*5000))
+	body := bytes.Join([][]byte{[]byte("AVI "), hdrl, junk, movi, idx1}, nil)
+	file := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	file = append(file, body...)
+	size := int64(len(file))
+
+	r := &recordingReader{Reader: bytes.NewReader(file)}
+	ranges, err := aviByteRanges(r, size, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rangesCover(ranges, 0, int64(12+len(hdrl))) {
+		t.Errorf("hdrl not covered: %+v", ranges)
+	}
+	moviAt := int64(12 + len(hdrl) + len(junk))
+	if !rangesCover(ranges, moviAt, moviAt+12+1024*1024) {
+		t.Errorf("movi sample not covered: %+v", ranges)
+	}
+	if !rangesCover(ranges, size-int64(len(idx1)), size) {
+		t.Errorf("idx1 not covered: %+v", ranges)
+	}
+	if total := rangesTotal(ranges); total > 2*1024*1024 {
+		t.Errorf("expected ~1.1MB of ranges, got %d", total)
+	}
+
+	if _, err := aviByteRanges(bytes.NewReader([]byte("RIFF\x04\x00\x00\x00WAVE")), 12, 1024); err == nil {
+		t.Error("expected error for a WAV file")
+	}
+}
+
+func TestTSPacketSize(t *testing.T) {
+	packets := func(size, sync int) []byte {
+		data := make([]byte, size*5)
+		for i := 0; i < 5; i++ {
+			data[i*size+sync] = tsSyncByte
+		}
+		return data
+	}
+	if got := tsPacketSize(packets(188, 0)); got != 188 {
+		t.Errorf("TS: got %d, want 188", got)
+	}
+	if got := tsPacketSize(packets(192, 4)); got != 192 {
+		t.Errorf("M2TS: got %d, want 192", got)
+	}
+	if got := tsPacketSize(make([]byte, 1024)); got != 0 {
+		t.Errorf("zeros: got %d, want 0", got)
+	}
+}
+
+func TestTSByteRanges(t *testing.T) {
+	size := int64(100 * 1024 * 1024)
+	head := make([]byte, 188*5)
+	for i := 0; i < 5; i++ {
+		head[i*188] = tsSyncByte
+	}
+	file := append(head, make([]byte, size-int64(len(head)))...)
+
+	ranges, err := tsByteRanges(bytes.NewReader(file), size, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != tsProbes+2 {
+		t.Fatalf("expected head, %d probes and tail, got %+v", tsProbes, ranges)
+	}
+	if !rangesCover(ranges, 0, 1024*1024) || !rangesCover(ranges, size-tsTailBytes, size) {
+		t.Errorf("head or tail not covered: %+v", ranges)
+	}
+	for _, r := range ranges[1 : len(ranges)-1] {
+		if r.Start%188 != 0 {
+			t.Errorf("probe at %d not packet aligned", r.Start)
+		}
+	}
+}
+
+func TestASFByteRanges(t *testing.T) {
+	header := append(append([]byte(nil), asfHeaderGUID...), binary.LittleEndian.AppendUint64(nil, 5000)...)
+	header = append(header, make([]byte, 5000-len(header))...)
+	dataSize := 8 * 1024 * 1024
+	data := append(append([]byte(nil), asfDataGUID...), binary.LittleEndian.AppendUint64(nil, uint64(dataSize))...)
+	data = append(data, make([]byte, dataSize-len(data))...)
+	index := make([]byte, 3000)
+	file := bytes.Join([][]byte{header, data, index}, nil)
+	size := int64(len(file))
+
+	ranges, err := asfByteRanges(bytes.NewReader(file), size, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rangesCover(ranges, 0, 5000+asfDataHeader+1024*1024) {
+		t.Errorf("header and data sample not covered: %+v", ranges)
+	}
+	if !rangesCover(ranges, size-3000, size) {
+		t.Errorf("index objects not covered: %+v", ranges)
+	}
+
+	if _, err := asfByteRanges(bytes.NewReader(make([]byte, 100)), 100, 1024); err == nil {
+		t.Error("expected error for non-ASF data")
+	}
+}
+
+// firstRange returns the end of the range starting at offset 0: the gap-free
+// head the analyzers read once the ranges are downloaded.
+func firstRange(ranges []byteRange) int64 {
+	if len(ranges) == 0 || ranges[0].Start != 0 {
+		return 0
+	}
+	return ranges[0].End
+}
+
+func TestPlanRanges_AnalysisHead(t *testing.T) {
+	minBytes := 10 * 1024 * 1024
+	// Traffic over the ffprobe-only plan: the headers, Cues and cap
+	maxHead := int64(analysisSampleCap + 1024*1024)
+
+	// Confidence of the windows the duplicate-track and upmix checks fit into
+	// the head span: 1 spread, 0.5 packed into the opening, 0 skipped.
+	tests := []struct {
+		name                string
+		size                int64
+		duration            float64
+		wantDupe, wantUpmix float64
+	}{
+		{"two-hour 1 Mbit/s encode", 7200 * 1e6 / 8, 7200, 1, 1},
+		{"two-hour 10 Mbit/s encode", 7200 * 10e6 / 8, 7200, dummyHeadConf, upmixHeadConf},
+		{"60 GB remux", 60 << 30, 7200, 0, 0},
+	}
+	for _, tt := range tests {
+		f := buildSparseMKV(tt.size, tt.duration)
+
+		// The ffprobe sample alone is a few seconds of a high-bitrate video
+		ranges, err := planRanges(f, f.size, matroskaContainer, minBytes, false, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head := firstRange(ranges); head > mediaSampleBytes+1024*1024 {
+			t.Errorf("%s: ffprobe-only head is %dMB", tt.name, head/1024/1024)
+		}
+		if !rangesCover(ranges, f.size-int64(len(f.tail)), f.size) {
+			t.Errorf("%s: Cues at the end not covered: %+v", tt.name, ranges)
+		}
+
+		// With the analyzers the head grows, up to the cap, and the
+		// analyzers fit their windows into the span it gives
+		ranges, err = planRanges(f, f.size, matroskaContainer, minBytes, true, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		head := firstRange(ranges)
+		if head > maxHead {
+			t.Errorf("%s: analysis head is %dMB, cap is %dMB", tt.name, head/1024/1024, analysisSampleCap/1024/1024)
+		}
+		span := headSpan(head, f.size, tt.duration)
+		if starts, conf := dummyStarts(span); conf != tt.wantDupe || (starts == nil) != (conf == 0) {
+			t.Errorf("%s: head span %.1fs: duplicate-track windows %v (confidence %v, want %v)", tt.name, span, starts, conf, tt.wantDupe)
+		}
+		if starts, conf := upmixStarts(span); conf != tt.wantUpmix || (starts == nil) != (conf == 0) {
+			t.Errorf("%s: head span %.1fs: upmix windows %v (confidence %v, want %v)", tt.name, span, starts, conf, tt.wantUpmix)
+		}
+	}
+
+	// A memory cap bounds the sample further
+	f := buildSparseMKV(7200*10e6/8, 7200)
+	ranges, err := planRanges(f, f.size, matroskaContainer, minBytes, true, 16*1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head := firstRange(ranges); head > 17*1024*1024 {
+		t.Errorf("memory-capped head is %dMB", head/1024/1024)
+	}
+}
+
+func TestAnalysisSampleBytes_UnknownDuration(t *testing.T) {
+	// AVI, TS and ASF have no duration reader: a 700 MB DivX must not be
+	// fetched at the high assumed bitrate
+	if got := analysisSampleBytes(700<<20, 0); got > analysisSampleCap {
+		t.Errorf("unknown duration: %dMB, cap is %dMB", got>>20, analysisSampleCap>>20)
+	}
+	if got := analysisSampleBytes(10<<20, 0); got != 10<<20 {
+		t.Errorf("small file: got %d, want the whole file", got)
+	}
+}
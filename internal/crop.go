@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const cropKeyframes = 12 // keyframes fed to cropdetect
+
+// cropDetectFilter is the cropdetect filter. The black limit is a fraction
+// of the full range, which ffmpeg scales to the bit depth of the source:
+// 0.094 is 24 on 8-bit and 96 on 10-bit, where limited-range black is 64
+// and an absolute limit of 24 would count the bars as picture.
+const cropDetectFilter = "cropdetect=limit=0.094:round=2:reset=0"
+
+// cropRe matches cropdetect log lines: "... crop=3840:1600:0:280".
+var cropRe = regexp.MustCompile(`crop=(\d+):(\d+):(\d+):(\d+)`)
+
+// detectCrop runs ffmpeg's cropdetect over the keyframes of the downloaded
+// data and returns the active picture size. With reset=0 cropdetect reports
+// the bounding box of everything seen so far, so black fade-ins don't shrink
+// the result. Reading stops after span seconds (0 = no bound).
+func detectCrop(ctx context.Context, ffmpegPath, filePath string, span float64) (int, int, error) {
+	decCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	args := append([]string{"-hide_banner", "-skip_frame", "nokey"}, inputArgs(filePath, span)...)
+	args = append(args,
+		"-map", "0:v:0",
+		"-vf", cropDetectFilter,
+		"-frames:v", fmt.Sprint(cropKeyframes),
+		"-f", "null",
+		"-",
+	)
+	cmd := exec.CommandContext(decCtx, ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	w, h, ok := parseCropDetect(stderr.String())
+	if !ok {
+		if runErr != nil {
+			return 0, 0, fmt.Errorf("ffmpeg cropdetect failed: %w", runErr)
+		}
+		return 0, 0, fmt.Errorf("no crop detected")
+	}
+	return w, h, nil
+}
+
+// parseCropDetect returns the size from the last valid cropdetect line.
+func parseCropDetect(output string) (int, int, bool) {
+	matches := cropRe.FindAllStringSubmatch(output, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		w, _ := strconv.Atoi(matches[i][1])
+		h, _ := strconv.Atoi(matches[i][2])
+		if w > 0 && h > 0 {
+			return w, h, true
+		}
+	}
+	return 0, 0, false
+}
+
+// parseRatio parses an ffprobe ratio like "16:15" or "24000/1001".
+func parseRatio(s string) float64 {
+	sep := ":"
+	if strings.Contains(s, "/") {
+		sep = "/"
+	}
+	parts := strings.SplitN(s, sep, 2)
+	if len(parts) != 2 {
+		return 0
+	}
+	num, err1 := strconv.ParseFloat(parts[0], 64)
+	den, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil || num <= 0 || den <= 0 {
+		return 0
+	}
+	return num / den
+}
+
+// applyCrop sets the active picture size and its display aspect ratio,
+// accounting for anamorphic pixels (DVD, some HDTV). A crop larger than the
+// coded frame is clamped.
+func applyCrop(v *VideoInfo, width, height int) {
+	v.ActiveWidth = min(width, v.Width)
+	v.ActiveHeight = min(height, v.Height)
+	sar := parseRatio(v.SampleAspectRatio)
+	if sar == 0 {
+		sar = 1
+	}
+	if v.ActiveHeight > 0 {
+		v.DisplayAspectRatio = math.Round(float64(v.ActiveWidth)*sar/float64(v.ActiveHeight)*100) / 100
+	}
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCropDetect(t *testing.T) {
+	output := `[Parsed_cropdetect_0 @ 0x55] x1:0 x2:3839 y1:290 y2:1869 w:3840 h:1580 x:0 y:290 pts:0 t:0.000000 limit:0.094118 crop=3840:1580:0:290
+[Parsed_cropdetect_0 @ 0x55] x1:0 x2:3839 y1:280 y2:1879 w:3840 h:1600 x:0 y:280 pts:1001 t:1.001000 limit:0.094118 crop=3840:1600:0:280
+frame=   12 fps=0.0 q=-0.0 Lsize=N/A time=00:00:11.01 bitrate=N/A speed=  22x`
+
+	w, h, ok := parseCropDetect(output)
+	if !ok || w != 3840 || h != 1600 {
+		t.Errorf("parseCropDetect = (%d, %d, %v), want (3840, 1600, true)", w, h, ok)
+	}
+
+	if _, _, ok := parseCropDetect("Input #0, matroska,webm, from 'x.mkv':"); ok {
+		t.Error("expected no crop without cropdetect lines")
+	}
+}
+
+func TestApplyCrop(t *testing.T) {
+	tests := []struct {
+		name       string
+		video      VideoInfo
+		cropW      int
+		cropH      int
+		wantW      int
+		wantH      int
+		wantAspect float64
+	}{
+		{"scope in 2160p", VideoInfo{Width: 3840, Height: 2160}, 3840, 1600, 3840, 1600, 2.4},
+		{"pillarboxed 4:3", VideoInfo{Width: 1920, Height: 1080}, 1440, 1080, 1440, 1080, 1.33},
+		{"full frame", VideoInfo{Width: 1920, Height: 1080}, 1920, 1080, 1920, 1080, 1.78},
+		{"anamorphic DVD", VideoInfo{Width: 720, Height: 480, SampleAspectRatio: "32:27"}, 720, 480, 720, 480, 1.78},
+		{"clamped", VideoInfo{Width: 1280, Height: 720}, 1300, 720, 1280, 720, 1.78},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.video
+			applyCrop(&v, tt.cropW, tt.cropH)
+			if v.ActiveWidth != tt.wantW || v.ActiveHeight != tt.wantH || v.DisplayAspectRatio != tt.wantAspect {
+				t.Errorf("got %dx%d %.2f, want %dx%d %.2f",
+					v.ActiveWidth, v.ActiveHeight, v.DisplayAspectRatio, tt.wantW, tt.wantH, tt.wantAspect)
+			}
+		})
+	}
+}
+
+func TestDetectCrop_TenBit(t *testing.T) {
+	ffmpegPath := ResolveFFmpeg("")
+	if ffmpegPath == "" {
+		t.Skip("ffmpeg not available")
+	}
+
+	// 2.40:1 picture letterboxed into 320x240, stored as 10-bit limited range.
+	path := filepath.Join(t.TempDir(), "letterbox10.mkv")
+	gen := exec.Command(ffmpegPath, "-hide_banner", "-v", "error",
+		"-f", "lavfi", "-i", "testsrc2=s=320x134:d=1:r=12",
+		"-vf", "pad=320:240:0:53:black,format=yuv420p10le",
+		"-c:v", "ffv1", "-g", "1", path)
+	if out, err := gen.CombinedOutput(); err != nil {
+		t.Skipf("ffmpeg cannot encode 10-bit test clip: %v: %s", err, out)
+	}
+
+	w, h, err := detectCrop(context.Background(), ffmpegPath, path, 0)
+	if err != nil {
+		t.Fatalf("detectCrop: %v", err)
+	}
+	if w != 320 || h < 130 || h > 138 {
+		t.Errorf("detectCrop = %dx%d, want about 320x134 (bars cropped)", w, h)
+	}
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// rippers maps a signature string found in a rip log to the ripper name.
+var rippers = []struct{ marker, name string }{
+	{"exact audio copy", "EAC"},
+	{"x lossless decoder", "XLD"},
+	{"whipper", "whipper"},
+	{"cueripper", "CUERipper"},
+	{"dbpoweramp", "dBpoweramp"},
+}
+
+var (
+	logTrackRe    = regexp.MustCompile(`(?i)^track\s+\d+\s*$`)
+	logTestCRCRe  = regexp.MustCompile(`(?i)^(?:test crc|crc32 hash \(test run\))\s*:?\s*([0-9a-f]{8})`)
+	logCopyCRCRe  = regexp.MustCompile(`(?i)^(?:copy crc|crc32 hash)\s*:?\s*([0-9a-f]{8})`)
+	logErrCountRe = regexp.MustCompile(`(?i)^(?:read error|skipped \(treated as error\)|damaged sector count|inconsistency in error sectors)\s*:\s*(\d+)`)
+	cueFileRe     = regexp.MustCompile(`(?i)^FILE\s+(?:"([^"]+)"|(\S+))`)
+)
+
+// decodeLogText converts a .cue/.log file to a string. EAC writes its logs
+// as UTF-16LE with a BOM; UTF-16BE and UTF-8 BOMs are handled too.
+func decodeLogText(data []byte) string {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		order = binary.BigEndian
+	default:
+		return string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}))
+	}
+
+	data = data[2:]
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// splitLines splits text into trimmed lines, accepting \r\n and \n endings.
+func splitLines(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	return lines
+}
+
+// cueValue returns the value of a cue command, unquoting it if needed:
+// `PERFORMER "Pink Floyd"` → "Pink Floyd".
+func cueValue(line, cmd string) string {
+	v := strings.TrimSpace(line[len(cmd):])
+	if unq, err := strconv.Unquote(v); err == nil {
+		return unq
+	}
+	return strings.Trim(v, `"`)
+}
+
+// ParseCueSheet parses a .cue file: album performer/title (the ones before
+// the first TRACK), referenced FILE entries and the number of tracks.
+func ParseCueSheet(text string) CueSheet {
+	cue := CueSheet{Files: []string{}, MissingFiles: []string{}}
+	for _, line := range splitLines(text) {
+		upper := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(upper, "PERFORMER ") && cue.Tracks == 0:
+			cue.Performer = cueValue(line, "PERFORMER")
+		case strings.HasPrefix(upper, "TITLE ") && cue.Tracks == 0:
+			cue.Title = cueValue(line, "TITLE")
+		case strings.HasPrefix(upper, "FILE "):
+			if m := cueFileRe.FindStringSubmatch(line); m != nil {
+				cue.Files = append(cue.Files, m[1]+m[2])
+			}
+		case strings.HasPrefix(upper, "TRACK "):
+			cue.Tracks++
+		}
+	}
+	return cue
+}
+
+// missingCueFiles returns the FILE entries of a cue sheet that are not in the
+// torrent. Entries are resolved relative to the cue's directory. A file with
+// the same name but a different audio extension counts as present, since cue
+// sheets written at rip time usually reference the .wav that was later
+// encoded to FLAC.
+func missingCueFiles(cue CueSheet, cuePath string, tf *TorrentFiles) []string {
+	missing := []string{}
+	if tf == nil {
+		return missing
+	}
+
+	exact := make(map[string]bool)
+	stems := make(map[string]bool)
+	for _, f := range tf.AudioFiles {
+		p := strings.ToLower(f.Path)
+		exact[p] = true
+		stems[strings.TrimSuffix(p, path.Ext(p))] = true
+	}
+	for _, f := range tf.OtherFiles {
+		exact[strings.ToLower(f.Path)] = true
+	}
+
+	dir := path.Dir(cuePath)
+	for _, name := range cue.Files {
+		p := strings.ToLower(path.Join(dir, strings.ReplaceAll(name, `\`, "/")))
+		if exact[p] || stems[strings.TrimSuffix(p, path.Ext(p))] {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	return missing
+}
+
+// ParseRipLog parses a CD ripper log (EAC, XLD, whipper, CUERipper,
+// dBpoweramp) and summarizes its verification results. Returns false when
+// the text does not look like a rip log.
+func ParseRipLog(text string) (RipLog, bool) {
+	rl := RipLog{Ripper: "unknown"}
+	lower := strings.ToLower(text)
+	for _, r := range rippers {
+		if strings.Contains(lower, r.marker) {
+			rl.Ripper = r.name
+			break
+		}
+	}
+
+	copyCRCs, crcMatches := 0, 0
+	testCRC := ""
+	for _, line := range splitLines(text) {
+		l := strings.ToLower(line)
+		switch {
+		case logTrackRe.MatchString(line):
+			rl.Tracks++
+		case logTestCRCRe.MatchString(line):
+			testCRC = strings.ToLower(logTestCRCRe.FindStringSubmatch(line)[1])
+		case logCopyCRCRe.MatchString(line):
+			copyCRCs++
+			copyCRC := strings.ToLower(logCopyCRCRe.FindStringSubmatch(line)[1])
+			if testCRC != "" {
+				if testCRC == copyCRC {
+					crcMatches++
+				} else {
+					rl.CRCMismatches++
+				}
+			}
+			testCRC = ""
+		case strings.HasPrefix(l, "all tracks"):
+			// EAC end-of-log summary, already counted per track
+		case strings.Contains(l, "accurately ripped") || strings.Contains(l, "found, exact match"):
+			rl.AccurateRip++
+		case strings.HasPrefix(l, "suspicious position"):
+			rl.ReadErrors++
+		case logErrCountRe.MatchString(line):
+			n, _ := strconv.Atoi(logErrCountRe.FindStringSubmatch(line)[1])
+			rl.ReadErrors += n
+		}
+	}
+
+	if rl.Ripper == "unknown" && rl.Tracks == 0 && copyCRCs == 0 {
+		return rl, false
+	}
+	// whipper logs list tracks as YAML keys; fall back to CRC entries
+	if rl.Tracks == 0 {
+		rl.Tracks = copyCRCs
+	}
+	if rl.Tracks > 0 {
+		rl.AccurateRip = min(rl.AccurateRip, rl.Tracks)
+	}
+
+	rl.ChecksumPresent = strings.Contains(lower, "log checksum") ||
+		strings.Contains(lower, "xld signature") ||
+		strings.Contains(lower, "sha-256 hash")
+
+	switch {
+	case rl.CRCMismatches > 0 || rl.ReadErrors > 0 || strings.Contains(lower, "there were errors"):
+		rl.Status = "errors"
+	case rl.Tracks > 0 && (rl.AccurateRip >= rl.Tracks || crcMatches >= rl.Tracks):
+		rl.Status = "ok"
+	default:
+		rl.Status = "unverified"
+	}
+	return rl, true
+}
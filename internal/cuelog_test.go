@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const testCue = `REM GENRE Rock
+PERFORMER "Pink Floyd"
+TITLE "Animals"
+FILE "Pink Floyd - Animals.wav" WAVE
+  TRACK 01 AUDIO
+    TITLE "Pigs on the Wing 1"
+    PERFORMER "Roger Waters"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Dogs"
+    INDEX 01 01:25:00
+FILE "Bonus.wav" WAVE
+  TRACK 03 AUDIO
+    INDEX 01 00:00:00
+`
+
+func TestParseCueSheet(t *testing.T) {
+	cue := ParseCueSheet(strings.ReplaceAll(testCue, "\n", "\r\n"))
+	if cue.Performer != "Pink Floyd" || cue.Title != "Animals" {
+		t.Errorf("expected album Pink Floyd/Animals, got %q/%q", cue.Performer, cue.Title)
+	}
+	if cue.Tracks != 3 {
+		t.Errorf("expected 3 tracks, got %d", cue.Tracks)
+	}
+	if len(cue.Files) != 2 || cue.Files[0] != "Pink Floyd - Animals.wav" {
+		t.Errorf("unexpected files: %v", cue.Files)
+	}
+}
+
+func TestMissingCueFiles(t *testing.T) {
+	cue := ParseCueSheet(testCue)
+	tf := AnalyzeFiles([]FileInfo{
+		{Path: "Animals/Pink Floyd - Animals.flac", Size: 300_000_000, Ext: ".flac"},
+		{Path: "Animals/Animals.cue", Size: 1000, Ext: ".cue"},
+	})
+
+	missing := missingCueFiles(cue, "Animals/Animals.cue", tf)
+	if len(missing) != 1 || missing[0] != "Bonus.wav" {
+		t.Errorf("expected only Bonus.wav missing (wav→flac counts as present), got %v", missing)
+	}
+}
+
+const testEACLog = `Exact Audio Copy V1.6 from 23. October 2020
+
+Track  1
+
+     Filename C:\Rips\01 - Pigs.wav
+
+     Peak level 100.0 %
+     Test CRC 1A2B3C4D
+     Copy CRC 1A2B3C4D
+     Accurately ripped (confidence 12)  [ABCDEF01]  (AR v2)
+     Copy OK
+
+Track  2
+
+     Filename C:\Rips\02 - Dogs.wav
+
+     Suspicious position 0:02:20
+     Test CRC 11111111
+     Copy CRC 22222222
+     Copy finished
+
+There were errors
+
+==== Log checksum 0123456789ABCDEF ====
+`
+
+func TestParseRipLog_EACErrors(t *testing.T) {
+	rl, ok := ParseRipLog(testEACLog)
+	if !ok {
+		t.Fatal("expected EAC log to be recognized")
+	}
+	if rl.Ripper != "EAC" || rl.Tracks != 2 {
+		t.Errorf("expected EAC with 2 tracks, got %s with %d", rl.Ripper, rl.Tracks)
+	}
+	if rl.AccurateRip != 1 || rl.CRCMismatches != 1 || rl.ReadErrors != 1 {
+		t.Errorf("unexpected counts: ar=%d crc=%d err=%d", rl.AccurateRip, rl.CRCMismatches, rl.ReadErrors)
+	}
+	if !rl.ChecksumPresent {
+		t.Error("expected log checksum to be detected")
+	}
+	if rl.Status != "errors" {
+		t.Errorf("expected status errors, got %s", rl.Status)
+	}
+}
+
+func TestParseRipLog_XLDOk(t *testing.T) {
+	log := `X Lossless Decoder version 20230627 (155.3)
+
+Track 01
+    CRC32 hash (test run)  : 0A0B0C0D
+    CRC32 hash             : 0A0B0C0D
+    CRC32 hash (skip zero) : 99999999
+    AccurateRip v2 signature : 12345678
+        ->Accurately ripped (v2, confidence 5/5)
+    Statistics
+        Read error                           : 0
+        Skipped (treated as error)           : 0
+        Damaged sector count                 : 0
+
+Track 02
+    CRC32 hash (test run)  : 01020304
+    CRC32 hash             : 01020304
+        ->Accurately ripped (v1+v2, confidence 3+4/7)
+        Read error                           : 0
+
+No errors occurred
+
+-----BEGIN XLD SIGNATURE-----
+abc
+-----END XLD SIGNATURE-----
+`
+	rl, ok := ParseRipLog(log)
+	if !ok {
+		t.Fatal("expected XLD log to be recognized")
+	}
+	if rl.Ripper != "XLD" || rl.Tracks != 2 || rl.AccurateRip != 2 {
+		t.Errorf("expected XLD 2 tracks 2 AR, got %s %d %d", rl.Ripper, rl.Tracks, rl.AccurateRip)
+	}
+	if rl.CRCMismatches != 0 || rl.ReadErrors != 0 {
+		t.Errorf("expected no errors, got crc=%d err=%d", rl.CRCMismatches, rl.ReadErrors)
+	}
+	if !rl.ChecksumPresent || rl.Status != "ok" {
+		t.Errorf("expected ok with checksum, got %s checksum=%v", rl.Status, rl.ChecksumPresent)
+	}
+}
+
+func TestParseRipLog_NotARipLog(t *testing.T) {
+	if _, ok := ParseRipLog("Downloaded from example.org\nEnjoy!\n"); ok {
+		t.Error("expected plain text log to be rejected")
+	}
+}
+
+func TestDecodeLogText_UTF16LE(t *testing.T) {
+	text := "Exact Audio Copy V1.6\r\nTrack  1\r\n"
+	u := utf16.Encode([]rune(text))
+	data := []byte{0xFF, 0xFE}
+	for _, c := range u {
+		data = binary.LittleEndian.AppendUint16(data, c)
+	}
+
+	if got := decodeLogText(data); got != text {
+		t.Errorf("decodeLogText = %q, want %q", got, text)
+	}
+}
+
+func TestDecodeLogText_UTF8BOM(t *testing.T) {
+	if got := decodeLogText([]byte("\xEF\xBB\xBFPERFORMER \"X\"")); got != `PERFORMER "X"` {
+		t.Errorf("BOM not stripped: %q", got)
+	}
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/anacrolix/dht/v2"
+	"github.com/anacrolix/dht/v2/krpc"
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	dhtDefaultPort = "6881"
+	// dhtMinSavedNodes keeps a short-lived client that barely bootstrapped
+	// from overwriting a good routing table.
+	dhtMinSavedNodes = 8
+)
+
+// hasHTTPTracker reports whether any tracker is announced to over HTTP(S),
+// the only kind reachable through a proxy.
+func hasHTTPTracker(trackers []string) bool {
+	for _, tr := range trackers {
+		if u, err := url.Parse(tr); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveTrackers returns the announce list used for magnets: the default
+// public trackers followed by the extra ones (from flags or config, then
+// one per line from file, # comments allowed), or only the extra ones when
+// replace is set. Duplicates are dropped.
+func ResolveTrackers(extra []string, file string, replace bool) ([]string, error) {
+	var list []string
+	if !replace {
+		list = append(list, defaultTrackers...)
+	}
+	list = append(list, extra...)
+	if file != "" {
+		fromFile, err := readTrackersFile(file)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, fromFile...)
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, tr := range list {
+		tr = strings.TrimSpace(tr)
+		if tr == "" || seen[tr] {
+			continue
+		}
+		if err := validTrackerURL(tr); err != nil {
+			return nil, err
+		}
+		seen[tr] = true
+		out = append(out, tr)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("replacing the default trackers needs at least one tracker")
+	}
+	return out, nil
+}
+
+// readTrackersFile reads announce URLs, one per line. Blank lines and lines
+// starting with # are skipped.
+func readTrackersFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open trackers file: %w", err)
+	}
+	defer f.Close()
+
+	var trackers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		trackers = append(trackers, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read trackers file: %w", err)
+	}
+	return trackers, nil
+}
+
+// validTrackerURL checks that an announce URL has a supported scheme and a host.
+func validTrackerURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid tracker %q: %w", s, err)
+	}
+	switch u.Scheme {
+	case "udp", "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("invalid tracker %q: scheme must be udp, http, https, ws or wss", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid tracker %q: no host", s)
+	}
+	return nil
+}
+
+// NormalizeDHTNodes validates DHT bootstrap nodes given as host:port. A
+// missing port defaults to 6881.
+func NormalizeDHTNodes(nodes []string) ([]string, error) {
+	var out []string
+	for _, n := range nodes {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(n)
+		if err != nil {
+			host, port = strings.Trim(n, "[]"), dhtDefaultPort
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 || host == "" || strings.ContainsAny(host, "/ ") {
+			return nil, fmt.Errorf("invalid DHT node %q (want host:port)", n)
+		}
+		out = append(out, net.JoinHostPort(host, port))
+	}
+	return out, nil
+}
+
+// SplitList splits a comma- or whitespace-separated list from an env var.
+func SplitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// dhtStartingNodes returns a bootstrap function for the given nodes.
+func dhtStartingNodes(nodes []string) func(string) dht.StartingNodesGetter {
+	return func(string) dht.StartingNodesGetter {
+		return func() ([]dht.Addr, error) { return dht.ResolveHostPorts(nodes) }
+	}
+}
+
+// loadDHTNodes seeds the client's DHT servers with the routing table saved
+// by a previous run. Returns the number of nodes added.
+func loadDHTNodes(client *torrent.Client, path string) int {
+	nodes, err := dht.ReadNodesFromFile(path)
+	if err != nil {
+		return 0
+	}
+	added := 0
+	for _, s := range client.DhtServers() {
+		for _, n := range nodes {
+			if s.AddNode(n) == nil {
+				added++
+			}
+		}
+	}
+	return added
+}
+
+// saveDHTNodes writes the routing table of the client's DHT servers so the
+// next run skips bootstrapping. The file is replaced atomically since
+// concurrent workers share it.
+func saveDHTNodes(client *torrent.Client, path string) error {
+	var nodes []krpc.NodeInfo
+	for _, s := range client.DhtServers() {
+		if w, ok := s.(torrent.AnacrolixDhtServerWrapper); ok {
+			nodes = append(nodes, w.Nodes()...)
+		}
+	}
+	if len(nodes) < dhtMinSavedNodes {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".dht-nodes-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := dht.WriteNodesToFile(nodes, tmp.Name()); err != nil {
+		return err
+	}
+	return atomicRename(tmp.Name(), path)
+}
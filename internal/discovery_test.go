@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/dht/v2"
+	"github.com/anacrolix/dht/v2/krpc"
+)
+
+func TestResolveTrackers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trackers.txt")
+	os.WriteFile(file, []byte("# local\nhttp://127.0.0.1:8000/announce\n\nudp://tracker.example:6969/announce\n"), 0o644)
+
+	got, err := ResolveTrackers([]string{"udp://tracker.example:6969/announce"}, file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(defaultTrackers)+2 {
+		t.Errorf("expected defaults plus 2 deduplicated trackers, got %v", got)
+	}
+	if got[0] != defaultTrackers[0] {
+		t.Errorf("defaults should come first, got %q", got[0])
+	}
+
+	got, err = ResolveTrackers(nil, file, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "http://127.0.0.1:8000/announce" {
+		t.Errorf("replace should keep only the file's trackers, got %v", got)
+	}
+
+	if _, err := ResolveTrackers(nil, "", true); err == nil {
+		t.Error("expected error when replacing with no trackers")
+	}
+	if _, err := ResolveTrackers([]string{"ftp://tracker.example/announce"}, "", false); err == nil {
+		t.Error("expected error for an unsupported scheme")
+	}
+	if _, err := ResolveTrackers(nil, filepath.Join(t.TempDir(), "missing.txt"), false); err == nil {
+		t.Error("expected error for a missing trackers file")
+	}
+}
+
+func TestResolvePeerDiscovery_Proxy(t *testing.T) {
+	cfg := Config{Proxy: "socks5://127.0.0.1:1080"}
+	if err := cfg.ResolvePeerDiscovery(); err == nil {
+		t.Error("expected error: the default trackers are all UDP, unusable through a proxy")
+	}
+
+	cfg.ExtraTrackers = []string{"https://tracker.example/announce"}
+	if err := cfg.ResolvePeerDiscovery(); err != nil {
+		t.Errorf("an HTTPS tracker should be enough through a proxy: %v", err)
+	}
+
+	direct := Config{}
+	if err := direct.ResolvePeerDiscovery(); err != nil {
+		t.Errorf("without a proxy UDP trackers are fine: %v", err)
+	}
+}
+
+func TestNormalizeDHTNodes(t *testing.T) {
+	got, err := NormalizeDHTNodes([]string{"router.example:6881", "dht.example", " 10.0.0.1:7000 ", "::1", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"router.example:6881", "dht.example:6881", "10.0.0.1:7000", "[::1]:6881"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("NormalizeDHTNodes = %v, want %v", got, want)
+	}
+	if _, err := NormalizeDHTNodes([]string{"http://router.example/"}); err == nil {
+		t.Error("expected error for a URL")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := SplitList("udp://a:1/announce, http://b/announce\nwss://c")
+	if len(got) != 3 || got[1] != "http://b/announce" {
+		t.Errorf("SplitList = %v", got)
+	}
+	if len(SplitList("")) != 0 {
+		t.Error("empty list should have no entries")
+	}
+}
+
+func TestBuildMagnet_Trackers(t *testing.T) {
+	hash := strings.Repeat("ab", 20)
+	m := buildMagnet(hash, []string{"http://127.0.0.1:8000/announce"})
+	if m != "magnet:?xt=urn:btih:"+hash+"&tr=http%3A%2F%2F127.0.0.1%3A8000%2Fannounce" {
+		t.Errorf("unexpected magnet %s", m)
+	}
+}
+
+func TestDHTStatePersistence(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "dht_nodes.dat")
+	var nodes []krpc.NodeInfo
+	for i := 1; i <= dhtMinSavedNodes+2; i++ {
+		var id [20]byte
+		id[0], id[19] = byte(i), byte(i*7)
+		nodes = append(nodes, krpc.NodeInfo{
+			ID:   id,
+			Addr: krpc.NodeAddr{IP: net.IPv4(10, 0, 0, byte(i)).To4(), Port: 6881},
+		})
+	}
+	if err := dht.WriteNodesToFile(nodes, state); err != nil {
+		t.Fatal(err)
+	}
+
+	dl, err := NewDownloader(DownloadConfig{
+		TempDir:      t.TempDir(),
+		BindAddress:  "127.0.0.1",
+		DHTNodes:     []string{"127.0.0.1:1"},
+		DHTStateFile: state,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := loadDHTNodes(dl.client, state); n == 0 {
+		t.Error("expected the saved nodes to be added to the routing table")
+	}
+	os.Remove(state)
+	dl.Close()
+
+	saved, err := dht.ReadNodesFromFile(state)
+	if err != nil {
+		t.Fatalf("routing table not saved on Close: %v", err)
+	}
+	if len(saved) < dhtMinSavedNodes {
+		t.Errorf("expected at least %d saved nodes, got %d", dhtMinSavedNodes, len(saved))
+	}
+}
@@ -2,18 +2,24 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	alog "github.com/anacrolix/log"
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
+	infohash_v2 "github.com/anacrolix/torrent/types/infohash-v2"
+	"golang.org/x/net/proxy"
 )
 
 // Public trackers for magnet resolution.
@@ -25,17 +31,6 @@ var defaultTrackers = []string{
 	"udp://exodus.desync.com:6969/announce",
 }
 
-var videoExtensions = map[string]bool{
-	".mkv": true, ".mp4": true, ".avi": true,
-	".m4v": true, ".wmv": true, ".ts": true,
-	".mov": true,
-}
-
-// mp4Extensions are formats where the moov atom may be at the end of the file.
-var mp4Extensions = map[string]bool{
-	".mp4": true, ".m4v": true, ".mov": true,
-}
-
 // DownloadConfig holds settings for the BitTorrent downloader.
 type DownloadConfig struct {
 	TempDir      string
@@ -43,19 +38,54 @@ type DownloadConfig struct {
 	MaxTimeout   time.Duration
 	MinBytesMKV  int
 	MinBytesMP4  int
+	MemoryCap    int64 // >0: keep pieces in memory up to this many bytes instead of on disk
+
+	// Resource limits (0 = unlimited / client default)
+	DownloadRate int // bytes per second
+	UploadRate   int // bytes per second
+	MaxConns     int // established peer connections per torrent
+	MaxHalfOpen  int // half-open (dialing) connections
+
+	// DiskReserve grows a torrent's share of a disk budget to need bytes
+	// before more of it is written to TempDir (nil = unlimited).
+	DiskReserve func(ctx context.Context, infoHash string, need int64) error
+
+	Proxy string // socks5:// or http:// proxy for peer and tracker connections
+
+	// Network binding (zero values = library defaults)
+	BindAddress string // IP or interface name to listen and dial from
+	PortMin     int    // listen port range; 0 = random port
+	PortMax     int
+	DisableIPv6 bool
+	DisableUTP  bool
+	DisableTCP  bool
+	Encryption  string // "", "prefer", "require" or "off"
+
+	// Peer discovery
+	Trackers     []string // announce list for magnets; nil = defaultTrackers
+	DHTNodes     []string // DHT bootstrap nodes (host:port); nil = library defaults
+	DHTStateFile string   // routing table loaded at start and saved on Close ("" = none)
+	Blocklist    string   // P2P or DAT peer blocklist file, optionally gzipped ("" = none)
 }
 
 // Downloader manages a BitTorrent client for partial torrent downloads.
 type Downloader struct {
 	client *torrent.Client
 	cfg    DownloadConfig
+
+	// In-memory mode: pieces live in mem and files are read through memSrv
+	mem    *memoryStorage
+	memSrv *memoryServer
+
+	blocklist *Blocklist // nil when no blocklist is set
 }
 
 // DownloadResult holds the outcome of a partial download.
 type DownloadResult struct {
-	FilePath string
-	FileName string
-	Ext      string
+	FilePath    string
+	FileName    string
+	TorrentPath string // path of the video file within the torrent
+	Ext         string
 }
 
 // NewDownloader creates a new BitTorrent downloader.
@@ -76,21 +106,120 @@ func NewDownloader(cfg DownloadConfig) (*Downloader, error) {
 	tcfg.DataDir = cfg.TempDir
 	tcfg.Seed = false
 	tcfg.NoUpload = true
-	tcfg.ListenPort = 0 // random port
 	tcfg.Logger = alog.Default.FilterLevel(alog.Disabled)
+	if l := newRateLimiter(cfg.DownloadRate); l != nil {
+		tcfg.DownloadRateLimiter = l
+	}
+	if l := newRateLimiter(cfg.UploadRate); l != nil {
+		tcfg.UploadRateLimiter = l
+	}
+	if cfg.MaxConns > 0 {
+		tcfg.EstablishedConnsPerTorrent = cfg.MaxConns
+	}
+	if cfg.MaxHalfOpen > 0 {
+		tcfg.HalfOpenConnsPerTorrent = cfg.MaxHalfOpen
+		tcfg.TotalHalfOpenConns = cfg.MaxHalfOpen
+	}
 
-	client, err := torrent.NewClient(tcfg)
+	if cfg.DisableUTP && cfg.DisableTCP {
+		return nil, errors.New("uTP and TCP cannot both be disabled")
+	}
+	if cfg.Proxy != "" && cfg.DisableTCP {
+		return nil, errors.New("TCP cannot be disabled behind a proxy: it is the only transport that goes through it")
+	}
+	tcfg.DisableIPv6 = cfg.DisableIPv6
+	tcfg.DisableUTP = cfg.DisableUTP
+	tcfg.DisableTCP = cfg.DisableTCP
+	if err := applyEncryption(tcfg, cfg.Encryption); err != nil {
+		return nil, err
+	}
+	bindIP, err := ResolveBindAddress(cfg.BindAddress, cfg.DisableIPv6)
 	if err != nil {
+		return nil, err
+	}
+
+	var peerDialer proxy.ContextDialer
+	var boundDialer *net.Dialer
+	if cfg.Proxy != "" {
+		u, err := parseProxy(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		forward := &net.Dialer{Timeout: proxyDialTimeout}
+		if bindIP != nil {
+			forward.LocalAddr = &net.TCPAddr{IP: bindIP}
+		}
+		if peerDialer, err = proxyDialer(u, forward); err != nil {
+			return nil, err
+		}
+		// Only outgoing TCP goes through the proxy: no inbound peers, no
+		// uTP, DHT, UDP trackers or WebRTC, which would leak past it
+		tcfg.DialForPeerConns = false
+		tcfg.AcceptPeerConnections = false
+		tcfg.DisableUTP = true
+		tcfg.NoDHT = true
+		tcfg.DisableWebtorrent = true
+		tcfg.NoDefaultPortForwarding = true
+		tcfg.TrackerDialContext = peerDialer.DialContext
+		tcfg.TrackerListenPacket = func(string, string) (net.PacketConn, error) { return nil, errUDPOverProxy }
+		tcfg.HTTPDialContext = peerDialer.DialContext
+	} else if bindIP != nil {
+		boundDialer = bindClientConfig(tcfg, bindIP)
+	}
+	if len(cfg.DHTNodes) > 0 {
+		tcfg.DhtStartingNodes = dhtStartingNodes(cfg.DHTNodes)
+	}
+
+	d := &Downloader{cfg: cfg}
+	if d.blocklist, err = LoadBlocklist(cfg.Blocklist); err != nil {
+		return nil, err
+	}
+	if d.blocklist != nil {
+		tcfg.IPBlocklist = d.blocklist
+	}
+	if cfg.MemoryCap > 0 {
+		d.mem = newMemoryStorage(cfg.MemoryCap)
+		srv, err := startMemoryServer(d.mem)
+		if err != nil {
+			return nil, err
+		}
+		d.memSrv = srv
+		tcfg.DefaultStorage = d.mem
+	}
+
+	// With a port range, try its ports until one is free: concurrent
+	// workers share the range. Any other error fails at once.
+	var client *torrent.Client
+	for _, port := range listenPorts(cfg.PortMin, cfg.PortMax) {
+		tcfg.ListenPort = port
+		client, err = torrent.NewClient(tcfg)
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			break
+		}
+	}
+	if err != nil {
+		if d.memSrv != nil {
+			d.memSrv.Close()
+		}
 		return nil, fmt.Errorf("create torrent client: %w", err)
 	}
+	if peerDialer != nil {
+		client.AddDialer(torrent.NetworkDialer{Network: "tcp", Dialer: peerDialer})
+	} else if boundDialer != nil {
+		bindPeerDialers(client, boundDialer, bindIP, cfg.DisableTCP)
+	}
+	if cfg.DHTStateFile != "" {
+		loadDHTNodes(client, cfg.DHTStateFile)
+	}
+	d.client = client
 
-	return &Downloader{client: client, cfg: cfg}, nil
+	return d, nil
 }
 
 // GetTorrentStats returns the download and upload bytes for a specific torrent.
 // Returns (0, 0) if the torrent is not found or the handle is stale.
 func (d *Downloader) GetTorrentStats(infoHash string) (downloaded, uploaded int64) {
-	hash := metainfo.NewHashFromHex(infoHash)
+	hash := torrentKey(infoHash)
 	t, ok := d.client.Torrent(hash)
 	if !ok {
 		return 0, 0
@@ -106,11 +235,42 @@ func (d *Downloader) GetTorrentStats(infoHash string) (downloaded, uploaded int6
 	return stats.ConnStats.BytesReadData.Int64(), stats.ConnStats.BytesWrittenData.Int64()
 }
 
+// InfoHashes returns the v1 and v2 info hashes of a torrent as hex ("" for a
+// version it lacks). Once metadata is resolved they come from the info
+// dictionary, so hybrid torrents report both; before that, or if the torrent
+// is not found, from infoHash alone.
+func (d *Downloader) InfoHashes(infoHash string) (v1, v2 string) {
+	v1, v2 = hashVersions(infoHash)
+	t, ok := d.client.Torrent(torrentKey(infoHash))
+	if !ok {
+		return v1, v2
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			v1, v2 = hashVersions(infoHash)
+		}
+	}()
+	info := t.Info()
+	if info == nil {
+		return v1, v2
+	}
+	mi := t.Metainfo()
+	v1, v2 = "", ""
+	if info.HasV1() {
+		v1 = mi.HashInfoBytes().HexString()
+	}
+	if info.HasV2() {
+		h := infohash_v2.HashBytes(mi.InfoBytes)
+		v2 = h.HexString()
+	}
+	return v1, v2
+}
+
 // GetFileList extracts the complete file listing from a torrent's metadata.
 // Must be called after metadata has been resolved (after PartialDownload).
 // Returns nil if the torrent is not found or the handle is stale.
 func (d *Downloader) GetFileList(infoHash string) (result []FileInfo) {
-	hash := metainfo.NewHashFromHex(infoHash)
+	hash := torrentKey(infoHash)
 	t, ok := d.client.Torrent(hash)
 	if !ok {
 		return nil
@@ -144,7 +304,7 @@ func (d *Downloader) GetFileList(infoHash string) (result []FileInfo) {
 // Must be called while the torrent is still active (before Cleanup).
 // Returns nil if the torrent is not found or the handle is stale.
 func (d *Downloader) GetSwarmInfo(infoHash string) (result *SwarmInfo) {
-	hash := metainfo.NewHashFromHex(infoHash)
+	hash := torrentKey(infoHash)
 	t, ok := d.client.Torrent(hash)
 	if !ok {
 		return nil
@@ -174,17 +334,27 @@ func (d *Downloader) GetSwarmInfo(infoHash string) (result *SwarmInfo) {
 		Seeds:              seeds,
 		DownloadBytesTotal: stats.ConnStats.BytesReadData.Int64(),
 		UploadBytesTotal:   stats.ConnStats.BytesWrittenData.Int64(),
+		ClientBlockedPeers: d.blocklist.BlockedFor(infoHash),
 	}
 }
 
-// PartialDownload downloads the first (and last for MP4) bytes of the largest video file.
+// PartialDownload downloads the parts of the largest video file that ffprobe needs.
 // Returns the download result with file path and metadata.
-// The minBytes parameter controls how many bytes from the start to download.
-// For MP4 files, it also downloads the last minBytes to catch the moov atom.
-func (d *Downloader) PartialDownload(ctx context.Context, infoHash string, minBytes int) (*DownloadResult, error) {
-	magnet := buildMagnet(infoHash)
+// The container strategy for the file's extension picks the byte ranges: headers
+// are parsed where a layout parser exists (MKV/WebM, MP4/MOV, AVI, TS, WMV/ASF).
+// Otherwise (or when parsing fails) the first minBytes are downloaded, plus the
+// end and spread probes the strategy asks for. With analyze set (ffmpeg is
+// available), a parsed layout also fetches minutes of gap-free media after
+// the headers for the decoding analyzers.
+func (d *Downloader) PartialDownload(ctx context.Context, infoHash string, minBytes int, analyze bool) (*DownloadResult, error) {
+	trackers := d.cfg.Trackers
+	if trackers == nil {
+		trackers = defaultTrackers
+	}
+	magnet := buildMagnet(infoHash, trackers)
 
-	t, err := d.client.AddMagnet(magnet)
+	d.blocklist.Track(infoHash)
+	t, err := addMagnet(d.client, magnet)
 	if err != nil {
 		return nil, fmt.Errorf("add magnet: %w", err)
 	}
@@ -210,44 +380,24 @@ func (d *Downloader) PartialDownload(ctx context.Context, infoHash string, minBy
 
 	log.Printf("  [%s] found video: %s (%d MB, %s)", TruncHash(infoHash), videoFile.DisplayPath(), videoFile.Length()/1024/1024, ext)
 
-	// Calculate required pieces
-	pieceLength := int(t.Info().PieceLength)
-	fileStartPiece := videoFile.BeginPieceIndex()
-	fileEndPiece := videoFile.EndPieceIndex() // exclusive
-
-	// Pieces from the start of the file
-	startPiecesNeeded := (minBytes + pieceLength - 1) / pieceLength
-	startEnd := fileStartPiece + startPiecesNeeded
-	if startEnd > fileEndPiece {
-		startEnd = fileEndPiece
-	}
-
-	// Build list of required piece indices
-	required := make(map[int]bool)
-	for i := fileStartPiece; i < startEnd; i++ {
-		required[i] = true
-	}
-
-	// For MP4/M4V: also download pieces from the END of the file (moov atom)
-	if mp4Extensions[ext] {
-		endPiecesNeeded := (minBytes + pieceLength - 1) / pieceLength
-		endStart := fileEndPiece - endPiecesNeeded
-		if endStart < startEnd {
-			endStart = startEnd // avoid overlap
-		}
-		for i := endStart; i < fileEndPiece; i++ {
-			required[i] = true
+	// Calculate required pieces: parse the container headers to fetch
+	// exactly the byte ranges ffprobe needs, or fall back to fixed-size
+	// ranges at the start (and end, for MP4)
+	container := containerFor(ext)
+	required, planned := d.plannedPieces(ctx, t, videoFile, infoHash, container, minBytes, analyze)
+	if !planned {
+		required = fallbackPieces(t, videoFile, minBytes, container)
+		if container.Tail {
+			log.Printf("  [%s] %s detected: also requesting end pieces", TruncHash(infoHash), container.Name)
 		}
-		log.Printf("  [%s] MP4 detected: also requesting last %d pieces (moov atom)",
-			TruncHash(infoHash), fileEndPiece-endStart)
 	}
 
-	log.Printf("  [%s] need %d pieces (%dKB each) for %dKB",
-		TruncHash(infoHash), len(required), pieceLength/1024, minBytes/1024)
+	log.Printf("  [%s] need %d pieces (%dKB each)",
+		TruncHash(infoHash), len(required), t.Info().PieceLength/1024)
 
 	// Set priority on required pieces
-	for i := range required {
-		t.Piece(i).SetPriority(torrent.PiecePriorityNow)
+	if err := d.requestPieces(ctx, t, infoHash, required); err != nil {
+		return nil, err
 	}
 
 	// Poll for piece completion with stall detection
@@ -262,17 +412,152 @@ func (d *Downloader) PartialDownload(ctx context.Context, infoHash string, minBy
 	}
 
 	return &DownloadResult{
-		FilePath: filePath,
-		FileName: filepath.Base(videoFile.DisplayPath()),
-		Ext:      ext,
+		FilePath:    filePath,
+		FileName:    filepath.Base(videoFile.DisplayPath()),
+		TorrentPath: videoFile.DisplayPath(),
+		Ext:         ext,
 	}, nil
 }
 
+// piecesForRanges returns the pieces covering byte ranges of a file.
+func piecesForRanges(t *torrent.Torrent, file *torrent.File, ranges []byteRange) map[int]bool {
+	pieceLength := t.Info().PieceLength
+	required := make(map[int]bool)
+	for _, r := range ranges {
+		if r.End <= r.Start {
+			continue
+		}
+		first := int((file.Offset() + r.Start) / pieceLength)
+		last := int((file.Offset() + r.End - 1) / pieceLength)
+		for i := first; i <= last; i++ {
+			required[i] = true
+		}
+	}
+	return required
+}
+
+// plannedPieces parses the container headers of the video file (fetching
+// only the pieces that hold them) and returns the pieces covering the byte
+// ranges ffprobe needs, and with analyze set the head the ffmpeg analyzers
+// read (see planRanges). Returns false when the container has no parser or
+// its headers can't be parsed, so the caller falls back to fixed ranges.
+func (d *Downloader) plannedPieces(ctx context.Context, t *torrent.Torrent, file *torrent.File, infoHash string, container containerStrategy, minBytes int, analyze bool) (map[int]bool, bool) {
+	if container.Layout == nil {
+		return nil, false
+	}
+	r := &torrentFileReader{ctx: ctx, d: d, t: t, file: file, infoHash: infoHash}
+	defer r.Close()
+
+	// In memory the sample must leave room for the headers and index
+	// elements under the cap.
+	var maxSample int64
+	if d.mem != nil && d.cfg.MemoryCap > 0 {
+		maxSample = d.cfg.MemoryCap / 2
+	}
+	ranges, err := planRanges(r, file.Length(), container, minBytes, analyze, maxSample)
+	if err != nil {
+		log.Printf("  [%s] %s parse failed, using fixed ranges: %v", TruncHash(infoHash), container.Name, err)
+		return nil, false
+	}
+
+	var total int64
+	for _, br := range ranges {
+		total += br.End - br.Start
+	}
+	log.Printf("  [%s] %s layout: %d byte range(s), %dKB", TruncHash(infoHash), container.Name, len(ranges), total/1024)
+	return piecesForRanges(t, file, ranges), true
+}
+
+// torrentFileReader reads a torrent file from disk, first downloading the
+// pieces that cover each read. Container parsers use it to follow headers
+// through a file without fetching the data in between.
+type torrentFileReader struct {
+	ctx      context.Context
+	d        *Downloader
+	t        *torrent.Torrent
+	file     *torrent.File
+	infoHash string
+	local    interface {
+		io.ReaderAt
+		io.Closer
+	}
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *torrentFileReader) ReadAt(p []byte, off int64) (int, error) {
+	size := r.file.Length()
+	if off >= size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), size)
+
+	required := piecesForRanges(r.t, r.file, []byteRange{{off, end}})
+	for i := range required {
+		if r.t.Piece(i).State().Complete {
+			delete(required, i)
+		}
+	}
+	if len(required) > 0 {
+		if err := r.d.requestPieces(r.ctx, r.t, r.infoHash, required); err != nil {
+			return 0, err
+		}
+		if err := r.d.waitForPieces(r.ctx, r.t, r.infoHash, required); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.local == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.local.ReadAt(p[:end-off], off)
+	if err == nil && end < off+int64(len(p)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// open opens the file in memory or on disk.
+func (r *torrentFileReader) open() error {
+	if r.d.mem != nil {
+		mt, ok := r.d.mem.torrent(r.t.InfoHash())
+		if !ok {
+			return fmt.Errorf("torrent %s not in memory storage", TruncHash(r.infoHash))
+		}
+		r.local = memoryFile{io.NewSectionReader(mt, r.file.Offset(), r.file.Length())}
+		return nil
+	}
+	path, err := r.d.resolveFilePath(r.t, r.file, r.infoHash)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	r.local = f
+	return nil
+}
+
+// Close closes the local file, if it was opened.
+func (r *torrentFileReader) Close() error {
+	if r.local == nil {
+		return nil
+	}
+	return r.local.Close()
+}
+
 // resolveFilePath locates the downloaded video file on disk.
 // anacrolix/torrent stores files under DataDir using the torrent name and file path,
 // but the exact layout varies (single-file vs multi-file, wrapper dirs, .part suffix).
 // This method tries multiple candidate paths and falls back to a recursive walk.
+// In-memory mode has no such guesswork: the file's loopback URL is returned.
 func (d *Downloader) resolveFilePath(t *torrent.Torrent, videoFile *torrent.File, infoHash string) (string, error) {
+	if d.memSrv != nil {
+		return d.memSrv.fileURL(t, videoFile), nil
+	}
+
 	tName := t.Name()
 	vPath := videoFile.Path()
 	vDisplay := videoFile.DisplayPath()
@@ -393,7 +678,7 @@ func (d *Downloader) logFileNotFound(infoHash, tName, vPath, vDisplay string) {
 // RequestMorePieces requests additional pieces for a torrent that's already active.
 // Used for ffprobe retry — instead of re-downloading, just request more bytes.
 func (d *Downloader) RequestMorePieces(ctx context.Context, infoHash string, minBytes int) error {
-	hash := metainfo.NewHashFromHex(infoHash)
+	hash := torrentKey(infoHash)
 	t, ok := d.client.Torrent(hash)
 	if !ok {
 		return fmt.Errorf("torrent %s not found in client", TruncHash(infoHash))
@@ -405,41 +690,36 @@ func (d *Downloader) RequestMorePieces(ctx context.Context, infoHash string, min
 	}
 
 	ext := strings.ToLower(filepath.Ext(videoFile.DisplayPath()))
-	pieceLength := int(t.Info().PieceLength)
-	fileStartPiece := videoFile.BeginPieceIndex()
-	fileEndPiece := videoFile.EndPieceIndex()
+	required := fallbackPieces(t, videoFile, minBytes, containerFor(ext))
 
-	startPiecesNeeded := (minBytes + pieceLength - 1) / pieceLength
-	startEnd := fileStartPiece + startPiecesNeeded
-	if startEnd > fileEndPiece {
-		startEnd = fileEndPiece
-	}
+	log.Printf("  [%s] requesting %d more pieces for %dKB retry",
+		TruncHash(infoHash), len(required), minBytes/1024)
 
-	required := make(map[int]bool)
-	for i := fileStartPiece; i < startEnd; i++ {
-		required[i] = true
+	if err := d.requestPieces(ctx, t, infoHash, required); err != nil {
+		return err
 	}
 
-	// Also request end pieces for MP4
-	if mp4Extensions[ext] {
-		endPiecesNeeded := (minBytes + pieceLength - 1) / pieceLength
-		endStart := fileEndPiece - endPiecesNeeded
-		if endStart < startEnd {
-			endStart = startEnd
+	return d.waitForPieces(ctx, t, infoHash, required)
+}
+
+// requestPieces sets the required pieces to download now, after reserving
+// the disk space the torrent takes once they are written.
+func (d *Downloader) requestPieces(ctx context.Context, t *torrent.Torrent, infoHash string, required map[int]bool) error {
+	if d.cfg.DiskReserve != nil && d.mem == nil {
+		need := t.BytesCompleted()
+		for i := range required {
+			if !t.Piece(i).State().Complete {
+				need += t.Info().Piece(i).Length()
+			}
 		}
-		for i := endStart; i < fileEndPiece; i++ {
-			required[i] = true
+		if err := d.cfg.DiskReserve(ctx, infoHash, need); err != nil {
+			return err
 		}
 	}
-
-	log.Printf("  [%s] requesting %d more pieces for %dKB retry",
-		TruncHash(infoHash), len(required), minBytes/1024)
-
 	for i := range required {
 		t.Piece(i).SetPriority(torrent.PiecePriorityNow)
 	}
-
-	return d.waitForPieces(ctx, t, infoHash, required)
+	return nil
 }
 
 // waitForPieces polls until all required pieces are complete or a timeout/stall occurs.
@@ -461,6 +741,9 @@ func (d *Downloader) waitForPieces(ctx context.Context, t *torrent.Torrent, info
 		case <-deadline:
 			return fmt.Errorf("max timeout (%s) for %s", d.cfg.MaxTimeout, TruncHash(infoHash))
 		case <-ticker.C:
+			if d.mem != nil && d.mem.capReached(t.InfoHash()) {
+				return fmt.Errorf("memory cap (%dMB) reached for %s", d.cfg.MemoryCap/1024/1024, TruncHash(infoHash))
+			}
 			// Check piece completion
 			allComplete := true
 			completed := 0
@@ -512,8 +795,9 @@ func (d *Downloader) waitForPieces(ctx context.Context, t *torrent.Torrent, info
 // Cleanup removes a torrent and its downloaded files.
 func (d *Downloader) Cleanup(infoHash string) {
 	defer func() { recover() }()
+	d.blocklist.Untrack(infoHash)
 
-	hash := metainfo.NewHashFromHex(infoHash)
+	hash := torrentKey(infoHash)
 	if t, ok := d.client.Torrent(hash); ok {
 		name := t.Name()
 		t.Drop()
@@ -526,9 +810,10 @@ func (d *Downloader) Cleanup(infoHash string) {
 }
 
 // FindLocalFile tries to locate a torrent file on disk in the temp directory.
-// Returns the local path if found, or empty string if not.
+// Returns the local path if found (the loopback URL in in-memory mode), or
+// empty string if not.
 func (d *Downloader) FindLocalFile(infoHash string, filePath string) (result string) {
-	hash := metainfo.NewHashFromHex(infoHash)
+	hash := torrentKey(infoHash)
 	t, ok := d.client.Torrent(hash)
 	if !ok {
 		return ""
@@ -540,6 +825,15 @@ func (d *Downloader) FindLocalFile(infoHash string, filePath string) (result str
 		}
 	}()
 
+	if d.memSrv != nil {
+		for _, f := range t.Files() {
+			if f.DisplayPath() == filePath || f.Path() == filePath {
+				return d.memSrv.fileURL(t, f)
+			}
+		}
+		return ""
+	}
+
 	candidates := []string{
 		filepath.Join(d.cfg.TempDir, t.Name(), filePath),
 		filepath.Join(d.cfg.TempDir, filePath),
@@ -557,10 +851,44 @@ func (d *Downloader) FindLocalFile(infoHash string, filePath string) (result str
 	return ""
 }
 
+// HeadBytes returns how many bytes from the start of a torrent file are
+// downloaded without a gap, and the file's length. Beyond the head the file
+// may hold further downloaded ranges (indexes, tail samples) between holes.
+// Returns (0, 0) if the torrent or file is not found.
+func (d *Downloader) HeadBytes(infoHash string, filePath string) (head, size int64) {
+	t, ok := d.client.Torrent(torrentKey(infoHash))
+	if !ok {
+		return 0, 0
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			head, size = 0, 0
+		}
+	}()
+	info := t.Info()
+	if info == nil {
+		return 0, 0
+	}
+	for _, f := range t.Files() {
+		if f.DisplayPath() != filePath && f.Path() != filePath {
+			continue
+		}
+		end := f.Offset() + f.Length()
+		for i := f.Offset() / info.PieceLength; i*info.PieceLength < end; i++ {
+			if !t.Piece(int(i)).State().Complete {
+				break
+			}
+			head = min((i+1)*info.PieceLength, end) - f.Offset()
+		}
+		return head, f.Length()
+	}
+	return 0, 0
+}
+
 // DownloadFullFile downloads a specific file completely from a torrent.
 // Returns the local path to the fully downloaded file.
 func (d *Downloader) DownloadFullFile(ctx context.Context, infoHash string, filePath string) (localPath string, err error) {
-	hash := metainfo.NewHashFromHex(infoHash)
+	hash := torrentKey(infoHash)
 	t, ok := d.client.Torrent(hash)
 	if !ok {
 		return "", fmt.Errorf("torrent %s not found", TruncHash(infoHash))
@@ -576,6 +904,11 @@ func (d *Downloader) DownloadFullFile(ctx context.Context, infoHash string, file
 	for _, f := range t.Files() {
 		dp := f.DisplayPath()
 		if dp == filePath || f.Path() == filePath || strings.HasSuffix(dp, filePath) {
+			if d.cfg.DiskReserve != nil && d.mem == nil {
+				if err := d.cfg.DiskReserve(ctx, infoHash, t.BytesCompleted()+f.Length()-f.BytesCompleted()); err != nil {
+					return "", err
+				}
+			}
 			f.Download()
 
 			dlCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
@@ -606,22 +939,76 @@ func (d *Downloader) DownloadFullFile(ctx context.Context, infoHash string, file
 
 // Close shuts down the BitTorrent client.
 func (d *Downloader) Close() {
+	if d.cfg.DHTStateFile != "" {
+		if err := saveDHTNodes(d.client, d.cfg.DHTStateFile); err != nil {
+			log.Printf("save DHT routing table: %v", err)
+		}
+	}
 	d.client.Close()
+	if d.memSrv != nil {
+		d.memSrv.Close()
+	}
 }
 
-func buildMagnet(infoHash string) string {
-	params := []string{"xt=urn:btih:" + infoHash}
-	for _, tracker := range defaultTrackers {
+// buildMagnet returns a magnet link for a v1 (urn:btih) or v2 (urn:btmh)
+// info hash.
+func buildMagnet(infoHash string, trackers []string) string {
+	xt := "xt=urn:btih:" + infoHash
+	if len(infoHash) == 2*infohash_v2.Size {
+		xt = "xt=urn:btmh:" + sha256MultihashPrefix + infoHash
+	}
+	params := []string{xt}
+	for _, tracker := range trackers {
 		params = append(params, "tr="+url.QueryEscape(tracker))
 	}
 	return "magnet:?" + strings.Join(params, "&")
 }
 
-// DownloadFileHeader downloads the first minBytes of a specific file in a torrent.
-// For MP4/M4V it also downloads end bytes (moov atom). Returns the local file path.
+// addMagnet adds a magnet link to the client. A v2-only magnet has no v1
+// hash, so the torrent is added by its v2 hash truncated to 20 bytes (what
+// trackers and the DHT use), as torrentKey does. The full v2 hash is left
+// unset: the client checks it against the metadata and would otherwise also
+// require the truncated hash to be the v1 hash.
+func addMagnet(client *torrent.Client, magnet string) (*torrent.Torrent, error) {
+	spec, err := torrent.TorrentSpecFromMagnetUri(magnet)
+	if err != nil {
+		return nil, err
+	}
+	if spec.InfoHash.IsZero() {
+		if !spec.InfoHashV2.Ok {
+			return nil, errors.New("magnet has no info hash")
+		}
+		spec.InfoHash = *spec.InfoHashV2.Value.ToShort()
+		spec.InfoHashV2.SetNone()
+	}
+	t, _, err := client.AddTorrentSpec(spec)
+	return t, err
+}
+
+// torrentKey returns the hash the client indexes a torrent by: the v1 info
+// hash, or for a 64-char v2 hash its first 20 bytes. An invalid hash gives
+// the zero hash, which matches no torrent.
+func torrentKey(infoHash string) metainfo.Hash {
+	if len(infoHash) == 2*infohash_v2.Size {
+		var v2 infohash_v2.T
+		if err := v2.FromHexString(infoHash); err != nil {
+			return metainfo.Hash{}
+		}
+		return *v2.ToShort()
+	}
+	var h metainfo.Hash
+	if err := h.FromHexString(infoHash); err != nil {
+		return metainfo.Hash{}
+	}
+	return h
+}
+
+// DownloadFileHeader downloads the first minBytes of a specific file in a torrent,
+// plus the end bytes for containers that keep their index there (MP4 moov, AVI
+// idx1...). Returns the local file path.
 // The torrent must already have metadata resolved (call after PartialDownload).
 func (d *Downloader) DownloadFileHeader(ctx context.Context, infoHash string, filePath string, minBytes int) (localPath string, err error) {
-	hash := metainfo.NewHashFromHex(infoHash)
+	hash := torrentKey(infoHash)
 	t, ok := d.client.Torrent(hash)
 	if !ok {
 		return "", fmt.Errorf("torrent %s not found", TruncHash(infoHash))
@@ -649,34 +1036,10 @@ func (d *Downloader) DownloadFileHeader(ctx context.Context, infoHash string, fi
 	}
 
 	ext := strings.ToLower(filepath.Ext(target.DisplayPath()))
-	pieceLength := int(t.Info().PieceLength)
-	fileStartPiece := target.BeginPieceIndex()
-	fileEndPiece := target.EndPieceIndex()
-
-	piecesNeeded := (minBytes + pieceLength - 1) / pieceLength
-	startEnd := fileStartPiece + piecesNeeded
-	if startEnd > fileEndPiece {
-		startEnd = fileEndPiece
-	}
+	required := fallbackPieces(t, target, minBytes, containerFor(ext))
 
-	required := make(map[int]bool)
-	for i := fileStartPiece; i < startEnd; i++ {
-		required[i] = true
-	}
-
-	// For MP4/M4V: also get end pieces
-	if mp4Extensions[ext] {
-		endStart := fileEndPiece - piecesNeeded
-		if endStart < startEnd {
-			endStart = startEnd
-		}
-		for i := endStart; i < fileEndPiece; i++ {
-			required[i] = true
-		}
-	}
-
-	for i := range required {
-		t.Piece(i).SetPriority(torrent.PiecePriorityNow)
+	if err := d.requestPieces(ctx, t, infoHash, required); err != nil {
+		return "", err
 	}
 
 	if err := d.waitForPieces(ctx, t, infoHash, required); err != nil {
@@ -696,7 +1059,7 @@ func findLargestVideo(files []*torrent.File) (*torrent.File, error) {
 
 	for _, f := range files {
 		ext := strings.ToLower(filepath.Ext(f.DisplayPath()))
-		if videoExtensions[ext] && f.Length() > bestSize {
+		if videoExts[ext] && f.Length() > bestSize {
 			best = f
 			bestSize = f.Length()
 		}
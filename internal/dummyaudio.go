@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/cmplx"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dummySeconds    = 30    // seconds measured per track
+	dummySilentLUFS = -60.0 // integrated loudness at or below this is a silent track
+	dummyRate       = 8000  // sample rate tracks are decoded at for comparison
+	dummyWindows    = 3     // windows compared between tracks, spread over the readable span
+	dummyWindowSec  = 6     // seconds per comparison window
+	dummyMinRange   = 120.0 // seconds of readable audio needed to spread the windows
+	dummyMaxLagSec  = 0.25  // codec delays differ between encodes; search this far for alignment
+	dummyCopyCorr   = 0.95  // peak correlation in every window that marks a track as a copy
+	dummyHeadConf   = 0.5   // confidence of a duplicate verdict from windows packed into a short head
+)
+
+var ebur128IntegratedRe = regexp.MustCompile(`(?s)Summary:.*?I:\s+(-?[0-9.]+|-inf)\s+LUFS`)
+
+// measureLoudness returns the EBU R128 integrated loudness (LUFS) of the
+// first seconds of the streamIndex-th audio stream, using ffmpeg's ebur128
+// filter. Input is read for no more than span seconds (0 = no bound).
+func measureLoudness(ctx context.Context, ffmpegPath, filePath string, span float64, streamIndex, seconds int) (float64, error) {
+	mCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	args := append([]string{"-nostats"}, inputArgs(filePath, span)...)
+	args = append(args,
+		"-map", fmt.Sprintf("0:a:%d", streamIndex),
+		"-t", fmt.Sprint(seconds),
+		"-af", "ebur128",
+		"-f", "null",
+		"-",
+	)
+	cmd := exec.CommandContext(mCtx, ffmpegPath, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	if lufs, ok := parseEBUR128(stderr.String()); ok {
+		return lufs, nil
+	}
+	if runErr != nil {
+		return 0, fmt.Errorf("ffmpeg ebur128 failed: %w", runErr)
+	}
+	return 0, fmt.Errorf("no ebur128 summary in output")
+}
+
+// parseEBUR128 extracts the integrated loudness from the ebur128 summary.
+// Digital silence is reported at the gate (-70 LUFS) or as -inf.
+func parseEBUR128(output string) (float64, bool) {
+	m := ebur128IntegratedRe.FindStringSubmatch(output)
+	if m == nil {
+		return 0, false
+	}
+	if m[1] == "-inf" {
+		return math.Inf(-1), true
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	return v, err == nil
+}
+
+// maxCrossCorrelation returns the highest normalized correlation between a
+// and b over lags of up to maxLag samples, computed with an FFT.
+func maxCrossCorrelation(a, b []float64, maxLag int) float64 {
+	n := min(len(a), len(b))
+	if n == 0 {
+		return 0
+	}
+	size := 1
+	for size < 2*n {
+		size <<= 1
+	}
+	fa, fb := make([]complex128, size), make([]complex128, size)
+	var ma, mb float64
+	for i := 0; i < n; i++ {
+		ma += a[i]
+		mb += b[i]
+	}
+	ma, mb = ma/float64(n), mb/float64(n)
+	var ea, eb float64
+	for i := 0; i < n; i++ {
+		fa[i] = complex(a[i]-ma, 0)
+		fb[i] = complex(b[i]-mb, 0)
+		ea += (a[i] - ma) * (a[i] - ma)
+		eb += (b[i] - mb) * (b[i] - mb)
+	}
+	if ea == 0 || eb == 0 {
+		return 0
+	}
+
+	fft(fa)
+	fft(fb)
+	for i := range fa {
+		fa[i] *= cmplx.Conj(fb[i])
+	}
+	// Inverse FFT via conjugation: ifft(x) = conj(fft(conj(x))) / size
+	for i := range fa {
+		fa[i] = cmplx.Conj(fa[i])
+	}
+	fft(fa)
+
+	best := 0.0
+	norm := math.Sqrt(ea*eb) * float64(size)
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		best = max(best, real(fa[(lag+size)%size])/norm)
+	}
+	return best
+}
+
+// copiedWindows returns the lowest peak correlation between matching
+// windows of two tracks, and whether every window correlates at
+// dummyCopyCorr or more.
+func copiedWindows(a, b [][]float64, maxLag int) (float64, bool) {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0, false
+	}
+	lowest := 1.0
+	for w := range a {
+		lowest = min(lowest, maxCrossCorrelation(a[w], b[w], maxLag))
+	}
+	return lowest, lowest >= dummyCopyCorr
+}
+
+// ApplyDummyAudioDetection measures the loudness of each embedded audio
+// track and compares the tracks with each other. Releases sometimes pad in
+// extra "language" tracks that are silent or a copy of another track to
+// claim multi-audio; those are flagged `silent` or `duplicate_of` (index of
+// the earlier track they copy) and no longer count toward Languages.
+// Decoding is bounded to span seconds, the gap-free head of the download
+// (0 = the whole file). Needs ffmpeg. Modifies the result in-place.
+func ApplyDummyAudioDetection(ctx context.Context, ffmpegPath string, result *ScanResult, filePath string, span float64) {
+	if result == nil || ffmpegPath == "" || len(result.Audio) == 0 || spanTooShort(span, minAnalysisSpan) {
+		return
+	}
+
+	loudness := make(map[int]float64)
+	audible := 0
+	for i, t := range result.Audio {
+		if t.External {
+			continue
+		}
+		lufs, err := measureLoudness(ctx, ffmpegPath, filePath, span, i, dummySeconds)
+		if err != nil {
+			log.Printf("  [%s] loudness skipped for audio track %d: %v", TruncHash(result.InfoHash), i, err)
+			continue
+		}
+		loudness[i] = lufs
+		if lufs > dummySilentLUFS {
+			audible++
+		}
+	}
+
+	// Genuine dubs share logos, title music and effects-only scenes, so the
+	// comparison windows are spread over the readable audio; a short head
+	// lowers the confidence of the verdict (see dummyStarts).
+	limit := analysisLimit(result, span)
+	starts, confidence := dummyStarts(limit)
+	if starts == nil && audible > 1 {
+		log.Printf("  [%s] duplicate audio check skipped: %.0fs of readable audio, need %ds",
+			TruncHash(result.InfoHash), limit, dummyWindows*dummyWindowSec)
+	}
+
+	// A quiet opening silences every track; a track is only a dummy when
+	// another one has sound in the same window.
+	signals := make(map[int][][]float64)
+	for i, lufs := range loudness {
+		t := &result.Audio[i]
+		if lufs <= dummySilentLUFS {
+			if audible > 0 {
+				t.Silent = true
+				log.Printf("  [%s] audio track %d (%s) is silent (%.1f LUFS)", TruncHash(result.InfoHash), i, t.Lang, lufs)
+			}
+			continue
+		}
+		t.Loudness = math.Round(lufs*10) / 10
+		if audible > 1 && starts != nil {
+			if windows := decodeWindows(ctx, ffmpegPath, filePath, span, i, starts); windows != nil {
+				signals[i] = windows
+			}
+		}
+	}
+
+	maxLag := int(dummyMaxLagSec * dummyRate)
+	for j := range result.Audio {
+		if signals[j] == nil {
+			continue
+		}
+		for i := 0; i < j; i++ {
+			if signals[i] == nil || result.Audio[i].DuplicateOf != nil {
+				continue
+			}
+			if c, copied := copiedWindows(signals[i], signals[j], maxLag); copied {
+				orig := i
+				result.Audio[j].DuplicateOf = &orig
+				result.Audio[j].DuplicateConfidence = confidence
+				log.Printf("  [%s] audio track %d (%s) duplicates track %d (%s), correlation ≥ %.3f",
+					TruncHash(result.InfoHash), j, result.Audio[j].Lang, i, result.Audio[i].Lang, c)
+				break
+			}
+		}
+	}
+
+	result.Languages = ComputeLanguages(nil, result.Audio)
+}
+
+// dummyStarts returns the start times of the comparison windows within the
+// first limit seconds, and the confidence of a duplicate verdict drawn from
+// them. With dummyMinRange of readable audio the windows are spread far
+// enough to get past a shared opening. A shorter head (high-bitrate files
+// past analysisSampleCap) packs them into what there is, which may only
+// cover logos and title music, so the verdict gets dummyHeadConf. Nil when
+// not even the windows fit.
+func dummyStarts(limit float64) ([]float64, float64) {
+	if starts := spreadWindows(limit, dummyMinRange, dummyWindows, dummyWindowSec); starts != nil {
+		return starts, 1
+	}
+	if starts := spreadWindows(limit, dummyWindows*dummyWindowSec, dummyWindows, dummyWindowSec); starts != nil {
+		return starts, dummyHeadConf
+	}
+	return nil, 0
+}
+
+// decodeWindows decodes the comparison windows of the streamIndex-th audio
+// stream as mono PCM. Returns nil unless every window yields at least half
+// its length.
+func decodeWindows(ctx context.Context, ffmpegPath, filePath string, span float64, streamIndex int, starts []float64) [][]float64 {
+	windows := make([][]float64, len(starts))
+	for w, start := range starts {
+		ch, err := decodeChannels(ctx, ffmpegPath, filePath, span, start, streamIndex, 1, dummyWindowSec, dummyRate)
+		if err != nil || len(ch[0]) < dummyWindowSec*dummyRate/2 {
+			return nil
+		}
+		windows[w] = ch[0]
+	}
+	return windows
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestParseEBUR128(t *testing.T) {
+	output := `[Parsed_ebur128_0 @ 0x5581] t: 29.9 TARGET:-23 LUFS M: -19.8 S: -20.4 I: -21.0 LUFS LRA: 6.1 LU
+[Parsed_ebur128_0 @ 0x5581] Summary:
+
+  Integrated loudness:
+    I:         -20.7 LUFS
+    Threshold: -31.0 LUFS
+
+  Loudness range:
+    LRA:         6.3 LU
+`
+	if v, ok := parseEBUR128(output); !ok || v != -20.7 {
+		t.Errorf("parseEBUR128 = (%v, %v), want (-20.7, true)", v, ok)
+	}
+
+	silent := "[Parsed_ebur128_0 @ 0x1] Summary:\n\n  Integrated loudness:\n    I:         -70.0 LUFS\n"
+	if v, ok := parseEBUR128(silent); !ok || v > dummySilentLUFS {
+		t.Errorf("expected silent track, got (%v, %v)", v, ok)
+	}
+
+	if v, ok := parseEBUR128("[Parsed_ebur128_0] Summary:\n I: -inf LUFS\n"); !ok || !math.IsInf(v, -1) {
+		t.Errorf("expected -inf, got (%v, %v)", v, ok)
+	}
+
+	if _, ok := parseEBUR128("Error opening input"); ok {
+		t.Error("expected no result without a summary")
+	}
+}
+
+func TestMaxCrossCorrelation(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 4 * dummyRate
+	music := noiseSignal(rng, n+1000, 0.3)
+	maxLag := int(dummyMaxLagSec * dummyRate)
+
+	// Re-encoded copy: shifted by a codec delay, slightly quieter, with noise
+	delay := 400
+	copyTrack := make([]float64, n)
+	for i := range copyTrack {
+		copyTrack[i] = 0.8*music[i+delay] + 0.02*(rng.Float64()*2-1)
+	}
+	if c := maxCrossCorrelation(music[:n], copyTrack, maxLag); c < dummyCopyCorr {
+		t.Errorf("delayed copy: correlation %.3f, want ≥ %.2f", c, dummyCopyCorr)
+	}
+
+	// Dub: same music and effects, different dialogue on top
+	dubA, dubB := make([]float64, n), make([]float64, n)
+	dialogueA, dialogueB := noiseSignal(rng, n, 0.3), noiseSignal(rng, n, 0.3)
+	for i := range dubA {
+		dubA[i] = music[i] + dialogueA[i]
+		dubB[i] = music[i] + dialogueB[i]
+	}
+	if c := maxCrossCorrelation(dubA, dubB, maxLag); c >= dummyCopyCorr {
+		t.Errorf("dubbed tracks: correlation %.3f, want < %.2f", c, dummyCopyCorr)
+	}
+
+	if c := maxCrossCorrelation(music[:n], make([]float64, n), maxLag); c != 0 {
+		t.Errorf("silent track: correlation %.3f, want 0", c)
+	}
+}
+
+func TestComputeLanguages_SkipsDummyTracks(t *testing.T) {
+	orig := 0
+	audio := []AudioTrack{
+		{Lang: "en", Role: RoleMain},
+		{Lang: "es", Role: RoleMain, DuplicateOf: &orig},
+		{Lang: "fr", Role: RoleMain, Silent: true},
+		{Lang: "de", Role: RoleMain},
+	}
+	langs := ComputeLanguages(nil, audio)
+	slices.Sort(langs)
+	if !slices.Equal(langs, []string{"de", "en"}) {
+		t.Errorf("expected [de en], got %v", langs)
+	}
+}
+
+func TestComputeLanguages_DuplicateWithUniqueLanguage(t *testing.T) {
+	orig := 0
+	audio := []AudioTrack{
+		{Lang: "en", Role: RoleMain},
+		{Lang: "ja", Role: RoleMain, DuplicateOf: &orig},
+	}
+	langs := ComputeLanguages([]string{"multi"}, audio)
+	if !slices.Equal(langs, []string{"en"}) {
+		t.Errorf("expected [en], got %v", langs)
+	}
+}
+
+func TestCopiedWindows_SharedIntro(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	length := 150 * dummyRate
+	maxLag := int(dummyMaxLagSec * dummyRate)
+
+	// Two dubs: the first 40 s (logos, title music) are identical, then the
+	// same score carries different dialogue.
+	intro := noiseSignal(rng, 40*dummyRate, 0.3)
+	score := noiseSignal(rng, length, 0.2)
+	dubA, dubB := make([]float64, length), make([]float64, length)
+	dialogueA, dialogueB := noiseSignal(rng, length, 0.3), noiseSignal(rng, length, 0.3)
+	for i := range dubA {
+		if i < len(intro) {
+			dubA[i], dubB[i] = intro[i], intro[i]
+			continue
+		}
+		dubA[i] = score[i] + dialogueA[i]
+		dubB[i] = score[i] + dialogueB[i]
+	}
+	copyTrack := make([]float64, length)
+	for i := range copyTrack {
+		copyTrack[i] = 0.9*dubA[i] + 0.01*(rng.Float64()*2-1)
+	}
+
+	// The opening alone looks like a copy.
+	if c := maxCrossCorrelation(dubA[:20*dummyRate], dubB[:20*dummyRate], maxLag); c < dummyCopyCorr {
+		t.Fatalf("shared intro: correlation %.3f, want ≥ %.2f (precondition)", c, dummyCopyCorr)
+	}
+
+	windows := func(x []float64) [][]float64 {
+		var out [][]float64
+		for _, s := range spreadWindows(float64(length/dummyRate), dummyMinRange, dummyWindows, dummyWindowSec) {
+			from := int(s * dummyRate)
+			out = append(out, x[from:from+dummyWindowSec*dummyRate])
+		}
+		return out
+	}
+	if c, copied := copiedWindows(windows(dubA), windows(dubB), maxLag); copied {
+		t.Errorf("dubs with a shared intro flagged as copies (lowest correlation %.3f)", c)
+	}
+	if c, copied := copiedWindows(windows(dubA), windows(copyTrack), maxLag); !copied {
+		t.Errorf("copy not detected (lowest correlation %.3f)", c)
+	}
+	if _, copied := copiedWindows(nil, nil, maxLag); copied {
+		t.Error("no windows should never be a copy")
+	}
+}
+
+func TestDummyStarts(t *testing.T) {
+	starts, conf := dummyStarts(600)
+	if len(starts) != dummyWindows || conf != 1 || starts[0] < 60 {
+		t.Errorf("long head: got %v (confidence %v), want spread windows", starts, conf)
+	}
+
+	// A short head packs the windows into what there is, with lower confidence
+	starts, conf = dummyStarts(25)
+	if len(starts) != dummyWindows || conf != dummyHeadConf || starts[len(starts)-1]+dummyWindowSec > 25 {
+		t.Errorf("25s head: got %v (confidence %v)", starts, conf)
+	}
+
+	if starts, _ := dummyStarts(dummyWindows*dummyWindowSec - 1); starts != nil {
+		t.Errorf("head shorter than the windows should not be compared, got %v", starts)
+	}
+}
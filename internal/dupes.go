@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DupeRelease is a scanned release considered for duplicate detection.
+type DupeRelease struct {
+	InfoHash string `json:"info_hash"`
+	File     string `json:"file"`
+	Report   string `json:"report"` // report file the result was read from
+
+	hashes [][2]uint64 // parsed pHash/dHash pairs
+}
+
+// DupeCluster is a group of releases whose video fingerprints match.
+type DupeCluster struct {
+	Releases []DupeRelease `json:"releases"`
+}
+
+// LoadReportResults reads the results of a scan report. Both the JSON
+// report written by `truespec scan` and the JSONL stream of pipe mode are
+// accepted.
+func LoadReportResults(path string) ([]ScanResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report ScanReport
+	if err := json.Unmarshal(data, &report); err == nil && report.Results != nil {
+		return report.Results, nil
+	}
+
+	var results []ScanResult
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 1024*1024), 64*1024*1024) // base64 contact sheets make long lines
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var r ScanResult
+		if err := json.Unmarshal([]byte(text), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		results = append(results, r)
+	}
+	return results, sc.Err()
+}
+
+// releaseKey identifies the torrent of a result. A hybrid torrent scanned by
+// its v1 hash in one report and its v2 hash in another has the same key.
+func releaseKey(r ScanResult) string {
+	switch {
+	case r.InfoHashV1 != "":
+		return r.InfoHashV1
+	case r.InfoHashV2 != "":
+		return r.InfoHashV2
+	}
+	return strings.ToLower(r.InfoHash)
+}
+
+// LoadDupeReleases reads the given reports and returns the releases that
+// have video fingerprints, one per info hash (the first occurrence wins).
+func LoadDupeReleases(paths []string) ([]DupeRelease, error) {
+	var releases []DupeRelease
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		results, err := LoadReportResults(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			key := releaseKey(r)
+			if seen[key] || len(r.Fingerprints) == 0 {
+				continue
+			}
+			rel := DupeRelease{InfoHash: r.InfoHash, File: r.File, Report: p}
+			for _, fh := range r.Fingerprints {
+				ph, err1 := strconv.ParseUint(fh.PHash, 16, 64)
+				dh, err2 := strconv.ParseUint(fh.DHash, 16, 64)
+				if err1 == nil && err2 == nil {
+					rel.hashes = append(rel.hashes, [2]uint64{ph, dh})
+				}
+			}
+			if len(rel.hashes) > 0 {
+				seen[key] = true
+				releases = append(releases, rel)
+			}
+		}
+	}
+	return releases, nil
+}
+
+// fingerprintsMatch reports whether two releases show the same picture.
+// Frames match when both their pHash and dHash are within a small Hamming
+// distance. Studio logos at the start are shared by unrelated films, so at
+// least 75% of the shorter fingerprint (and at least all of it when it has
+// fewer than 3 frames) must find a match.
+func fingerprintsMatch(a, b [][2]uint64) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(a) == 0 {
+		return false
+	}
+	matched := 0
+	for _, fa := range a {
+		for _, fb := range b {
+			if bits.OnesCount64(fa[0]^fb[0]) <= phashMaxDist && bits.OnesCount64(fa[1]^fb[1]) <= dhashMaxDist {
+				matched++
+				break
+			}
+		}
+	}
+	if len(a) < 3 {
+		return matched == len(a)
+	}
+	return float64(matched) >= float64(len(a))*phashMatchRatio
+}
+
+// FindDuplicates clusters releases whose fingerprints match, directly or
+// through another release. Only clusters of two or more are returned, in
+// the order their first release appears.
+func FindDuplicates(releases []DupeRelease) []DupeCluster {
+	parent := make([]int, len(releases))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range releases {
+		for j := i + 1; j < len(releases); j++ {
+			if fingerprintsMatch(releases[i].hashes, releases[j].hashes) {
+				ri, rj := find(i), find(j)
+				if ri != rj {
+					parent[max(ri, rj)] = min(ri, rj)
+				}
+			}
+		}
+	}
+
+	groups := make(map[int][]DupeRelease)
+	var order []int
+	for i, rel := range releases {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			order = append(order, root)
+		}
+		groups[root] = append(groups[root], rel)
+	}
+
+	clusters := []DupeCluster{}
+	for _, root := range order {
+		if len(groups[root]) > 1 {
+			clusters = append(clusters, DupeCluster{Releases: groups[root]})
+		}
+	}
+	return clusters
+}
+
+// FormatDupes renders duplicate clusters as human-readable text.
+func FormatDupes(clusters []DupeCluster, scanned int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d release(s) with fingerprints, %d duplicate cluster(s)\n", scanned, len(clusters))
+	for i, c := range clusters {
+		fmt.Fprintf(&b, "\nCluster %d (%d releases)\n", i+1, len(c.Releases))
+		for _, r := range c.Releases {
+			fmt.Fprintf(&b, "  %s  %s  (%s)\n", r.InfoHash, r.File, r.Report)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fingerprintOf hashes frames re-encoded with the given brightness shift.
+func fingerprintOf(rng *rand.Rand, frames []grayFrame, shift int) []FrameHash {
+	var out []grayFrame
+	for _, f := range frames {
+		out = append(out, reencode(rng, f, shift))
+	}
+	return fingerprintFrames(out)
+}
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var movieA, movieB []grayFrame
+	logo := sceneFrame(rng)
+	movieA = append(movieA, logo)
+	movieB = append(movieB, logo)
+	for i := 0; i < 5; i++ {
+		movieA = append(movieA, sceneFrame(rng))
+		movieB = append(movieB, sceneFrame(rng))
+	}
+
+	dir := t.TempDir()
+	report := filepath.Join(dir, "results.json")
+	writeJSON(t, report, ScanReport{Results: []ScanResult{
+		{InfoHash: "aaaa", File: "Movie.A.2020.1080p.mkv", Fingerprints: fingerprintOf(rng, movieA, 0)},
+		{InfoHash: "bbbb", File: "Movie.B.2021.1080p.mkv", Fingerprints: fingerprintOf(rng, movieB, 0)},
+		{InfoHash: "cccc", File: "no-fingerprint.mkv"},
+	}})
+
+	// Pipe mode JSONL with a renamed re-upload of A, and A itself again
+	jsonl := filepath.Join(dir, "pipe.jsonl")
+	var lines []byte
+	for _, r := range []ScanResult{
+		{InfoHash: "dddd", File: "www.spam.site - Movie A.mkv", Fingerprints: fingerprintOf(rng, movieA, 5)},
+		{InfoHash: "AAAA", File: "Movie.A.2020.1080p.mkv", Fingerprints: fingerprintOf(rng, movieA, 0)},
+	} {
+		line, _ := json.Marshal(r)
+		lines = append(append(lines, line...), '\n')
+	}
+	if err := os.WriteFile(jsonl, lines, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	releases, err := LoadDupeReleases([]string{report, jsonl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 3 {
+		t.Fatalf("expected 3 fingerprinted releases (deduplicated by hash), got %d", len(releases))
+	}
+
+	clusters := FindDuplicates(releases)
+	if len(clusters) != 1 {
+		t.Fatalf("expected 1 cluster, got %d: %+v", len(clusters), clusters)
+	}
+	got := clusters[0].Releases
+	if len(got) != 2 || got[0].InfoHash != "aaaa" || got[1].InfoHash != "dddd" {
+		t.Errorf("expected cluster [aaaa dddd], got %+v", got)
+	}
+	if got[1].Report != jsonl {
+		t.Errorf("expected report path %s, got %s", jsonl, got[1].Report)
+	}
+}
+
+func TestLoadReportResults_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte("not json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadReportResults(path); err == nil {
+		t.Error("expected error for invalid report")
+	}
+}
+
+func TestReleaseKey_Hybrid(t *testing.T) {
+	byV1 := ScanResult{InfoHash: "AAAA", InfoHashV1: "aaaa", InfoHashV2: "bbbb"}
+	byV2 := ScanResult{InfoHash: "bbbb", InfoHashV1: "aaaa", InfoHashV2: "bbbb"}
+	if releaseKey(byV1) != releaseKey(byV2) {
+		t.Error("a hybrid torrent scanned by either hash should have one key")
+	}
+	if got := releaseKey(ScanResult{InfoHash: "CCCC"}); got != "cccc" {
+		t.Errorf("old reports should fall back to the lowercased info_hash, got %q", got)
+	}
+}
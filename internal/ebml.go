@@ -0,0 +1,342 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Matroska element IDs (with their length-marker bits, as written in the file).
+const (
+	ebmlIDHeader       = 0x1A45DFA3
+	mkvIDSegment       = 0x18538067
+	mkvIDSeekHead      = 0x114D9B74
+	mkvIDSeek          = 0x4DBB
+	mkvIDSeekID        = 0x53AB
+	mkvIDSeekPosition  = 0x53AC
+	mkvIDInfo          = 0x1549A966
+	mkvIDTracks        = 0x1654AE6B
+	mkvIDCues          = 0x1C53BB6B
+	mkvIDChapters      = 0x1043A770
+	mkvIDTags          = 0x1254C367
+	mkvIDAttachments   = 0x1941A469
+	mkvIDCluster       = 0x1F43B675
+	mkvIDTimecodeScale = 0x2AD7B1 // in Info: nanoseconds per timestamp tick
+	mkvIDDuration      = 0x4489   // in Info: float, in ticks
+)
+
+const (
+	mkvHeadProbe     = 256 * 1024       // bytes read from the start to find the SeekHead and Tracks
+	mkvAttachmentCap = 1024 * 1024      // attachments (fonts, cover art) are only needed for their listing
+	mkvElementCap    = 16 * 1024 * 1024 // guard against corrupt sizes
+	mkvInfoCap       = 64 * 1024        // Info holds a few short fields
+)
+
+// mkvIndexElements are the level-1 elements ffprobe reads besides the
+// headers at the start: they may sit anywhere, often after the clusters.
+var mkvIndexElements = []uint32{mkvIDInfo, mkvIDTracks, mkvIDChapters, mkvIDTags, mkvIDCues, mkvIDAttachments}
+
+// byteRange is a half-open range [Start, End) of file offsets.
+type byteRange struct {
+	Start, End int64
+}
+
+// ebmlUnknownSize marks an EBML element whose size field is all ones
+// (live streams write Segments and Clusters this way).
+const ebmlUnknownSize = -1
+
+// readEBMLID reads an element ID (1–4 bytes, marker bits kept).
+func readEBMLID(b []byte) (id uint32, n int, err error) {
+	if len(b) == 0 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	switch {
+	case b[0]&0x80 != 0:
+		n = 1
+	case b[0]&0x40 != 0:
+		n = 2
+	case b[0]&0x20 != 0:
+		n = 3
+	case b[0]&0x10 != 0:
+		n = 4
+	default:
+		return 0, 0, fmt.Errorf("invalid EBML ID byte 0x%02x", b[0])
+	}
+	if len(b) < n {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	for _, c := range b[:n] {
+		id = id<<8 | uint32(c)
+	}
+	return id, n, nil
+}
+
+// readEBMLSize reads an element data size (1–8 byte vint). Returns
+// ebmlUnknownSize when all value bits are set.
+func readEBMLSize(b []byte) (size int64, n int, err error) {
+	if len(b) == 0 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	n = 1
+	for mask := byte(0x80); n <= 8 && b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 {
+		return 0, 0, fmt.Errorf("invalid EBML size byte 0x00")
+	}
+	if len(b) < n {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	v := uint64(b[0] & (0xFF >> n))
+	allOnes := v == uint64(0xFF>>n)
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+		allOnes = allOnes && c == 0xFF
+	}
+	if allOnes {
+		return ebmlUnknownSize, n, nil
+	}
+	return int64(v), n, nil
+}
+
+// readEBMLHeader reads an element header and returns its ID, data size and
+// header length.
+func readEBMLHeader(b []byte) (id uint32, size int64, n int, err error) {
+	id, idLen, err := readEBMLID(b)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	size, sizeLen, err := readEBMLSize(b[idLen:])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return id, size, idLen + sizeLen, nil
+}
+
+// readUint decodes a big-endian unsigned integer element body.
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// mkvLayout is what the start of a Matroska file says about where its
+// level-1 elements are.
+type mkvLayout struct {
+	segmentData  int64            // offset of the Segment's data (SeekPosition base)
+	elements     map[uint32]int64 // element ID → offset of its header
+	sizes        map[uint32]int64 // element ID → total length (header + data), when known
+	firstCluster int64            // offset of the first Cluster, 0 if not seen
+	headerEnd    int64            // end of the last level-1 element parsed before the clusters
+}
+
+// parseMKVHead parses the EBML header, Segment and the level-1 elements in
+// head (the first bytes of the file), including SeekHead entries that point
+// further into the file. Parsing stops at the first Cluster or where head
+// runs out.
+func parseMKVHead(head []byte) (mkvLayout, error) {
+	layout := mkvLayout{elements: make(map[uint32]int64), sizes: make(map[uint32]int64)}
+
+	id, size, n, err := readEBMLHeader(head)
+	if err != nil || id != ebmlIDHeader {
+		return layout, errors.New("not a Matroska/WebM file")
+	}
+	off := int64(n) + size
+	if size < 0 || off >= int64(len(head)) {
+		return layout, errors.New("EBML header truncated")
+	}
+	id, _, n, err = readEBMLHeader(head[off:])
+	if err != nil || id != mkvIDSegment {
+		return layout, errors.New("Segment element not found")
+	}
+	off += int64(n)
+	layout.segmentData = off
+	layout.headerEnd = off
+
+	for off < int64(len(head)) {
+		id, size, n, err := readEBMLHeader(head[off:])
+		if err != nil {
+			break // header cut off at the end of head
+		}
+		if id == mkvIDCluster {
+			layout.firstCluster = off
+			break
+		}
+		if size == ebmlUnknownSize {
+			break
+		}
+		if _, seen := layout.elements[id]; !seen {
+			layout.elements[id] = off
+			layout.sizes[id] = int64(n) + size
+		}
+		dataEnd := off + int64(n) + size
+		if id == mkvIDSeekHead && dataEnd <= int64(len(head)) {
+			layout.addSeeks(head[off+int64(n) : dataEnd])
+		}
+		off = dataEnd
+		layout.headerEnd = min(off, int64(len(head)))
+	}
+	return layout, nil
+}
+
+// addSeeks records the targets of the Seek entries in a SeekHead body.
+// Entries for elements already located by the sequential walk are ignored.
+func (l *mkvLayout) addSeeks(body []byte) {
+	for off := 0; off < len(body); {
+		id, size, n, err := readEBMLHeader(body[off:])
+		if err != nil || size < 0 || off+n+int(size) > len(body) {
+			return
+		}
+		if id == mkvIDSeek {
+			var target uint32
+			var pos int64 = -1
+			entry := body[off+n : off+n+int(size)]
+			for eo := 0; eo < len(entry); {
+				cid, csize, cn, err := readEBMLHeader(entry[eo:])
+				if err != nil || csize < 0 || eo+cn+int(csize) > len(entry) {
+					break
+				}
+				val := entry[eo+cn : eo+cn+int(csize)]
+				switch cid {
+				case mkvIDSeekID:
+					target = uint32(readUint(val))
+				case mkvIDSeekPosition:
+					pos = int64(readUint(val))
+				}
+				eo += cn + int(csize)
+			}
+			if target == mkvIDCluster && pos >= 0 && l.firstCluster == 0 {
+				l.firstCluster = l.segmentData + pos
+			} else if _, seen := l.elements[target]; target != 0 && pos >= 0 && !seen {
+				l.elements[target] = l.segmentData + pos
+			}
+		}
+		off += n + int(size)
+	}
+}
+
+// mkvByteRanges computes the byte ranges of a Matroska/WebM file that
+// ffprobe needs: the headers at the start, a sample of cluster data for
+// decoding, and the Info, Tracks, Chapters, Tags, Cues and Attachments
+// elements wherever the SeekHead says they are. Reading through r downloads
+// only the pieces holding the headers that must be inspected.
+func mkvByteRanges(r io.ReaderAt, size, sample int64) ([]byteRange, error) {
+	head := make([]byte, min(size, mkvHeadProbe))
+	if n, err := r.ReadAt(head, 0); err != nil && !(errors.Is(err, io.EOF) && n == len(head)) {
+		return nil, fmt.Errorf("read head: %w", err)
+	}
+	layout, err := parseMKVHead(head)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := layout.elements[mkvIDTracks]; !ok {
+		return nil, errors.New("Tracks element not found")
+	}
+
+	ranges := []byteRange{{0, layout.headerEnd}}
+	clusters := layout.firstCluster
+	if clusters == 0 {
+		clusters = layout.headerEnd
+	}
+	ranges = append(ranges, byteRange{clusters, min(size, clusters+sample)})
+
+	for _, id := range mkvIndexElements {
+		pos, ok := layout.elements[id]
+		if !ok || pos >= size {
+			continue
+		}
+		length, known := layout.sizes[id]
+		if !known {
+			// Element beyond the head: read its header to learn the size
+			var hdr [12]byte
+			n, err := r.ReadAt(hdr[:min(int64(len(hdr)), size-pos)], pos)
+			if err != nil && n == 0 {
+				continue
+			}
+			gotID, dataSize, hn, err := readEBMLHeader(hdr[:n])
+			if err != nil || gotID != id || dataSize < 0 {
+				continue // stale SeekHead entry
+			}
+			length = int64(hn) + dataSize
+		}
+		limit := int64(mkvElementCap)
+		if id == mkvIDAttachments {
+			limit = mkvAttachmentCap
+		}
+		ranges = append(ranges, byteRange{pos, min(size, pos+min(length, limit))})
+	}
+	return mergeRanges(ranges), nil
+}
+
+// mkvDuration returns the Segment duration in seconds from the Info
+// element, or 0 when it is missing (live streams) or can't be read.
+func mkvDuration(r io.ReaderAt, size int64) float64 {
+	head := make([]byte, min(size, mkvHeadProbe))
+	if n, err := r.ReadAt(head, 0); err != nil && !(errors.Is(err, io.EOF) && n == len(head)) {
+		return 0
+	}
+	layout, err := parseMKVHead(head)
+	if err != nil {
+		return 0
+	}
+	pos, ok := layout.elements[mkvIDInfo]
+	if !ok || pos >= size {
+		return 0
+	}
+	info := make([]byte, min(size-pos, mkvInfoCap))
+	n, err := r.ReadAt(info, pos)
+	if err != nil && n == 0 {
+		return 0
+	}
+	id, dataSize, hn, err := readEBMLHeader(info[:n])
+	if err != nil || id != mkvIDInfo || dataSize < 0 {
+		return 0
+	}
+	body := info[hn:min(int64(n), int64(hn)+dataSize)]
+
+	scale, ticks := uint64(1000000), 0.0 // TimecodeScale defaults to 1ms
+	for off := 0; off < len(body); {
+		cid, csize, cn, err := readEBMLHeader(body[off:])
+		if err != nil || csize < 0 || off+cn+int(csize) > len(body) {
+			break
+		}
+		val := body[off+cn : off+cn+int(csize)]
+		switch {
+		case cid == mkvIDTimecodeScale && csize > 0:
+			scale = readUint(val)
+		case cid == mkvIDDuration && csize == 4:
+			ticks = float64(math.Float32frombits(binary.BigEndian.Uint32(val)))
+		case cid == mkvIDDuration && csize == 8:
+			ticks = math.Float64frombits(binary.BigEndian.Uint64(val))
+		}
+		off += cn + int(csize)
+	}
+	if ticks <= 0 || math.IsInf(ticks, 0) || math.IsNaN(ticks) {
+		return 0
+	}
+	return ticks * float64(scale) / 1e9
+}
+
+// mergeRanges sorts ranges and joins overlapping or touching ones.
+func mergeRanges(ranges []byteRange) []byteRange {
+	sorted := append([]byteRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var out []byteRange
+	for _, r := range sorted {
+		if r.End <= r.Start {
+			continue
+		}
+		if n := len(out); n > 0 && r.Start <= out[n-1].End {
+			out[n-1].End = max(out[n-1].End, r.End)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+// ebmlElement encodes an element with an 8-byte size field.
+func ebmlElement(id uint32, body []byte) []byte {
+	var out []byte
+	switch {
+	case id > 0xFFFFFF:
+		out = binary.BigEndian.AppendUint32(out, id)
+	case id > 0xFFFF:
+		out = append(out, byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xFF:
+		out = append(out, byte(id>>8), byte(id))
+	default:
+		out = append(out, byte(id))
+	}
+	size := uint64(len(body)) | 0x01<<56
+	out = binary.BigEndian.AppendUint64(out, size)
+	return append(out, body...)
+}
+
+func ebmlUint(id uint32, v uint64) []byte {
+	return ebmlElement(id, binary.BigEndian.AppendUint64(nil, v))
+}
+
+// recordingReader records the offsets read from a bytes.Reader.
+type recordingReader struct {
+	*bytes.Reader
+	reads []byteRange
+}
+
+func (r *recordingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.reads = append(r.reads, byteRange{off, off + int64(len(p))})
+	return r.Reader.ReadAt(p, off)
+}
+
+// buildMKV returns a Matroska file with the headers at the start, clusterBytes
+// of cluster data, and Cues/Tags after the clusters, plus the offsets of the
+// Cues and Tags elements.
+func buildMKV(clusterBytes int) (file []byte, cuesAt, tagsAt int64) {
+	header := ebmlElement(ebmlIDHeader, ebmlElement(0x4282, []byte("matroska")))
+	info := ebmlElement(mkvIDInfo, ebmlUint(0x2AD7B1, 1000000))
+	tracks := ebmlElement(mkvIDTracks, ebmlElement(0xAE, ebmlUint(0xD7, 1)))
+	cluster := ebmlElement(mkvIDCluster, bytes.Repeat([]byte{0xAA}, clusterBytes))
+	cues := ebmlElement(mkvIDCues, bytes.Repeat([]byte{0xBB}, 5000))
+	tags := ebmlElement(mkvIDTags, bytes.Repeat([]byte{0xCC}, 300))
+
+	seek := func(id uint32, pos int) []byte {
+		return ebmlElement(mkvIDSeek, append(
+			ebmlElement(mkvIDSeekID, binary.BigEndian.AppendUint32(nil, id)),
+			ebmlUint(mkvIDSeekPosition, uint64(pos))...))
+	}
+	// SeekHead size is fixed by its entry count, so positions can be computed first
+	seekHeadLen := len(ebmlElement(mkvIDSeekHead, bytes.Repeat(seek(0, 0), 4)))
+	infoPos := seekHeadLen
+	tracksPos := infoPos + len(info)
+	cuesPos := tracksPos + len(tracks) + len(cluster)
+	tagsPos := cuesPos + len(cues)
+	seekHead := ebmlElement(mkvIDSeekHead, bytes.Join([][]byte{
+		seek(mkvIDInfo, infoPos), seek(mkvIDTracks, tracksPos), seek(mkvIDCues, cuesPos), seek(mkvIDTags, tagsPos),
+	}, nil))
+
+	segment := bytes.Join([][]byte{seekHead, info, tracks, cluster, cues, tags}, nil)
+	// Unknown-size Segment, as written by streaming muxers
+	segHeader := []byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	file = bytes.Join([][]byte{header, segHeader, segment}, nil)
+	segData := int64(len(header) + len(segHeader))
+	return file, segData + int64(cuesPos), segData + int64(tagsPos)
+}
+
+func TestMKVByteRanges(t *testing.T) {
+	file, cuesAt, tagsAt := buildMKV(8 * 1024 * 1024)
+	r := &recordingReader{Reader: bytes.NewReader(file)}
+	size := int64(len(file))
+
+	ranges, err := mkvByteRanges(r, size, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+	for _, br := range ranges {
+		total += br.End - br.Start
+	}
+	if total > 2*1024*1024 {
+		t.Errorf("expected ~1MB of ranges, got %d bytes: %+v", total, ranges)
+	}
+	covered := func(off, end int64) bool {
+		for _, br := range ranges {
+			if br.Start <= off && end <= br.End {
+				return true
+			}
+		}
+		return false
+	}
+	if !covered(0, 200) {
+		t.Errorf("headers not covered: %+v", ranges)
+	}
+	if !covered(cuesAt, cuesAt+5000) {
+		t.Errorf("Cues at %d not covered: %+v", cuesAt, ranges)
+	}
+	if !covered(tagsAt, size) {
+		t.Errorf("Tags at %d not covered: %+v", tagsAt, ranges)
+	}
+
+	// Only the head and the Cues/Tags element headers were read
+	for _, rd := range r.reads[1:] {
+		if rd.End-rd.Start > 16 {
+			t.Errorf("unexpected large read %+v", rd)
+		}
+	}
+}
+
+// ebmlFloat encodes a float element with an 8-byte body.
+func ebmlFloat(id uint32, v float64) []byte {
+	return ebmlElement(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+// sparseFile is a file of size bytes with head at the start and tail at the
+// end, and zeros in between, to stand in for multi-gigabyte releases.
+type sparseFile struct {
+	head, tail []byte
+	size       int64
+}
+
+func (f sparseFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), f.size-off))
+	tailAt := f.size - int64(len(f.tail))
+	for i := range n {
+		pos := off + int64(i)
+		switch {
+		case pos < int64(len(f.head)):
+			p[i] = f.head[pos]
+		case pos >= tailAt:
+			p[i] = f.tail[pos-tailAt]
+		default:
+			p[i] = 0
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// buildSparseMKV returns a Matroska file of size bytes lasting duration
+// seconds: headers and the start of the first Cluster at the beginning, Cues
+// in the last bytes.
+func buildSparseMKV(size int64, duration float64) sparseFile {
+	header := ebmlElement(ebmlIDHeader, ebmlElement(0x4282, []byte("matroska")))
+	segHeader := []byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	info := ebmlElement(mkvIDInfo, append(ebmlUint(mkvIDTimecodeScale, 1000000), ebmlFloat(mkvIDDuration, duration*1000)...))
+	tracks := ebmlElement(mkvIDTracks, ebmlElement(0xAE, ebmlUint(0xD7, 1)))
+	cues := ebmlElement(mkvIDCues, bytes.Repeat([]byte{0xBB}, 64*1024))
+	segData := int64(len(header) + len(segHeader))
+
+	seek := func(id uint32, pos int64) []byte {
+		return ebmlElement(mkvIDSeek, append(
+			ebmlElement(mkvIDSeekID, binary.BigEndian.AppendUint32(nil, id)),
+			ebmlUint(mkvIDSeekPosition, uint64(pos))...))
+	}
+	seekHeadLen := int64(len(ebmlElement(mkvIDSeekHead, bytes.Repeat(seek(0, 0), 2))))
+	seekHead := ebmlElement(mkvIDSeekHead, append(
+		seek(mkvIDInfo, seekHeadLen), seek(mkvIDCues, size-int64(len(cues))-segData)...))
+	cluster := []byte{0x1F, 0x43, 0xB6, 0x75, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+	head := bytes.Join([][]byte{header, segHeader, seekHead, info, tracks, cluster}, nil)
+	return sparseFile{head: head, tail: cues, size: size}
+}
+
+func TestMKVDuration(t *testing.T) {
+	f := buildSparseMKV(100*1024*1024, 5400)
+	if got := mkvDuration(f, f.size); math.Abs(got-5400) > 1e-6 {
+		t.Errorf("mkvDuration = %v, want 5400", got)
+	}
+
+	// Without a Duration (live streams) the length is unknown
+	file, _, _ := buildMKV(1024)
+	if got := mkvDuration(bytes.NewReader(file), int64(len(file))); got != 0 {
+		t.Errorf("no Duration element: got %v, want 0", got)
+	}
+}
+
+func TestMKVByteRanges_NotMatroska(t *testing.T) {
+	data := append([]byte("\x00\x00\x00\x20ftypisom"), make([]byte, 1000)...)
+	if _, err := mkvByteRanges(bytes.NewReader(data), int64(len(data)), 1024); err == nil {
+		t.Error("expected error for non-Matroska data")
+	}
+}
+
+func TestReadEBMLSize(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		size int64
+		n    int
+	}{
+		{[]byte{0x81}, 1, 1},
+		{[]byte{0x40, 0x02}, 2, 2},
+		{[]byte{0xFF}, ebmlUnknownSize, 1},
+		{[]byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, ebmlUnknownSize, 8},
+		{[]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}, 256, 8},
+	}
+	for _, tt := range tests {
+		size, n, err := readEBMLSize(tt.in)
+		if err != nil || size != tt.size || n != tt.n {
+			t.Errorf("readEBMLSize(%x) = (%d, %d, %v), want (%d, %d)", tt.in, size, n, err, tt.size, tt.n)
+		}
+	}
+	if _, _, err := readEBMLSize([]byte{0x00}); err == nil {
+		t.Error("expected error for zero length marker")
+	}
+}
+
+func TestMergeRanges(t *testing.T) {
+	got := mergeRanges([]byteRange{{100, 200}, {0, 50}, {150, 300}, {300, 310}, {400, 400}})
+	want := []byteRange{{0, 50}, {100, 310}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("mergeRanges = %+v, want %+v", got, want)
+	}
+}
@@ -14,8 +14,8 @@ import (
 )
 
 var (
-	ffprobeAPIClient = &http.Client{Timeout: 30 * time.Second}
-	ffprobeDLClient  = &http.Client{Timeout: 10 * time.Minute}
+	ffprobeAPIClient = newOutboundClient(30 * time.Second)
+	ffprobeDLClient  = newOutboundClient(10 * time.Minute)
 )
 
 const maxFFprobeZipSize = 100 * 1024 * 1024 // 100MB max for downloaded zip
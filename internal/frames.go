@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"time"
+)
+
+const (
+	frameSampleEvery = 12 // decode every Nth frame of the downloaded segment
+	frameSampleMax   = 8  // max frames decoded per analysis
+	frameMaxHeight   = 2160
+)
+
+// grayFrame is a decoded 8-bit luma frame.
+type grayFrame struct {
+	width, height int
+	pix           []byte
+}
+
+// at returns the luma value at (x, y).
+func (f grayFrame) at(x, y int) float64 {
+	return float64(f.pix[y*f.width+x])
+}
+
+// stddev returns the standard deviation of the frame's luma.
+func (f grayFrame) stddev() float64 {
+	var sum, sq float64
+	for _, p := range f.pix {
+		v := float64(p)
+		sum += v
+		sq += v * v
+	}
+	n := float64(len(f.pix))
+	mean := sum / n
+	return math.Sqrt(max(0, sq/n-mean*mean))
+}
+
+// decodeGrayFrames decodes every Nth frame of the first video stream into
+// width×height luma frames. Decoding stops at maxFrames, after span seconds
+// (see headSpan) or when the partial data runs out — ffmpeg's error exit is
+// ignored if frames were produced.
+func decodeGrayFrames(ctx context.Context, ffmpegPath, filePath string, span float64, width, height, every, maxFrames int) ([]grayFrame, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid frame size %dx%d", width, height)
+	}
+	decCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	args := append([]string{"-v", "quiet"}, inputArgs(filePath, span)...)
+	args = append(args,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf(`select=not(mod(n\,%d)),scale=%d:%d`, every, width, height),
+		"-fps_mode", "passthrough",
+		"-frames:v", fmt.Sprint(maxFrames),
+		"-f", "rawvideo",
+		"-pix_fmt", "gray",
+		"-",
+	)
+	return runGrayDecode(exec.CommandContext(decCtx, ffmpegPath, args...), width, height)
+}
+
+// runGrayDecode runs an ffmpeg command writing raw gray frames to stdout
+// and splits the output into frames.
+func runGrayDecode(cmd *exec.Cmd, width, height int) ([]grayFrame, error) {
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	runErr := cmd.Run()
+
+	size := width * height
+	data := stdout.Bytes()
+	if len(data) < size {
+		if runErr != nil {
+			return nil, fmt.Errorf("ffmpeg frame decode failed: %w", runErr)
+		}
+		return nil, fmt.Errorf("no frames decoded")
+	}
+
+	frames := make([]grayFrame, 0, len(data)/size)
+	for off := 0; off+size <= len(data); off += size {
+		frames = append(frames, grayFrame{width: width, height: height, pix: data[off : off+size]})
+	}
+	return frames, nil
+}
+
+// ApplyVideoAnalysis decodes sample frames of the main video from the
+// downloaded data and runs the picture analyzers on them: black-bar crop
+// (cropdetect over keyframes), scan type (idet), static "fake video"
+// detection, native resolution estimate and CAM/telesync detection (which
+// also decodes a short audio clip). Decoding is bounded to span seconds,
+// the gap-free head of the download (0 = the whole file). Needs ffmpeg;
+// results are left empty when decoding fails or the span is too short.
+// Modifies the result in-place.
+func ApplyVideoAnalysis(ctx context.Context, ffmpegPath string, result *ScanResult, filePath string, span float64) {
+	if result == nil || result.Video == nil || ffmpegPath == "" {
+		return
+	}
+	v := result.Video
+	if v.Height <= 0 || v.Height > frameMaxHeight {
+		return
+	}
+	if spanTooShort(span, minAnalysisSpan) {
+		log.Printf("  [%s] frame analysis skipped: only %.1fs downloaded contiguously", TruncHash(result.InfoHash), span)
+		return
+	}
+
+	if w, h, err := detectCrop(ctx, ffmpegPath, filePath, span); err == nil {
+		applyCrop(v, w, h)
+	} else {
+		log.Printf("  [%s] cropdetect skipped: %v", TruncHash(result.InfoHash), err)
+	}
+
+	if counts, err := runIdet(ctx, ffmpegPath, filePath, span); err == nil {
+		if scan, confidence, ok := classifyScanType(counts, v.FrameRate); ok {
+			v.ScanType = scan
+			v.ScanTypeConfidence = confidence
+		}
+	} else {
+		log.Printf("  [%s] idet skipped: %v", TruncHash(result.InfoHash), err)
+	}
+
+	sw, sh := staticFrameSize(v.Width, v.Height)
+	if small, err := decodeGrayFrames(ctx, ffmpegPath, filePath, span, sw, sh, staticSampleEvery, staticSampleMax); err == nil {
+		if suspect, ratio, ok := assessStaticVideo(small); ok {
+			result.FakeVideoSuspect = suspect
+			result.StaticFrameRatio = ratio
+			if suspect {
+				flagFakeVideo(result.Files, result.File)
+			}
+		}
+	} else {
+		log.Printf("  [%s] motion analysis skipped: %v", TruncHash(result.InfoHash), err)
+	}
+
+	frames, err := decodeGrayFrames(ctx, ffmpegPath, filePath, span, v.Width, v.Height, frameSampleEvery, frameSampleMax)
+	if err != nil {
+		log.Printf("  [%s] frame analysis skipped: %v", TruncHash(result.InfoHash), err)
+		return
+	}
+
+	if v.Height >= 720 {
+		if height, confidence, ok := estimateNativeHeight(frames); ok {
+			v.EstimatedNativeHeight = height
+			v.NativeHeightConfidence = confidence
+		}
+	}
+
+	var noiseFloor float64
+	hasNoiseFloor := false
+	if samples, err := decodePCM(ctx, ffmpegPath, filePath, span, camAudioSeconds, camAudioRate); err == nil {
+		noiseFloor, hasNoiseFloor = audioNoiseFloor(samples, camAudioRate)
+	}
+	result.SourceQualitySuspect, result.SourceQualitySignals = assessSourceQuality(frames, result.Audio, noiseFloor, hasNoiseFloor)
+
+	log.Printf("  [%s] frame analysis: %d frame(s), native height=%d (%.2f), source suspect=%v %v, static=%.2f fake=%v",
+		TruncHash(result.InfoHash), len(frames), v.EstimatedNativeHeight, v.NativeHeightConfidence,
+		result.SourceQualitySuspect, result.SourceQualitySignals, result.StaticFrameRatio, result.FakeVideoSuspect)
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"math"
+	"strconv"
+)
+
+const (
+	spanSafety      = 0.9   // margin on the estimate: bitrate varies across a file
+	spanAssumedRate = 2.5e6 // bytes/s assumed when the duration is unknown (20 Mbit/s)
+	minAnalysisSpan = 2.0   // seconds of gap-free data the decoders need to run
+)
+
+// analysisHeadSeconds is the playback a partial download fetches in one
+// piece after the container headers when the ffmpeg analyzers will run: the
+// widest spread windows (duplicate audio tracks), plus a window of slack,
+// before the margin headSpan takes off its estimate. analysisSampleCap
+// bounds it, so high-bitrate files get less and the analyzers fit their
+// windows into the shorter head (see upmixStarts and dummyStarts).
+const analysisHeadSeconds = (max(dummyMinRange, upmixMinRange) + dummyWindowSec) / spanSafety
+
+// analysisSampleCap is the most media a partial download fetches for the
+// analyzers: about 25 s of a 10 Mbit/s encode.
+const analysisSampleCap = 32 * 1024 * 1024
+
+// analysisSampleBytes returns how many bytes of a file of size bytes hold
+// analysisHeadSeconds of playback, at the average bitrate when the duration
+// is known and at spanAssumedRate otherwise (as headSpan does), up to
+// analysisSampleCap.
+func analysisSampleBytes(size int64, duration float64) int64 {
+	if size <= 0 {
+		return 0
+	}
+	want := int64(analysisHeadSeconds * spanAssumedRate)
+	if duration > 0 {
+		want = int64(math.Ceil(float64(size) * analysisHeadSeconds / duration))
+	}
+	return min(size, want, analysisSampleCap)
+}
+
+// headSpan converts the gap-free head of a partial download (see
+// Downloader.HeadBytes) to playback seconds. Index and tail ranges make the
+// file full length with holes in between, and a decoder that runs past the
+// head reads into them until its timeout, so the ffmpeg analyzers stop at
+// the span. The estimate assumes a constant bitrate, with a margin; without
+// a duration a high bitrate is assumed so it errs short. Returns 0 (no
+// bound) when the whole file is present.
+func headSpan(head, size int64, duration float64) float64 {
+	if size <= 0 || head >= size {
+		return 0
+	}
+	span := float64(head) / spanAssumedRate
+	if duration > 0 {
+		span = duration * float64(head) / float64(size)
+	}
+	return max(span*spanSafety, 0.001)
+}
+
+// inputArgs returns the ffmpeg input options for filePath, reading no more
+// than span seconds of it (0 reads it all).
+func inputArgs(filePath string, span float64) []string {
+	if span > 0 {
+		return []string{"-t", strconv.FormatFloat(span, 'f', 3, 64), "-i", filePath}
+	}
+	return []string{"-i", filePath}
+}
+
+// seekInputArgs is inputArgs starting start seconds into the file; the
+// bound stays span seconds from the beginning.
+func seekInputArgs(filePath string, span, start float64) []string {
+	if start <= 0 {
+		return inputArgs(filePath, span)
+	}
+	args := []string{"-ss", strconv.FormatFloat(start, 'f', 3, 64)}
+	if span > 0 {
+		args = append(args, "-t", strconv.FormatFloat(max(span-start, 0.001), 'f', 3, 64))
+	}
+	return append(args, "-i", filePath)
+}
+
+// spreadWindows returns the start times of n windows of the given length
+// spread evenly over the first limit seconds of a file, so an analyzer does
+// not judge a track by its opening (logos, title music, silence) alone.
+// Returns nil when limit is under minRange.
+func spreadWindows(limit, minRange float64, n, seconds int) []float64 {
+	if limit < minRange || n <= 0 {
+		return nil
+	}
+	starts := make([]float64, n)
+	for k := range starts {
+		starts[k] = max(0, limit*float64(k+1)/float64(n+1)-float64(seconds)/2)
+	}
+	return starts
+}
+
+// analysisLimit returns how far into the file the windowed analyzers may
+// read: the head span, or the whole duration when the file is complete
+// (0 when neither is known).
+func analysisLimit(result *ScanResult, span float64) float64 {
+	if span == 0 && result.Video != nil {
+		return result.Video.Duration
+	}
+	return span
+}
+
+// spanTooShort reports whether a bounded span holds less than need seconds.
+func spanTooShort(span, need float64) bool {
+	return span > 0 && span < need
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestHeadSpan(t *testing.T) {
+	tests := []struct {
+		name       string
+		head, size int64
+		duration   float64
+		want       float64
+	}{
+		{"whole file", 1000, 1000, 600, 0},
+		{"unknown size", 1000, 0, 600, 0},
+		{"tenth of the file", 100, 1000, 600, 54},
+		{"unknown duration", 25e6, 1e9, 0, 9},
+		{"nothing contiguous", 0, 1000, 600, 0.001},
+	}
+	for _, tt := range tests {
+		if got := headSpan(tt.head, tt.size, tt.duration); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("%s: headSpan = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInputArgs(t *testing.T) {
+	if got := inputArgs("a.mkv", 0); !reflect.DeepEqual(got, []string{"-i", "a.mkv"}) {
+		t.Errorf("unbounded: %v", got)
+	}
+	if got := inputArgs("a.mkv", 12.5); !reflect.DeepEqual(got, []string{"-t", "12.500", "-i", "a.mkv"}) {
+		t.Errorf("bounded: %v", got)
+	}
+	if got := seekInputArgs("a.mkv", 60, 20); !reflect.DeepEqual(got, []string{"-ss", "20.000", "-t", "40.000", "-i", "a.mkv"}) {
+		t.Errorf("seek within span: %v", got)
+	}
+	if got := seekInputArgs("a.mkv", 0, 20); !reflect.DeepEqual(got, []string{"-ss", "20.000", "-i", "a.mkv"}) {
+		t.Errorf("seek unbounded: %v", got)
+	}
+	if spanTooShort(0, minAnalysisSpan) || !spanTooShort(1, minAnalysisSpan) || spanTooShort(60, minAnalysisSpan) {
+		t.Error("spanTooShort: only a bounded span below the minimum is too short")
+	}
+}
+
+func TestHeadBytes(t *testing.T) {
+	dl := newTestDownloader(t)
+	if head, size := dl.HeadBytes(testV1Hash, "movie.mkv"); head != 0 || size != 0 {
+		t.Errorf("unknown torrent: got %d, %d", head, size)
+	}
+	infoBytes := testInfoBytes(t, true)
+	mi := metainfo.MetaInfo{InfoBytes: infoBytes}
+	tor, err := addMagnet(dl.client, buildMagnet(mi.HashInfoBytes().HexString(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tor.SetInfoBytes(infoBytes); err != nil {
+		t.Fatal(err)
+	}
+	if head, size := dl.HeadBytes(mi.HashInfoBytes().HexString(), "movie.mkv"); head != 0 || size != 1024 {
+		t.Errorf("nothing downloaded: got %d, %d", head, size)
+	}
+}
+
+func TestSpreadWindows(t *testing.T) {
+	if starts := spreadWindows(59, 60, 3, 6); starts != nil {
+		t.Errorf("short span should not be sampled, got %v", starts)
+	}
+	if spreadWindows(0, 60, 3, 6) != nil {
+		t.Error("unknown span should not be sampled")
+	}
+	starts := spreadWindows(600, 60, 3, 6)
+	if len(starts) != 3 || starts[0] < 100 || starts[2]+6 > 600 {
+		t.Errorf("windows should be spread over the span, got %v", starts)
+	}
+
+	if got := analysisLimit(&ScanResult{Video: &VideoInfo{Duration: 5400}}, 0); got != 5400 {
+		t.Errorf("complete file: limit %v, want the duration", got)
+	}
+	if got := analysisLimit(&ScanResult{Video: &VideoInfo{Duration: 5400}}, 90); got != 90 {
+		t.Errorf("partial file: limit %v, want the head span", got)
+	}
+}
@@ -1,16 +1,26 @@
 package internal
 
 import (
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/anacrolix/torrent/metainfo"
+	infohash_v2 "github.com/anacrolix/torrent/types/infohash-v2"
 )
 
+// sha256MultihashPrefix starts the hex multihash of a SHA-256 digest, as used
+// by v2 info hashes in urn:btmh magnets.
+const sha256MultihashPrefix = "1220"
+
 // NormalizeInput takes a raw input string (info hash, magnet link, .torrent path,
 // or directory of .torrent files) and returns the extracted info hashes.
+// Hashes are lowercase hex: the v1 hash (40 chars) for v1 and hybrid
+// torrents, the v2 hash (64 chars) for v2-only ones.
 func NormalizeInput(input string) ([]string, error) {
 	input = strings.TrimSpace(input)
 	if input == "" {
@@ -19,11 +29,17 @@ func NormalizeInput(input string) ([]string, error) {
 
 	// Magnet link
 	if strings.HasPrefix(input, "magnet:") {
-		m, err := metainfo.ParseMagnetUri(input)
+		m, err := metainfo.ParseMagnetV2Uri(input)
 		if err != nil {
 			return nil, fmt.Errorf("invalid magnet link: %w", err)
 		}
-		return []string{m.InfoHash.HexString()}, nil
+		switch {
+		case m.InfoHash.Ok:
+			return []string{m.InfoHash.Value.HexString()}, nil
+		case m.V2InfoHash.Ok:
+			return []string{m.V2InfoHash.Value.HexString()}, nil
+		}
+		return nil, errors.New("invalid magnet link: no urn:btih or urn:btmh info hash")
 	}
 
 	// Check if it's a file or directory path
@@ -44,7 +60,40 @@ func NormalizeInput(input string) ([]string, error) {
 	}
 
 	// Assume raw info hash
-	return []string{strings.ToLower(input)}, nil
+	return []string{normalizeRawHash(input)}, nil
+}
+
+// normalizeRawHash converts a raw info hash to lowercase hex. v1 hashes may
+// be hex or base32 (32 chars); v2 hashes hex or a SHA-256 multihash (1220
+// prefix). Anything else is only lowercased.
+func normalizeRawHash(s string) string {
+	switch {
+	case len(s) == 32:
+		if b, err := base32.StdEncoding.DecodeString(strings.ToUpper(s)); err == nil {
+			return hex.EncodeToString(b)
+		}
+	case len(s) == len(sha256MultihashPrefix)+2*infohash_v2.Size && strings.HasPrefix(s, sha256MultihashPrefix):
+		return strings.ToLower(s[len(sha256MultihashPrefix):])
+	}
+	return strings.ToLower(s)
+}
+
+// ValidInfoHash reports whether h is a v1 (40-char) or v2 (64-char) hex
+// info hash.
+func ValidInfoHash(h string) bool {
+	if len(h) != 40 && len(h) != 2*infohash_v2.Size {
+		return false
+	}
+	_, err := hex.DecodeString(h)
+	return err == nil
+}
+
+// hashVersions splits an info hash into its v1 and v2 forms by length.
+func hashVersions(infoHash string) (v1, v2 string) {
+	if len(infoHash) == 2*infohash_v2.Size {
+		return "", infoHash
+	}
+	return infoHash, ""
 }
 
 func hashFromTorrentFile(path string) (string, error) {
@@ -52,6 +101,15 @@ func hashFromTorrentFile(path string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
 	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	// v2-only torrents have no v1 swarm: the SHA-1 of their info is unused
+	if !info.HasV1() && info.HasV2() {
+		v2 := infohash_v2.HashBytes(mi.InfoBytes)
+		return v2.HexString(), nil
+	}
 	return mi.HashInfoBytes().HexString(), nil
 }
 
@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	infohash_v2 "github.com/anacrolix/torrent/types/infohash-v2"
+)
+
+var (
+	testV1Hash = strings.Repeat("ab", 20)
+	testV2Hash = strings.Repeat("cd", 32)
+)
+
+func TestNormalizeInput_Hashes(t *testing.T) {
+	v1Bytes, _ := hex.DecodeString(testV1Hash)
+	v1Base32 := base32.StdEncoding.EncodeToString(v1Bytes)
+
+	tests := []struct {
+		name, input, want string
+	}{
+		{"v1 hex", strings.ToUpper(testV1Hash), testV1Hash},
+		{"v1 base32", v1Base32, testV1Hash},
+		{"v1 base32 lowercase", strings.ToLower(v1Base32), testV1Hash},
+		{"v2 hex", strings.ToUpper(testV2Hash), testV2Hash},
+		{"v2 multihash", "1220" + testV2Hash, testV2Hash},
+		{"btih magnet", "magnet:?xt=urn:btih:" + testV1Hash + "&dn=x", testV1Hash},
+		{"btih base32 magnet", "magnet:?xt=urn:btih:" + v1Base32, testV1Hash},
+		{"btmh magnet", "magnet:?xt=urn:btmh:1220" + testV2Hash, testV2Hash},
+		{"hybrid magnet", "magnet:?xt=urn:btih:" + testV1Hash + "&xt=urn:btmh:1220" + testV2Hash, testV1Hash},
+		{"unknown", "NotAHash", "notahash"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeInput(tt.input)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := NormalizeInput("magnet:?dn=nohash"); err == nil {
+		t.Error("expected error for a magnet without info hash")
+	}
+}
+
+func TestValidInfoHash(t *testing.T) {
+	for h, want := range map[string]bool{
+		testV1Hash:               true,
+		testV2Hash:               true,
+		"1220" + testV2Hash:      false,
+		strings.Repeat("zz", 20): false,
+		testV1Hash[:38]:          false,
+		base32.StdEncoding.EncodeToString(make([]byte, 20)): false,
+	} {
+		if got := ValidInfoHash(h); got != want {
+			t.Errorf("ValidInfoHash(%q) = %v, want %v", h, got, want)
+		}
+	}
+}
+
+// testInfoBytes returns bencoded info dictionaries: v2-only, or hybrid when
+// v1 is set.
+func testInfoBytes(t *testing.T, v1 bool) []byte {
+	t.Helper()
+	info := map[string]any{
+		"name":         "movie.mkv",
+		"piece length": 16384,
+		"meta version": 2,
+		"file tree": map[string]any{
+			"movie.mkv": map[string]any{"": map[string]any{"length": 1024, "pieces root": strings.Repeat("r", 32)}},
+		},
+	}
+	if v1 {
+		info["length"] = 1024
+		info["pieces"] = strings.Repeat("p", 20)
+	}
+	b, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHashFromTorrentFile_V2(t *testing.T) {
+	dir := t.TempDir()
+	for _, hybrid := range []bool{false, true} {
+		infoBytes := testInfoBytes(t, hybrid)
+		mi := metainfo.MetaInfo{InfoBytes: infoBytes}
+		path := filepath.Join(dir, "test.torrent")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mi.Write(f)
+		f.Close()
+
+		got, err := hashFromTorrentFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := infohash_v2.HashBytes(infoBytes)
+		if hybrid {
+			if got != mi.HashInfoBytes().HexString() {
+				t.Errorf("hybrid torrent should be keyed by its v1 hash, got %s", got)
+			}
+		} else if got != want.HexString() {
+			t.Errorf("v2 torrent should be keyed by its v2 hash, got %s", got)
+		}
+	}
+}
+
+func TestBuildMagnet_V2(t *testing.T) {
+	m := buildMagnet(testV2Hash, nil)
+	if m != "magnet:?xt=urn:btmh:1220"+testV2Hash {
+		t.Fatalf("unexpected magnet %s", m)
+	}
+	spec, err := torrent.TorrentSpecFromMagnetUri(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spec.InfoHashV2.Ok || spec.InfoHashV2.Value.HexString() != testV2Hash {
+		t.Errorf("magnet does not carry the v2 hash: %v", spec.InfoHashV2)
+	}
+}
+
+func TestTorrentKey(t *testing.T) {
+	if got := torrentKey(testV1Hash).HexString(); got != testV1Hash {
+		t.Errorf("v1 key = %s", got)
+	}
+	if got := torrentKey(testV2Hash).HexString(); got != testV2Hash[:40] {
+		t.Errorf("v2 key should be the truncated v2 hash, got %s", got)
+	}
+	if (torrentKey("garbage") != metainfo.Hash{}) || (torrentKey(strings.Repeat("zz", 32)) != metainfo.Hash{}) {
+		t.Error("invalid hashes should give the zero key")
+	}
+}
+
+func TestAddMagnet_V2Only(t *testing.T) {
+	dl := newTestDownloader(t)
+	infoBytes := testInfoBytes(t, true)
+	v2 := infohash_v2.HashBytes(infoBytes)
+	infoHash := v2.HexString()
+
+	tor, err := addMagnet(dl.client, buildMagnet(infoHash, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1, got := dl.InfoHashes(infoHash); v1 != "" || got != infoHash {
+		t.Errorf("before metadata: v1=%q v2=%q", v1, got)
+	}
+	if err := tor.SetInfoBytes(infoBytes); err != nil {
+		t.Fatalf("metadata rejected: %v", err)
+	}
+	if _, ok := dl.client.Torrent(torrentKey(infoHash)); !ok {
+		t.Fatal("torrent not found by its v2 key")
+	}
+	mi := metainfo.MetaInfo{InfoBytes: infoBytes}
+	v1, got := dl.InfoHashes(infoHash)
+	if got != infoHash || v1 != mi.HashInfoBytes().HexString() {
+		t.Errorf("hybrid metadata should give both hashes: v1=%q v2=%q", v1, got)
+	}
+}
+
+// newTestDownloader returns an offline downloader (no DHT, trackers or
+// listening peers).
+func newTestDownloader(t *testing.T) *Downloader {
+	t.Helper()
+	tcfg := torrent.NewDefaultClientConfig()
+	tcfg.DataDir = t.TempDir()
+	tcfg.NoDHT = true
+	tcfg.DisableTrackers = true
+	tcfg.NoDefaultPortForwarding = true
+	tcfg.ListenHost = func(string) string { return "127.0.0.1" }
+	tcfg.DisableIPv6 = true
+	tcfg.ListenPort = 0
+	client, err := torrent.NewClient(tcfg)
+	if err != nil {
+		t.Skipf("torrent client unavailable: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return &Downloader{client: client}
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"sort"
 	"strings"
+	"unicode"
 )
 
 // langNormalize maps ISO 639-2/B, 639-2/T, and 639-1 codes to ISO 639-1.
@@ -60,9 +61,16 @@ func NormalizeLang(raw string) string {
 
 // ComputeLanguages extracts unique ISO 639-1 language codes from audio tracks.
 // It merges with any existing languages, replacing ambiguous tags like "multi"/"dual".
+// Only main-program tracks are counted: commentary, audio description and
+// similar auxiliary tracks do not make a release multi-language. Dummy
+// tracks are skipped too: a track flagged `silent` or `duplicate_of` is
+// padding, not a dub, even when it carries its own language tag.
 func ComputeLanguages(existing []string, audioTracks []AudioTrack) []string {
 	detected := make(map[string]struct{})
 	for _, t := range audioTracks {
+		if !t.IsMainProgram() || t.Silent || t.DuplicateOf != nil {
+			continue
+		}
 		lang := t.Lang
 		if lang != "" && lang != "und" && len(lang) <= 3 {
 			detected[lang] = struct{}{}
@@ -114,3 +122,139 @@ func ComputeLanguages(existing []string, audioTracks []AudioTrack) []string {
 	sort.Strings(result)
 	return result
 }
+
+// langNames maps lowercase language names (English and native) found in
+// file names and track titles to ISO 639-1 codes.
+var langNames = map[string]string{
+	"english": "en",
+	"spanish": "es", "español": "es", "espanol": "es", "castellano": "es", "latino": "es",
+	"french": "fr", "français": "fr", "francais": "fr", "vff": "fr", "vfq": "fr",
+	"german": "de", "deutsch": "de",
+	"italian": "it", "italiano": "it",
+	"portuguese": "pt", "português": "pt", "portugues": "pt", "brazilian": "pt",
+	"russian": "ru", "japanese": "ja", "korean": "ko",
+	"chinese": "zh", "mandarin": "zh", "cantonese": "zh",
+	"hindi": "hi", "arabic": "ar", "dutch": "nl", "nederlands": "nl",
+	"polish": "pl", "polski": "pl", "turkish": "tr", "türkçe": "tr",
+	"swedish": "sv", "svenska": "sv", "norwegian": "no", "norsk": "no",
+	"danish": "da", "dansk": "da", "finnish": "fi", "suomi": "fi",
+	"czech": "cs", "hungarian": "hu", "magyar": "hu", "romanian": "ro",
+	"greek": "el", "thai": "th", "vietnamese": "vi", "indonesian": "id",
+	"hebrew": "he", "ukrainian": "uk", "catalan": "ca", "català": "ca",
+	"bulgarian": "bg", "croatian": "hr", "serbian": "sr", "slovenian": "sl",
+	"lithuanian": "lt", "latvian": "lv", "estonian": "et",
+}
+
+// LangFromName resolves a language name or ISO 639 code token (e.g. "Spanish",
+// "spa", "en") to an ISO 639-1 code. Returns "" if the token is not a language.
+func LangFromName(token string) string {
+	lower := strings.ToLower(token)
+	if code, ok := langNames[lower]; ok {
+		return code
+	}
+	if code, ok := langNormalize[lower]; ok {
+		return code
+	}
+	// Regional variants: "pt-br", "es_419", "en-US"
+	if i := strings.IndexAny(lower, "-_"); i == 2 || i == 3 {
+		if code, ok := langNormalize[lower[:i]]; ok {
+			return code
+		}
+	}
+	return ""
+}
+
+// stopwords holds frequent, fairly distinctive words per language used to
+// guess the language of subtitle text.
+var stopwords = map[string][]string{
+	"en": {"the", "you", "and", "that", "what", "this", "is", "it's", "don't", "i'm", "have", "are", "with", "your", "just"},
+	"es": {"que", "el", "los", "qué", "pero", "por", "una", "está", "lo", "las", "muy", "eso", "aquí", "usted", "estoy"},
+	"fr": {"le", "les", "et", "est", "je", "vous", "pas", "c'est", "une", "qui", "des", "ne", "nous", "oui", "ça"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ich", "sie", "ein", "du", "mit", "wir", "was", "auf", "mir"},
+	"it": {"che", "non", "il", "è", "per", "sono", "mi", "ti", "questo", "ho", "cosa", "della", "gli", "hai", "sei"},
+	"pt": {"não", "você", "um", "do", "da", "isso", "para", "com", "uma", "eu", "está", "muito", "ele", "aqui", "sim"},
+	"nl": {"het", "een", "ik", "je", "niet", "dat", "van", "wat", "zijn", "maar", "heb", "hij", "wij", "ook", "dit"},
+}
+
+// scriptLangs maps Unicode scripts that identify a language on their own.
+var scriptLangs = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+}
+
+// DetectTextLanguage guesses the language of a block of text (e.g. subtitle
+// dialogue). Non-Latin scripts are identified by character ranges; Latin-script
+// languages by stopword frequency. Returns "" when the text is too short or
+// no language clearly wins.
+func DetectTextLanguage(text string) string {
+	letters := 0
+	scriptCounts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, sl := range scriptLangs {
+			if unicode.Is(sl.table, r) {
+				scriptCounts[sl.lang]++
+				break
+			}
+		}
+	}
+	if letters < 50 {
+		return ""
+	}
+
+	// Japanese text mixes kana with Han characters — any significant kana wins.
+	if scriptCounts["ja"]*10 >= letters {
+		return "ja"
+	}
+	best, bestCount := "", 0
+	for lang, n := range scriptCounts {
+		if n > bestCount {
+			best, bestCount = lang, n
+		}
+	}
+	if bestCount*10 >= letters*3 {
+		return best
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	scores := make(map[string]int)
+	for _, w := range words {
+		for lang, list := range stopwords {
+			for _, sw := range list {
+				if w == sw {
+					scores[lang]++
+					break
+				}
+			}
+		}
+	}
+
+	best, bestScore, secondScore := "", 0, 0
+	for lang, n := range scores {
+		switch {
+		case n > bestScore:
+			best, secondScore, bestScore = lang, bestScore, n
+		case n > secondScore:
+			secondScore = n
+		}
+	}
+	if bestScore < 20 || bestScore*2 < secondScore*3 {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLangFromName(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"English", "en"},
+		{"eng", "en"},
+		{"en", "en"},
+		{"Castellano", "es"},
+		{"latino", "es"},
+		{"pt-BR", "pt"},
+		{"es_419", "es"},
+		{"Français", "fr"},
+		{"1080p", ""},
+		{"movie", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			if got := LangFromName(tt.token); got != tt.want {
+				t.Errorf("LangFromName(%q) = %q, want %q", tt.token, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectTextLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "What is this? I don't know what you are doing with the car. It's just that you have to go.", "en"},
+		{"spanish", "¿Qué es eso? No sé lo que estás haciendo con el coche. Pero los chicos están aquí y usted no.", "es"},
+		{"french", "Je ne sais pas. C'est une histoire qui est pas facile, et vous les aimez. Oui, nous sommes des amis.", "fr"},
+		{"german", "Ich weiß nicht, was das ist. Sie ist nicht hier und wir haben das Auto mit der Frau und die Kinder.", "de"},
+		{"russian", "Я не знаю, что это такое. Мы должны идти домой прямо сейчас, потому что уже поздно.", "ru"},
+		{"japanese", "これは何ですか？私は知りません。今すぐ家に帰らなければなりません。遅いですから。お願いします。", "ja"},
+		{"too short", "Hello", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Repeat to reach the minimum sample size
+			text := strings.Repeat(tt.text+"\n", 6)
+			if tt.name == "too short" {
+				text = tt.text
+			}
+			if got := DetectTextLanguage(text); got != tt.want {
+				t.Errorf("DetectTextLanguage(%s) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectTextLanguage_NoStopwords(t *testing.T) {
+	text := strings.Repeat("lorem ipsum dolor sit amet consectetur adipiscing elit ", 10)
+	if got := DetectTextLanguage(text); got != "" {
+		t.Errorf("expected no detection, got %q", got)
+	}
+}
@@ -50,9 +50,10 @@ var (
 )
 
 // DetectAudioLanguage extracts a short audio clip from the video file and uses
-// whisper.cpp to detect the spoken language. Returns nil if detection fails
-// or is not applicable.
-func DetectAudioLanguage(ctx context.Context, cfg LangDetectConfig, videoPath string, audioStreamIndex int) (*LangDetectResult, error) {
+// whisper.cpp to detect the spoken language, reading no more than span
+// seconds of the file (0 = no bound). Returns nil if detection fails or is
+// not applicable.
+func DetectAudioLanguage(ctx context.Context, cfg LangDetectConfig, videoPath string, span float64, audioStreamIndex int) (*LangDetectResult, error) {
 	if !cfg.Enabled {
 		return nil, nil
 	}
@@ -68,8 +69,8 @@ func DetectAudioLanguage(ctx context.Context, cfg LangDetectConfig, videoPath st
 	ffmpegCtx, ffmpegCancel := context.WithTimeout(ctx, 30*time.Second)
 	defer ffmpegCancel()
 
-	ffmpegCmd := exec.CommandContext(ffmpegCtx, cfg.FFmpegPath,
-		"-i", videoPath,
+	args := inputArgs(videoPath, span)
+	args = append(args,
 		"-t", "30", // 30 seconds
 		"-map", fmt.Sprintf("0:a:%d", audioStreamIndex), // select specific audio stream
 		"-ar", "16000", // 16kHz sample rate
@@ -78,6 +79,7 @@ func DetectAudioLanguage(ctx context.Context, cfg LangDetectConfig, videoPath st
 		"-y", // overwrite
 		wavPath,
 	)
+	ffmpegCmd := exec.CommandContext(ffmpegCtx, cfg.FFmpegPath, args...)
 	ffmpegCmd.Stderr = nil // suppress ffmpeg output
 	ffmpegCmd.Stdout = nil
 
@@ -194,9 +196,7 @@ func resolveLangDetectInner() LangDetectConfig {
 	}
 
 	// Find ffmpeg
-	cfg.FFmpegPath = findBinary("ffmpeg",
-		os.Getenv("FFMPEG_PATH"),
-	)
+	cfg.FFmpegPath = ResolveFFmpeg("")
 	if cfg.FFmpegPath == "" {
 		return cfg
 	}
@@ -242,7 +242,7 @@ func effectiveMaxTracks(cfg LangDetectConfig) int {
 func undefinedTrackIndices(audio []AudioTrack) []int {
 	var indices []int
 	for i, track := range audio {
-		if isUnknownLang(track.Lang) {
+		if isUnknownLang(track.Lang) && !track.Silent {
 			indices = append(indices, i)
 		}
 	}
@@ -286,10 +286,11 @@ func homeDir() string {
 }
 
 // ApplyLangDetection runs language detection on a scan result if applicable.
-// Analyzes all audio tracks with unknown language using Whisper.
+// Analyzes all audio tracks with unknown language using Whisper, within the
+// first span seconds of the file (see headSpan).
 // Modifies the result in-place: updates audio track lang and adds detection info.
-func ApplyLangDetection(ctx context.Context, cfg LangDetectConfig, result *ScanResult, videoPath string) {
-	if !ShouldDetectLanguage(result) {
+func ApplyLangDetection(ctx context.Context, cfg LangDetectConfig, result *ScanResult, videoPath string, span float64) {
+	if !ShouldDetectLanguage(result) || spanTooShort(span, minAnalysisSpan) {
 		return
 	}
 
@@ -304,7 +305,7 @@ func ApplyLangDetection(ctx context.Context, cfg LangDetectConfig, result *ScanR
 		TruncHash(result.InfoHash), len(indices))
 
 	for _, i := range indices {
-		detected, err := DetectAudioLanguage(ctx, cfg, videoPath, i)
+		detected, err := DetectAudioLanguage(ctx, cfg, videoPath, span, i)
 		if err != nil {
 			log.Printf("  [%s] language detection failed for track %d: %v", TruncHash(result.InfoHash), i, err)
 			continue
@@ -28,8 +28,11 @@ type ffprobeStream struct {
 	CodecName      string            `json:"codec_name"`
 	Profile        string            `json:"profile"`
 	Channels       int               `json:"channels"`
+	BitRate        string            `json:"bit_rate"`
 	Width          int               `json:"width"`
 	Height         int               `json:"height"`
+	SampleAspect   string            `json:"sample_aspect_ratio"`
+	FieldOrder     string            `json:"field_order"`
 	BitsPerRaw     string            `json:"bits_per_raw_sample"`
 	PixFmt         string            `json:"pix_fmt"`
 	ColorSpace     string            `json:"color_space"`
@@ -67,8 +70,10 @@ func ExtractMediaInfo(ctx context.Context, ffprobePath, filePath string) (*ScanR
 
 	output, err := cmd.Output()
 	if err != nil {
-		// Check if the file even exists
-		if info, statErr := os.Stat(filePath); statErr != nil {
+		// Check if the file even exists (in-memory downloads are always there)
+		if isMemoryURL(filePath) {
+			return nil, fmt.Errorf("ffprobe failed (file=%s): %s", filePath, stderr.String())
+		} else if info, statErr := os.Stat(filePath); statErr != nil {
 			return nil, fmt.Errorf("ffprobe: file not found: %s", filePath)
 		} else {
 			return nil, fmt.Errorf("ffprobe failed (file=%s, size=%d): %s", filePath, info.Size(), stderr.String())
@@ -103,6 +108,13 @@ func ExtractMediaInfo(ctx context.Context, ffprobePath, filePath string) (*ScanR
 			if s.Disposition["default"] == 1 {
 				track.Default = true
 			}
+			// MKV muxers store the bitrate in a BPS statistics tag instead
+			if br, err := strconv.Atoi(s.BitRate); err == nil {
+				track.Bitrate = br
+			} else if br, err := strconv.Atoi(tagValue(s.Tags, "BPS")); err == nil {
+				track.Bitrate = br
+			}
+			track.Role = ClassifyAudioRole(s.Disposition, track.Title)
 			audioTracks = append(audioTracks, track)
 
 		case "subtitle":
@@ -120,6 +132,7 @@ func ExtractMediaInfo(ctx context.Context, ffprobePath, filePath string) (*ScanR
 			if s.Disposition["default"] == 1 {
 				track.Default = true
 			}
+			classifySubtitle(&track, s.Disposition)
 			subtitleTracks = append(subtitleTracks, track)
 
 		case "video":
@@ -175,6 +188,20 @@ func ExtractMediaInfo(ctx context.Context, ffprobePath, filePath string) (*ScanR
 				}
 			}
 
+			// Scan type from container flags; refined by idet when ffmpeg is available
+			if s.FieldOrder != "" && s.FieldOrder != "unknown" {
+				vi.FieldOrder = s.FieldOrder
+				if st := scanTypeFromFieldOrder(s.FieldOrder); st != "" {
+					vi.ScanType = st
+					vi.ScanTypeConfidence = fieldOrderConf
+				}
+			}
+
+			// Anamorphic pixels (DVD, some HDTV)
+			if s.SampleAspect != "" && s.SampleAspect != "1:1" && s.SampleAspect != "0:1" {
+				vi.SampleAspectRatio = s.SampleAspect
+			}
+
 			// Profile
 			if s.Profile != "" {
 				vi.Profile = s.Profile
@@ -256,6 +283,32 @@ func ResolveFFprobe(explicit string) (string, error) {
 	return "", fmt.Errorf("ffprobe not found. Install ffmpeg or provide --ffprobe path")
 }
 
+// ResolveFFmpeg finds the ffmpeg binary used for audio/video decoding
+// (language detection, spectral analysis). Search order:
+// 1. FFMPEG_PATH env var
+// 2. Next to the resolved ffprobe (static builds ship both)
+// 3. "ffmpeg" in PATH
+// 4. Adjacent to the current executable
+// Returns "" if ffmpeg is not available — features depending on it are optional.
+func ResolveFFmpeg(ffprobePath string) string {
+	name := "ffmpeg"
+	if runtime.GOOS == "windows" {
+		name = "ffmpeg.exe"
+	}
+	var candidates []string
+	candidates = append(candidates, os.Getenv("FFMPEG_PATH"))
+	if ffprobePath != "" {
+		candidates = append(candidates, filepath.Join(filepath.Dir(ffprobePath), name))
+	}
+	if p := findBinary("ffmpeg", candidates...); p != "" {
+		return p
+	}
+	if exePath, err := os.Executable(); err == nil {
+		return findFile(filepath.Join(filepath.Dir(exePath), name))
+	}
+	return ""
+}
+
 // tagValue gets a tag value case-insensitively (ffprobe uses both "language" and "LANGUAGE").
 func tagValue(tags map[string]string, key string) string {
 	if v, ok := tags[key]; ok {
@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// memoryStorage keeps piece data in RAM instead of files under TempDir.
+// Pieces are allocated on their first write and counted against a byte cap
+// shared by all torrents of the Downloader; a torrent that would exceed it
+// is flagged and its download fails fast instead of stalling.
+type memoryStorage struct {
+	cap int64
+
+	mu       sync.RWMutex
+	used     int64
+	torrents map[metainfo.Hash]*memoryTorrent
+}
+
+// memoryTorrent holds the pieces of one torrent.
+type memoryTorrent struct {
+	s        *memoryStorage
+	hash     metainfo.Hash
+	info     *metainfo.Info
+	pieces   map[int][]byte
+	complete map[int]bool
+	capHit   bool
+}
+
+// memoryPiece implements storage.PieceImpl for one piece of a memoryTorrent.
+type memoryPiece struct {
+	t      *memoryTorrent
+	index  int
+	length int64
+}
+
+func newMemoryStorage(capBytes int64) *memoryStorage {
+	return &memoryStorage{cap: capBytes, torrents: make(map[metainfo.Hash]*memoryTorrent)}
+}
+
+// OpenTorrent implements storage.ClientImpl.
+func (s *memoryStorage) OpenTorrent(_ context.Context, info *metainfo.Info, infoHash metainfo.Hash) (storage.TorrentImpl, error) {
+	t := &memoryTorrent{
+		s:        s,
+		hash:     infoHash,
+		info:     info,
+		pieces:   make(map[int][]byte),
+		complete: make(map[int]bool),
+	}
+	s.mu.Lock()
+	s.torrents[infoHash] = t
+	s.mu.Unlock()
+
+	return storage.TorrentImpl{
+		Piece: func(p metainfo.Piece) storage.PieceImpl {
+			return &memoryPiece{t: t, index: p.Index(), length: p.Length()}
+		},
+		Close: t.close,
+	}, nil
+}
+
+// Close implements storage.ClientImplCloser.
+func (s *memoryStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.torrents = make(map[metainfo.Hash]*memoryTorrent)
+	s.used = 0
+	return nil
+}
+
+// capReached reports whether a torrent was refused memory for a piece.
+func (s *memoryStorage) capReached(infoHash metainfo.Hash) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.torrents[infoHash]
+	return ok && t.capHit
+}
+
+// torrent returns the data of an open torrent.
+func (s *memoryStorage) torrent(infoHash metainfo.Hash) (*memoryTorrent, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.torrents[infoHash]
+	return t, ok
+}
+
+// close frees the torrent's pieces when it is dropped from the client.
+func (t *memoryTorrent) close() error {
+	s := t.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, buf := range t.pieces {
+		s.used -= int64(len(buf))
+	}
+	t.pieces = nil
+	if s.torrents[t.hash] == t {
+		delete(s.torrents, t.hash)
+	}
+	return nil
+}
+
+// ReadAt reads torrent data at a torrent-wide offset. Pieces that are not
+// complete read as zeros, like the holes of a sparse file on disk.
+func (t *memoryTorrent) ReadAt(p []byte, off int64) (int, error) {
+	t.s.mu.RLock()
+	defer t.s.mu.RUnlock()
+
+	total := t.info.TotalLength()
+	if off >= total {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), total-off))
+	pieceLength := t.info.PieceLength
+	for done := 0; done < n; {
+		pos := off + int64(done)
+		index := int(pos / pieceLength)
+		inPiece := pos % pieceLength
+		chunk := int(min(int64(n-done), pieceLength-inPiece))
+		dst := p[done : done+chunk]
+		if buf := t.pieces[index]; t.complete[index] && inPiece < int64(len(buf)) {
+			copied := copy(dst, buf[inPiece:])
+			clear(dst[copied:])
+		} else {
+			clear(dst)
+		}
+		done += chunk
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (p *memoryPiece) ReadAt(b []byte, off int64) (int, error) {
+	p.t.s.mu.RLock()
+	defer p.t.s.mu.RUnlock()
+	buf := p.t.pieces[p.index]
+	if buf == nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if off >= int64(len(buf)) {
+		return 0, io.EOF
+	}
+	n := copy(b, buf[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt implements io.WriterAt.
+func (p *memoryPiece) WriteAt(b []byte, off int64) (int, error) {
+	s := p.t.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.t.pieces == nil {
+		return 0, errors.New("torrent storage closed")
+	}
+	buf := p.t.pieces[p.index]
+	if buf == nil {
+		if s.cap > 0 && s.used+p.length > s.cap {
+			p.t.capHit = true
+			return 0, fmt.Errorf("memory cap of %dMB reached", s.cap/1024/1024)
+		}
+		buf = make([]byte, p.length)
+		p.t.pieces[p.index] = buf
+		s.used += p.length
+	}
+	if off >= int64(len(buf)) {
+		return 0, io.ErrShortWrite
+	}
+	n := copy(buf[off:], b)
+	if n < len(b) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// MarkComplete implements storage.PieceImpl.
+func (p *memoryPiece) MarkComplete() error {
+	p.t.s.mu.Lock()
+	defer p.t.s.mu.Unlock()
+	p.t.complete[p.index] = true
+	return nil
+}
+
+// MarkNotComplete implements storage.PieceImpl.
+func (p *memoryPiece) MarkNotComplete() error {
+	p.t.s.mu.Lock()
+	defer p.t.s.mu.Unlock()
+	delete(p.t.complete, p.index)
+	return nil
+}
+
+// Completion implements storage.PieceImpl. The state always starts empty:
+// unlike the SQLite database on disk, nothing survives a previous run.
+func (p *memoryPiece) Completion() storage.Completion {
+	p.t.s.mu.RLock()
+	defer p.t.s.mu.RUnlock()
+	return storage.Completion{Ok: true, Complete: p.t.complete[p.index]}
+}
+
+// memoryFile reads one file of an in-memory torrent.
+type memoryFile struct {
+	*io.SectionReader
+}
+
+// Close implements io.Closer.
+func (memoryFile) Close() error { return nil }
+
+// memoryServer serves the files of in-memory torrents over loopback HTTP so
+// ffprobe and ffmpeg can read them, seeking with Range requests, as they
+// would read a file path.
+type memoryServer struct {
+	storage *memoryStorage
+	srv     *http.Server
+	base    string
+}
+
+func startMemoryServer(s *memoryStorage) (*memoryServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listen for in-memory file server: %w", err)
+	}
+	m := &memoryServer{storage: s, base: "http://" + ln.Addr().String()}
+	m.srv = &http.Server{Handler: m, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := m.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("in-memory file server stopped: %v", err)
+		}
+	}()
+	return m, nil
+}
+
+// fileURL returns the URL a torrent file is served at:
+// /<info hash>/<offset>-<length>/<file name>. The file name keeps the
+// extension so ffprobe's format probing sees it.
+func (m *memoryServer) fileURL(t *torrent.Torrent, file *torrent.File) string {
+	return fmt.Sprintf("%s/%s/%d-%d/%s", m.base, t.InfoHash().HexString(),
+		file.Offset(), file.Length(), url.PathEscape(path.Base(file.DisplayPath())))
+}
+
+// open returns a reader for the file behind a URL path made by fileURL.
+func (m *memoryServer) open(urlPath string) (memoryFile, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(urlPath, "/"), "/", 3)
+	if len(parts) != 3 {
+		return memoryFile{}, "", os.ErrNotExist
+	}
+	var hash metainfo.Hash
+	if err := hash.FromHexString(parts[0]); err != nil {
+		return memoryFile{}, "", os.ErrNotExist
+	}
+	offStr, lenStr, ok := strings.Cut(parts[1], "-")
+	off, err1 := strconv.ParseInt(offStr, 10, 64)
+	length, err2 := strconv.ParseInt(lenStr, 10, 64)
+	if !ok || err1 != nil || err2 != nil || off < 0 || length < 0 {
+		return memoryFile{}, "", os.ErrNotExist
+	}
+	t, found := m.storage.torrent(hash)
+	if !found || off+length > t.info.TotalLength() {
+		return memoryFile{}, "", os.ErrNotExist
+	}
+	return memoryFile{io.NewSectionReader(t, off, length)}, parts[2], nil
+}
+
+// ServeHTTP implements http.Handler.
+func (m *memoryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, name, err := m.open(r.URL.Path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, name, time.Time{}, f)
+}
+
+// Close stops the server.
+func (m *memoryServer) Close() error {
+	return m.srv.Close()
+}
+
+// isMemoryURL reports whether a downloaded file path is the URL of an
+// in-memory download rather than a path on disk.
+func isMemoryURL(p string) bool {
+	return strings.HasPrefix(p, "http://127.0.0.1:")
+}
+
+// memoryFileClient reads in-memory files from the loopback server. The
+// timeout covers the whole read, so a wedged server can't hang a scan; it is
+// long enough for a slow consumer such as a VirusTotal upload streaming it.
+var memoryFileClient = &http.Client{Timeout: 5 * time.Minute}
+
+// openDownloadedFile opens a file returned by the Downloader, whether it is
+// on disk or served from memory.
+func openDownloadedFile(p string) (io.ReadCloser, error) {
+	if !isMemoryURL(p) {
+		return os.Open(p)
+	}
+	resp, err := memoryFileClient.Get(p)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("open %s: %s", p, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// readDownloadedFile reads a whole file returned by the Downloader.
+func readDownloadedFile(p string) ([]byte, error) {
+	f, err := openDownloadedFile(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// memTestInfo is a single-file torrent of 40 bytes in three 16-byte pieces.
+func memTestInfo() *metainfo.Info {
+	return &metainfo.Info{Name: "movie.mkv", PieceLength: 16, Length: 40, Pieces: make([]byte, 20*3)}
+}
+
+func TestMemoryStorage_ReadWrite(t *testing.T) {
+	s := newMemoryStorage(0)
+	info := memTestInfo()
+	var hash metainfo.Hash
+	impl, err := s.OpenTorrent(context.Background(), info, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("0123456789abcdefGHIJKLMNOPQRSTUVwxyz!?#$")
+	for i := 0; i < 3; i++ {
+		p := impl.Piece(info.Piece(i))
+		start := int64(i) * 16
+		end := min(start+16, 40)
+		if _, err := p.WriteAt(data[start:end], 0); err != nil {
+			t.Fatalf("write piece %d: %v", i, err)
+		}
+		if i != 1 {
+			p.MarkComplete()
+		}
+	}
+	if c := impl.Piece(info.Piece(1)).Completion(); !c.Ok || c.Complete {
+		t.Errorf("piece 1 completion = %+v, want known incomplete", c)
+	}
+
+	mt, ok := s.torrent(hash)
+	if !ok {
+		t.Fatal("torrent not registered")
+	}
+	got := make([]byte, 40)
+	n, err := mt.ReadAt(got, 0)
+	if n != 40 || err != nil {
+		t.Fatalf("ReadAt = %d, %v", n, err)
+	}
+	want := append(append(append([]byte(nil), data[:16]...), make([]byte, 16)...), data[32:]...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("ReadAt = %q, want incomplete piece zeroed: %q", got, want)
+	}
+
+	if err := impl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s.used != 0 {
+		t.Errorf("used = %d after close, want 0", s.used)
+	}
+	if _, ok := s.torrent(hash); ok {
+		t.Error("torrent still registered after close")
+	}
+}
+
+func TestMemoryStorage_Cap(t *testing.T) {
+	s := newMemoryStorage(32)
+	info := memTestInfo()
+	var hash metainfo.Hash
+	impl, _ := s.OpenTorrent(context.Background(), info, hash)
+
+	for i := 0; i < 2; i++ {
+		if _, err := impl.Piece(info.Piece(i)).WriteAt([]byte("x"), 0); err != nil {
+			t.Fatalf("piece %d within cap: %v", i, err)
+		}
+	}
+	if s.capReached(hash) {
+		t.Error("cap reported before it was exceeded")
+	}
+	if _, err := impl.Piece(info.Piece(2)).WriteAt([]byte("x"), 0); err == nil {
+		t.Error("expected error past the memory cap")
+	}
+	if !s.capReached(hash) {
+		t.Error("cap not reported")
+	}
+}
+
+func TestMemoryServer(t *testing.T) {
+	s := newMemoryStorage(0)
+	info := memTestInfo()
+	var hash metainfo.Hash
+	hash[0] = 0xAB
+	impl, _ := s.OpenTorrent(context.Background(), info, hash)
+	data := []byte(strings.Repeat("ABCDEFGHIJKLMNOP", 3))[:40]
+	for i := 0; i < 3; i++ {
+		p := impl.Piece(info.Piece(i))
+		p.WriteAt(data[i*16:min(i*16+16, 40)], 0)
+		p.MarkComplete()
+	}
+
+	srv, err := startMemoryServer(s)
+	if err != nil {
+		t.Skipf("loopback listener unavailable: %v", err)
+	}
+	defer srv.Close()
+
+	u := srv.base + "/" + hash.HexString() + "/8-30/movie.mkv"
+	if !isMemoryURL(u) {
+		t.Fatalf("isMemoryURL(%q) = false", u)
+	}
+	got, err := readDownloadedFile(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data[8:38]) {
+		t.Errorf("got %q, want %q", got, data[8:38])
+	}
+
+	req, _ := http.NewRequest("GET", u, nil)
+	req.Header.Set("Range", "bytes=20-")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, data[28:38]) {
+		t.Errorf("range request: %s %q, want 206 %q", resp.Status, body, data[28:38])
+	}
+
+	if _, err := readDownloadedFile(srv.base + "/" + hash.HexString() + "/0-999/movie.mkv"); err == nil {
+		t.Error("expected error for a range past the torrent end")
+	}
+}
+
+func TestOpenDownloadedFile_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	if !isMemoryURL(srv.URL) {
+		t.Skipf("test server not on 127.0.0.1: %s", srv.URL)
+	}
+
+	saved := memoryFileClient
+	memoryFileClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { memoryFileClient = saved }()
+
+	if _, err := openDownloadedFile(srv.URL + "/movie.mkv"); err == nil {
+		t.Error("expected a timeout from a server that never answers")
+	}
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	mp4MaxBoxes  = 64               // top-level boxes walked before giving up
+	mp4MoovCap   = 64 * 1024 * 1024 // guard against corrupt moov sizes
+	mp4MfraCap   = 16 * 1024 * 1024 // guard against corrupt mfro sizes
+	mp4HeadCap   = 4 * 1024 * 1024  // boxes before the first mdat fetched in full up to this size
+	mp4BoxMax    = 1 << 62          // sizes above this are corrupt
+	mp4MfroSize  = 16               // size of the mfro box closing a fragmented file
+	mp4MvhdProbe = 4096             // bytes of moov searched for the mvhd box
+)
+
+// mp4TopLevelBoxes are the top-level ISO-BMFF/QuickTime box types accepted
+// while walking a file. Anything else means the walk went off the rails.
+var mp4TopLevelBoxes = map[string]bool{
+	"ftyp": true, "styp": true, "moov": true, "mdat": true, "moof": true,
+	"mfra": true, "free": true, "skip": true, "wide": true, "uuid": true,
+	"sidx": true, "ssix": true, "prft": true, "emsg": true, "meta": true,
+	"pdin": true, "pnot": true, "junk": true,
+}
+
+// mp4Box is a top-level box located by the walker.
+type mp4Box struct {
+	Type   string
+	Offset int64 // offset of the box header
+	Header int64 // header length (8, or 16 with a 64-bit size)
+	Size   int64 // total length, header included
+}
+
+// readMP4Box reads the box header at off. A size of 0 means the box runs
+// to the end of the file; a size of 1 means a 64-bit size follows the type.
+func readMP4Box(r io.ReaderAt, off, fileSize int64) (mp4Box, error) {
+	var hdr [16]byte
+	n, err := r.ReadAt(hdr[:min(16, fileSize-off)], off)
+	if n < 8 {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return mp4Box{}, fmt.Errorf("read box header at %d: %w", off, err)
+	}
+	box := mp4Box{Type: string(hdr[4:8]), Offset: off, Header: 8}
+	size := int64(binary.BigEndian.Uint32(hdr[:4]))
+	switch size {
+	case 0:
+		size = fileSize - off
+	case 1:
+		if n < 16 {
+			return mp4Box{}, fmt.Errorf("truncated 64-bit size for %q at %d", box.Type, off)
+		}
+		large := binary.BigEndian.Uint64(hdr[8:16])
+		if large > mp4BoxMax {
+			return mp4Box{}, fmt.Errorf("invalid size for %q at %d", box.Type, off)
+		}
+		size = int64(large)
+		box.Header = 16
+	}
+	if size < box.Header {
+		return mp4Box{}, fmt.Errorf("invalid size %d for %q at %d", size, box.Type, off)
+	}
+	box.Size = size
+	return box, nil
+}
+
+// walkMP4 follows the top-level box sizes from the start of the file and
+// returns the boxes found. Only box headers are read, so moov is located
+// without touching the media data in between. For fragmented files the walk
+// stops at the second moof: the rest is a long run of moof/mdat pairs.
+func walkMP4(r io.ReaderAt, size int64) ([]mp4Box, error) {
+	var boxes []mp4Box
+	fragments := 0
+	for off := int64(0); off < size && len(boxes) < mp4MaxBoxes; {
+		box, err := readMP4Box(r, off, size)
+		if err != nil {
+			if len(boxes) == 0 {
+				return nil, err
+			}
+			break // trailing garbage or a truncated last box
+		}
+		if !mp4TopLevelBoxes[box.Type] {
+			if len(boxes) == 0 {
+				return nil, errors.New("not an MP4/QuickTime file")
+			}
+			break
+		}
+		if box.Type == "moof" {
+			if fragments++; fragments > 1 {
+				break
+			}
+		}
+		boxes = append(boxes, box)
+		off += box.Size
+	}
+	return boxes, nil
+}
+
+// mp4Duration returns the movie duration in seconds from the mvhd box at
+// the start of moov, or 0 when it can't be read. Fragmented files usually
+// leave it at 0, their length being spread over the fragments.
+func mp4Duration(r io.ReaderAt, size int64) float64 {
+	boxes, err := walkMP4(r, size)
+	if err != nil {
+		return 0
+	}
+	for _, b := range boxes {
+		if b.Type != "moov" {
+			continue
+		}
+		body := make([]byte, min(b.Size-b.Header, mp4MvhdProbe))
+		n, _ := r.ReadAt(body, b.Offset+b.Header)
+		body = body[:n]
+		for off := 0; off+8 <= len(body); {
+			childSize := int(binary.BigEndian.Uint32(body[off:]))
+			if string(body[off+4:off+8]) == "mvhd" {
+				return mvhdDuration(body[off+8 : min(len(body), off+max(childSize, 8))])
+			}
+			if childSize < 8 {
+				return 0
+			}
+			off += childSize
+		}
+		return 0
+	}
+	return 0
+}
+
+// mvhdDuration decodes the timescale and duration of an mvhd box body
+// (version 0 with 32-bit times, or version 1 with 64-bit ones).
+func mvhdDuration(b []byte) float64 {
+	var timescale uint32
+	var duration uint64
+	switch {
+	case len(b) >= 20 && b[0] == 0:
+		timescale = binary.BigEndian.Uint32(b[12:16])
+		duration = uint64(binary.BigEndian.Uint32(b[16:20]))
+		if duration == 0xFFFFFFFF {
+			return 0 // unknown
+		}
+	case len(b) >= 32 && b[0] == 1:
+		timescale = binary.BigEndian.Uint32(b[20:24])
+		duration = binary.BigEndian.Uint64(b[24:32])
+		if duration == 0xFFFFFFFFFFFFFFFF {
+			return 0
+		}
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return float64(duration) / float64(timescale)
+}
+
+// mp4FragmentIndex returns the range of the mfra box (fragment random
+// access index) that fragmented files may append, located through the mfro
+// box in their last 16 bytes.
+func mp4FragmentIndex(r io.ReaderAt, size int64) (byteRange, bool) {
+	if size < mp4MfroSize {
+		return byteRange{}, false
+	}
+	var mfro [mp4MfroSize]byte
+	if n, _ := r.ReadAt(mfro[:], size-mp4MfroSize); n < mp4MfroSize {
+		return byteRange{}, false
+	}
+	if string(mfro[4:8]) != "mfro" || binary.BigEndian.Uint32(mfro[:4]) != mp4MfroSize {
+		return byteRange{}, false
+	}
+	mfraSize := int64(binary.BigEndian.Uint32(mfro[12:16]))
+	if mfraSize < mp4MfroSize || mfraSize > min(size, mp4MfraCap) {
+		return byteRange{}, false
+	}
+	return byteRange{size - mfraSize, size}, true
+}
+
+// mp4ByteRanges computes the byte ranges of an MP4/MOV file that ffprobe
+// needs: the boxes before the media data, the moov box wherever it sits
+// (start, middle or end), and a sample of media data for decoding. For
+// fragmented files (moov with mvex, then moof/mdat pairs) the first
+// fragment and the trailing mfra index are included. Reading through r
+// downloads only the pieces holding the box headers.
+func mp4ByteRanges(r io.ReaderAt, size, sample int64) ([]byteRange, error) {
+	boxes, err := walkMP4(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []byteRange
+	var moov, firstMdat *mp4Box
+	fragmented := false
+	for i := range boxes {
+		b := &boxes[i]
+		switch b.Type {
+		case "moov":
+			if moov == nil {
+				moov = b
+			}
+		case "mdat":
+			if firstMdat == nil {
+				firstMdat = b
+			}
+		case "moof":
+			fragmented = true
+		}
+		if b.Type != "mdat" && (firstMdat == nil || b.Type == "moof") {
+			// Everything before the media data, and the first fragment header
+			ranges = append(ranges, byteRange{b.Offset, min(size, b.Offset+min(b.Size, mp4HeadCap))})
+		}
+	}
+	if moov == nil {
+		return nil, errors.New("moov box not found")
+	}
+	if moov.Size > mp4MoovCap {
+		return nil, fmt.Errorf("moov box too large (%d bytes)", moov.Size)
+	}
+	ranges = append(ranges, byteRange{moov.Offset, min(size, moov.Offset+moov.Size)})
+
+	if firstMdat != nil {
+		// Header plus the first sample bytes of media data (for a fragmented
+		// file, the mdat of the first fragment)
+		ranges = append(ranges, byteRange{firstMdat.Offset, min(size, firstMdat.Offset+firstMdat.Header+sample)})
+	}
+	if fragmented {
+		if mfra, ok := mp4FragmentIndex(r, size); ok {
+			ranges = append(ranges, mfra)
+		}
+	}
+	return mergeRanges(ranges), nil
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func mp4TestBox(typ string, body []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(out, typ...), body...)
+}
+
+// mp4LargeBox encodes a box with a 64-bit size field.
+func mp4LargeBox(typ string, body []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, 1)
+	out = append(out, typ...)
+	out = binary.BigEndian.AppendUint64(out, uint64(16+len(body)))
+	return append(out, body...)
+}
+
+func rangesCover(ranges []byteRange, start, end int64) bool {
+	for _, r := range ranges {
+		if r.Start <= start && end <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+func rangesTotal(ranges []byteRange) int64 {
+	var total int64
+	for _, r := range ranges {
+		total += r.End - r.Start
+	}
+	return total
+}
+
+func TestMP4ByteRanges_MoovAtEnd(t *testing.T) {
+	ftyp := mp4TestBox("ftyp", []byte("isom\x00\x00\x02\x00isomiso2"))
+	free := mp4TestBox("free", nil)
+	mdat := mp4LargeBox("mdat", make([]byte, 20*1024*1024))
+	moov := mp4TestBox("moov", bytes.Repeat([]byte{0x11}, 200000))
+	file := bytes.Join([][]byte{ftyp, free, mdat, moov}, nil)
+	size := int64(len(file))
+	moovAt := size - int64(len(moov))
+
+	r := &recordingReader{Reader: bytes.NewReader(file)}
+	ranges, err := mp4ByteRanges(r, size, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rangesCover(ranges, 0, int64(len(ftyp)+len(free))+16+1024*1024) {
+		t.Errorf("head and media sample not covered: %+v", ranges)
+	}
+	if !rangesCover(ranges, moovAt, size) {
+		t.Errorf("moov at %d not covered: %+v", moovAt, ranges)
+	}
+	if total := rangesTotal(ranges); total > 2*1024*1024 {
+		t.Errorf("expected ~1.2MB of ranges, got %d", total)
+	}
+	for _, rd := range r.reads {
+		if rd.End-rd.Start > 16 {
+			t.Errorf("walker read more than a box header: %+v", rd)
+		}
+	}
+}
+
+func TestMP4ByteRanges_FastStart(t *testing.T) {
+	ftyp := mp4TestBox("ftyp", []byte("mp42\x00\x00\x00\x00mp42"))
+	moov := mp4TestBox("moov", bytes.Repeat([]byte{0x11}, 5000))
+	mdat := mp4TestBox("mdat", make([]byte, 8*1024*1024))
+	file := bytes.Join([][]byte{ftyp, moov, mdat}, nil)
+
+	ranges, err := mp4ByteRanges(bytes.NewReader(file), int64(len(file)), 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 1 || ranges[0].Start != 0 {
+		t.Fatalf("expected one range from the start, got %+v", ranges)
+	}
+	if want := int64(len(ftyp)+len(moov)) + 8 + 1024*1024; ranges[0].End != want {
+		t.Errorf("range end = %d, want %d", ranges[0].End, want)
+	}
+}
+
+func TestMP4ByteRanges_Fragmented(t *testing.T) {
+	ftyp := mp4TestBox("ftyp", []byte("iso6\x00\x00\x00\x00iso6"))
+	moov := mp4TestBox("moov", mp4TestBox("mvex", make([]byte, 32)))
+	var fragments [][]byte
+	for i := 0; i < 10; i++ {
+		fragments = append(fragments, mp4TestBox("moof", make([]byte, 500)), mp4TestBox("mdat", make([]byte, 2*1024*1024)))
+	}
+	mfraBody := make([]byte, 300)
+	mfro := binary.BigEndian.AppendUint32(nil, mp4MfroSize)
+	mfro = append(mfro, "mfro\x00\x00\x00\x00"...)
+	mfro = binary.BigEndian.AppendUint32(mfro, uint32(8+len(mfraBody)+mp4MfroSize))
+	mfra := mp4TestBox("mfra", append(mfraBody, mfro...))
+
+	file := bytes.Join(append(append([][]byte{ftyp, moov}, fragments...), mfra), nil)
+	size := int64(len(file))
+
+	r := &recordingReader{Reader: bytes.NewReader(file)}
+	ranges, err := mp4ByteRanges(r, size, 512*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstMdat := int64(len(ftyp) + len(moov) + len(fragments[0]))
+	if !rangesCover(ranges, 0, firstMdat+8+512*1024) {
+		t.Errorf("moov and first fragment not covered: %+v", ranges)
+	}
+	if !rangesCover(ranges, size-int64(len(mfra)), size) {
+		t.Errorf("mfra not covered: %+v", ranges)
+	}
+	if len(r.reads) > 6 {
+		t.Errorf("walker should stop after the first fragment, made %d reads", len(r.reads))
+	}
+}
+
+func TestMP4ByteRanges_Errors(t *testing.T) {
+	ebml := append([]byte{0x1A, 0x45, 0xDF, 0xA3}, make([]byte, 100)...)
+	if _, err := mp4ByteRanges(bytes.NewReader(ebml), int64(len(ebml)), 1024); err == nil {
+		t.Error("expected error for non-MP4 data")
+	}
+
+	noMoov := append(mp4TestBox("ftyp", []byte("isom")), mp4TestBox("mdat", make([]byte, 1000))...)
+	if _, err := mp4ByteRanges(bytes.NewReader(noMoov), int64(len(noMoov)), 1024); err == nil {
+		t.Error("expected error when moov is missing")
+	}
+}
+
+func TestReadMP4Box_ToEOF(t *testing.T) {
+	data := append(mp4TestBox("ftyp", []byte("isom")), 0, 0, 0, 0, 'm', 'd', 'a', 't')
+	data = append(data, make([]byte, 100)...)
+	box, err := readMP4Box(bytes.NewReader(data), 12, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if box.Type != "mdat" || box.Size != int64(len(data))-12 {
+		t.Errorf("got %+v, want mdat running to EOF", box)
+	}
+}
+
+// mvhdBox encodes a version 0 mvhd box with the given timescale and duration.
+func mvhdBox(timescale, duration uint32) []byte {
+	body := make([]byte, 100)
+	binary.BigEndian.PutUint32(body[12:], timescale)
+	binary.BigEndian.PutUint32(body[16:], duration)
+	return mp4TestBox("mvhd", body)
+}
+
+func TestMP4Duration(t *testing.T) {
+	ftyp := mp4TestBox("ftyp", []byte("isom\x00\x00\x02\x00isomiso2"))
+	moov := mp4TestBox("moov", append(mvhdBox(1000, 5400000), mp4TestBox("trak", make([]byte, 64))...))
+	file := append(ftyp, moov...)
+	if got := mp4Duration(bytes.NewReader(file), int64(len(file))); got != 5400 {
+		t.Errorf("mp4Duration = %v, want 5400", got)
+	}
+
+	v1 := make([]byte, 112)
+	v1[0] = 1
+	binary.BigEndian.PutUint32(v1[20:], 600)
+	binary.BigEndian.PutUint64(v1[24:], 600*7200)
+	if got := mvhdDuration(v1); got != 7200 {
+		t.Errorf("version 1 mvhd: got %v, want 7200", got)
+	}
+
+	noMvhd := append(ftyp, mp4TestBox("moov", mp4TestBox("trak", nil))...)
+	if got := mp4Duration(bytes.NewReader(noMvhd), int64(len(noMvhd))); got != 0 {
+		t.Errorf("moov without mvhd: got %v, want 0", got)
+	}
+}
+
+func TestPlanRanges_MP4AnalysisHead(t *testing.T) {
+	// A 90-minute 720p encode at 5 Mbit/s with moov at the end
+	const duration = 5400.0
+	size := int64(duration * 5e6 / 8)
+	ftyp := mp4TestBox("ftyp", []byte("isom\x00\x00\x02\x00isomiso2"))
+	moov := mp4TestBox("moov", append(mvhdBox(1000, uint32(duration*1000)), mp4TestBox("trak", make([]byte, 64*1024))...))
+	mdatSize := size - int64(len(ftyp)) - int64(len(moov))
+	mdatHeader := binary.BigEndian.AppendUint32(nil, 1)
+	mdatHeader = binary.BigEndian.AppendUint64(append(mdatHeader, "mdat"...), uint64(mdatSize))
+	f := sparseFile{head: append(ftyp, mdatHeader...), tail: moov, size: size}
+
+	ranges, err := planRanges(f, size, mp4Container, 20*1024*1024, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rangesCover(ranges, size-int64(len(moov)), size) {
+		t.Errorf("moov at the end not covered: %+v", ranges)
+	}
+	head := firstRange(ranges)
+	if head > analysisSampleCap+1024*1024 {
+		t.Errorf("analysis head is %dMB, cap is %dMB", head/1024/1024, analysisSampleCap/1024/1024)
+	}
+	span := headSpan(head, size, duration)
+	if starts, _ := dummyStarts(span); starts == nil {
+		t.Errorf("head span %.1fs too short for duplicate-track windows", span)
+	}
+	if starts, _ := upmixStarts(span); starts == nil {
+		t.Errorf("head span %.1fs too short for upmix windows", span)
+	}
+}
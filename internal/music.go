@@ -0,0 +1,422 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxReleaseTracks   = 25              // max audio tracks probed per audio-only torrent
+	trackHeaderBytes   = 1 * 1024 * 1024 // header bytes downloaded per track (stream info + tags)
+	trackHeaderRetry   = 4 * 1024 * 1024 // retry size when embedded artwork pushes tags past the header
+	maxReleaseSidecars = 10              // max .cue/.log files parsed per torrent
+	maxSidecarTextSize = 1 * 1024 * 1024 // .cue/.log files larger than this are skipped
+	maxSpectrumTracks  = 3               // lossless tracks analyzed for transcodes
+	spectrumHeaderSize = 8 * 1024 * 1024 // header bytes decoded for spectral analysis (~20s of CD FLAC)
+)
+
+// losslessCodecs are ffprobe codec names of lossless audio formats.
+var losslessCodecs = map[string]bool{
+	"flac": true, "alac": true, "ape": true, "wavpack": true, "tta": true,
+	"mlp": true, "truehd": true, "tak": true,
+}
+
+// codecDisplayNames maps ffprobe codec names to the names used in release summaries.
+var codecDisplayNames = map[string]string{
+	"flac": "FLAC", "alac": "ALAC", "ape": "APE", "wavpack": "WavPack", "tta": "TTA",
+	"mp3": "MP3", "aac": "AAC", "vorbis": "Vorbis", "opus": "Opus", "wmav2": "WMA",
+	"wmapro": "WMA Pro", "wmalossless": "WMA Lossless", "ac3": "AC3", "eac3": "E-AC3",
+	"dts": "DTS", "truehd": "TrueHD",
+}
+
+// releaseTagKeys are the tags kept per track (lowercase ffprobe tag names).
+var releaseTagKeys = []string{"artist", "album_artist", "album", "title", "track", "disc", "date", "genre"}
+
+// audioProbeOutput matches the ffprobe JSON fields needed for audio files.
+type audioProbeOutput struct {
+	Streams []struct {
+		CodecType     string            `json:"codec_type"`
+		CodecName     string            `json:"codec_name"`
+		SampleRate    string            `json:"sample_rate"`
+		SampleFmt     string            `json:"sample_fmt"`
+		Channels      int               `json:"channels"`
+		BitsPerRaw    string            `json:"bits_per_raw_sample"`
+		BitsPerSample int               `json:"bits_per_sample"`
+		BitRate       string            `json:"bit_rate"`
+		Duration      string            `json:"duration"`
+		Tags          map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		Duration string            `json:"duration"`
+		BitRate  string            `json:"bit_rate"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// ProbeAudioFile runs ffprobe on a (possibly partial) audio file and returns
+// per-track technical details and tags. fileSize is the full size of the file
+// in the torrent, used to derive bitrate/duration when only the header is on disk.
+func ProbeAudioFile(ctx context.Context, ffprobePath, localPath string, fileSize int64) (*AudioFileTrack, error) {
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_streams",
+		"-show_format",
+		"--", localPath,
+	)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed (file=%s): %s", path.Base(localPath), strings.TrimSpace(stderr.String()))
+	}
+
+	var data audioProbeOutput
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("ffprobe JSON parse failed: %w", err)
+	}
+	return parseAudioProbe(data, fileSize)
+}
+
+// parseAudioProbe converts ffprobe output for an audio file into an AudioFileTrack.
+func parseAudioProbe(data audioProbeOutput, fileSize int64) (*AudioFileTrack, error) {
+	for _, s := range data.Streams {
+		if s.CodecType != "audio" {
+			continue // embedded cover art shows up as a video stream
+		}
+
+		track := &AudioFileTrack{
+			Codec:    s.CodecName,
+			Channels: s.Channels,
+			Lossless: losslessCodecs[s.CodecName] || strings.HasPrefix(s.CodecName, "pcm_"),
+			Tags:     map[string]string{},
+		}
+		track.SampleRate, _ = strconv.Atoi(s.SampleRate)
+
+		// Bit depth only makes sense for lossless/PCM audio
+		if track.Lossless {
+			if bd, err := strconv.Atoi(s.BitsPerRaw); err == nil && bd > 0 {
+				track.BitDepth = bd
+			} else if s.BitsPerSample > 0 {
+				track.BitDepth = s.BitsPerSample
+			} else if strings.HasPrefix(s.SampleFmt, "s16") {
+				track.BitDepth = 16
+			} else if strings.HasPrefix(s.SampleFmt, "s32") {
+				track.BitDepth = 24 // s32 is used for 24-bit FLAC without bits_per_raw_sample
+			}
+		}
+
+		track.Duration = parseDuration(data.Format.Duration)
+		if track.Duration == 0 {
+			track.Duration = parseDuration(s.Duration)
+		}
+
+		bitrate, _ := strconv.Atoi(s.BitRate)
+		if bitrate == 0 && !track.Lossless {
+			bitrate, _ = strconv.Atoi(data.Format.BitRate)
+		}
+		switch {
+		case track.Lossless && track.Duration > 0 && fileSize > 0:
+			// The format bitrate of a partial file is meaningless; derive it from the full size
+			bitrate = int(float64(fileSize) * 8 / track.Duration)
+		case !track.Lossless && bitrate > 0 && fileSize > 0:
+			// CBR files without a Xing/VBRI header get their duration estimated
+			// from the bytes on disk — use the full torrent file size instead.
+			if est := float64(fileSize) * 8 / float64(bitrate); est > track.Duration*1.1 {
+				track.Duration = math.Round(est*1000) / 1000
+			}
+		}
+		track.Bitrate = bitrate
+
+		for _, key := range releaseTagKeys {
+			if v := tagValue(data.Format.Tags, key); v != "" {
+				track.Tags[key] = v
+			} else if v := tagValue(s.Tags, key); v != "" {
+				track.Tags[key] = v
+			}
+		}
+		return track, nil
+	}
+	return nil, fmt.Errorf("no audio stream found")
+}
+
+// codecDisplayName returns the summary name for an ffprobe codec name.
+func codecDisplayName(codec string) string {
+	if name, ok := codecDisplayNames[codec]; ok {
+		return name
+	}
+	if strings.HasPrefix(codec, "pcm_") {
+		return "PCM"
+	}
+	if strings.HasPrefix(codec, "dsd_") {
+		return "DSD"
+	}
+	return strings.ToUpper(codec)
+}
+
+// formatSampleRate formats a sample rate in kHz as used in release names (44.1, 96).
+func formatSampleRate(hz int) string {
+	return strconv.FormatFloat(float64(hz)/1000, 'f', -1, 64)
+}
+
+// summarizeRelease fills in the album-level fields of an AudioRelease from its
+// probed tracks: common codec/format, a human summary like "FLAC 24/96" or
+// "MP3 320", total duration and album/artist from tags.
+func summarizeRelease(rel *AudioRelease) {
+	if len(rel.Tracks) == 0 {
+		rel.Summary = "unknown"
+		return
+	}
+
+	first := rel.Tracks[0]
+	sameCodec, sameFormat, sameBitrate := true, true, true
+	maxBitrate, totalBitrate := 0, 0
+	rel.Duration = 0
+
+	for _, t := range rel.Tracks {
+		if t.Codec != first.Codec {
+			sameCodec = false
+		}
+		if t.SampleRate != first.SampleRate || t.BitDepth != first.BitDepth {
+			sameFormat = false
+		}
+		// Allow small differences from container overhead/rounding
+		if math.Abs(float64(t.Bitrate-first.Bitrate)) > 8000 {
+			sameBitrate = false
+		}
+		maxBitrate = max(maxBitrate, t.Bitrate)
+		totalBitrate += t.Bitrate
+		rel.Duration += t.Duration
+	}
+	rel.Duration = math.Round(rel.Duration*1000) / 1000
+
+	rel.Artist = first.Tags["album_artist"]
+	if rel.Artist == "" {
+		rel.Artist = first.Tags["artist"]
+	}
+	rel.Album = first.Tags["album"]
+
+	if !sameCodec {
+		rel.Summary = "Mixed"
+		return
+	}
+
+	rel.Codec = first.Codec
+	rel.Lossless = first.Lossless
+	name := codecDisplayName(first.Codec)
+
+	if sameFormat {
+		rel.SampleRate = first.SampleRate
+		rel.BitDepth = first.BitDepth
+	}
+
+	switch {
+	case rel.Lossless && sameFormat && first.BitDepth > 0 && first.SampleRate > 0:
+		rel.Summary = fmt.Sprintf("%s %d/%s", name, first.BitDepth, formatSampleRate(first.SampleRate))
+	case rel.Lossless:
+		rel.Summary = name
+	case sameBitrate && first.Bitrate > 0:
+		rel.Summary = fmt.Sprintf("%s %d", name, int(math.Round(float64(first.Bitrate)/1000)))
+	case maxBitrate > 0:
+		avg := totalBitrate / len(rel.Tracks)
+		rel.Summary = fmt.Sprintf("%s VBR ~%d", name, int(math.Round(float64(avg)/1000)))
+	default:
+		rel.Summary = name
+	}
+}
+
+// processAudioRelease analyzes a music/audio-only torrent (no video file):
+// it probes the headers of the audio tracks, builds an album-level summary
+// and parses any .cue/.log files for rip verification.
+func processAudioRelease(ctx context.Context, dl *Downloader, cfg Config, infoHash string, files *TorrentFiles, swarm *SwarmInfo, start time.Time) ScanResult {
+	result := ScanResult{
+		InfoHash: infoHash,
+		Files:    files,
+		Swarm:    swarm,
+	}
+
+	ffprobePath, err := ResolveFFprobe(cfg.FFprobePath)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		result.ElapsedMs = time.Since(start).Milliseconds()
+		return result
+	}
+
+	tracks := make([]FileInfo, len(files.AudioFiles))
+	copy(tracks, files.AudioFiles)
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].Path < tracks[j].Path })
+
+	rel := &AudioRelease{
+		TrackCount: len(tracks),
+		Tracks:     []AudioFileTrack{},
+		CueSheets:  []CueSheet{},
+		RipLogs:    []RipLog{},
+	}
+	if len(tracks) > maxReleaseTracks {
+		log.Printf("  [%s] %d audio tracks, probing first %d", TruncHash(infoHash), len(tracks), maxReleaseTracks)
+		tracks = tracks[:maxReleaseTracks]
+	}
+
+	log.Printf("  [%s] audio release: probing %d track(s)", TruncHash(infoHash), len(tracks))
+
+	for _, f := range tracks {
+		track, err := probeReleaseTrack(ctx, dl, ffprobePath, infoHash, f)
+		if err != nil {
+			log.Printf("  [%s] audio probe skip %s: %v", TruncHash(infoHash), path.Base(f.Path), err)
+			continue
+		}
+		rel.Tracks = append(rel.Tracks, *track)
+	}
+
+	// Rip verification files (.cue / .log)
+	parsed := 0
+	for _, f := range files.OtherFiles {
+		ext := strings.ToLower(f.Ext)
+		if (ext != ".cue" && ext != ".log") || f.Size > maxSidecarTextSize || parsed >= maxReleaseSidecars {
+			continue
+		}
+		parsed++
+		localPath, err := dl.DownloadFullFile(ctx, infoHash, f.Path)
+		if err != nil {
+			log.Printf("  [%s] skip %s: %v", TruncHash(infoHash), path.Base(f.Path), err)
+			continue
+		}
+		data, err := readDownloadedFile(localPath)
+		if err != nil {
+			continue
+		}
+		text := decodeLogText(data)
+		if ext == ".cue" {
+			cue := ParseCueSheet(text)
+			cue.Path = f.Path
+			cue.MissingFiles = missingCueFiles(cue, f.Path, files)
+			rel.CueSheets = append(rel.CueSheets, cue)
+		} else if rl, ok := ParseRipLog(text); ok {
+			rl.Path = f.Path
+			rel.RipLogs = append(rel.RipLogs, rl)
+		}
+	}
+
+	summarizeRelease(rel)
+	if ffmpegPath := ResolveFFmpeg(ffprobePath); ffmpegPath != "" {
+		analyzeReleaseSpectrum(ctx, dl, ffmpegPath, infoHash, rel)
+	}
+	result.AudioRelease = rel
+	result.ElapsedMs = time.Since(start).Milliseconds()
+
+	if len(rel.Tracks) == 0 {
+		result.Status = "ffprobe_failed"
+		result.Error = "no audio track could be probed"
+		return result
+	}
+
+	result.Status = "success"
+	result.File = path.Base(rel.Tracks[0].Path)
+	log.Printf("  [%s] audio release: %s, %d track(s), %d cue, %d log",
+		TruncHash(infoHash), rel.Summary, rel.TrackCount, len(rel.CueSheets), len(rel.RipLogs))
+	return result
+}
+
+// probeReleaseTrack downloads the header of one audio track and probes it,
+// retrying with a larger header when embedded artwork hides the stream info.
+func probeReleaseTrack(ctx context.Context, dl *Downloader, ffprobePath, infoHash string, f FileInfo) (*AudioFileTrack, error) {
+	var lastErr error
+	for _, size := range []int{trackHeaderBytes, trackHeaderRetry} {
+		localPath, err := dl.DownloadFileHeader(ctx, infoHash, f.Path, size)
+		if err != nil {
+			return nil, err
+		}
+		track, err := ProbeAudioFile(ctx, ffprobePath, localPath, f.Size)
+		if err == nil {
+			track.Path = f.Path
+			track.Size = f.Size
+			return track, nil
+		}
+		lastErr = err
+		if int64(size) >= f.Size {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// analyzeReleaseSpectrum runs the spectral transcode check on up to
+// maxSpectrumTracks lossless tracks spread across the release, then sets the
+// release-level verdict. Tracks that cannot be decoded are left unanalyzed.
+func analyzeReleaseSpectrum(ctx context.Context, dl *Downloader, ffmpegPath, infoHash string, rel *AudioRelease) {
+	var lossless []int
+	for i, t := range rel.Tracks {
+		if t.Lossless {
+			lossless = append(lossless, i)
+		}
+	}
+	if len(lossless) == 0 {
+		return
+	}
+
+	picked := lossless
+	if len(lossless) > maxSpectrumTracks {
+		picked = make([]int, maxSpectrumTracks)
+		for i := range picked {
+			picked[i] = lossless[i*(len(lossless)-1)/(maxSpectrumTracks-1)]
+		}
+	}
+
+	for _, i := range picked {
+		track := &rel.Tracks[i]
+		localPath, err := dl.DownloadFileHeader(ctx, infoHash, track.Path, spectrumHeaderSize)
+		if err != nil {
+			log.Printf("  [%s] spectrum skip %s: %v", TruncHash(infoHash), path.Base(track.Path), err)
+			continue
+		}
+		samples, err := decodePCM(ctx, ffmpegPath, localPath, 0, spectrumSeconds, 0)
+		if err != nil {
+			log.Printf("  [%s] spectrum skip %s: %v", TruncHash(infoHash), path.Base(track.Path), err)
+			continue
+		}
+		res, ok := AnalyzeSpectrum(samples, track.SampleRate)
+		if !ok {
+			continue
+		}
+		track.SpectralCutoff = res.CutoffHz
+		track.SuspectedTranscode = res.Transcode
+		track.EstimatedSourceBitrate = res.FromBitrate
+		log.Printf("  [%s] spectrum %s: cutoff=%d Hz transcode=%v",
+			TruncHash(infoHash), path.Base(track.Path), res.CutoffHz, res.Transcode)
+	}
+
+	summarizeTranscode(rel)
+}
+
+// summarizeTranscode sets the release-level transcode verdict: suspected when
+// most analyzed tracks are, with the median estimated source bitrate.
+func summarizeTranscode(rel *AudioRelease) {
+	analyzed := 0
+	var bitrates []int
+	for _, t := range rel.Tracks {
+		if t.SpectralCutoff == 0 {
+			continue
+		}
+		analyzed++
+		if t.SuspectedTranscode {
+			bitrates = append(bitrates, t.EstimatedSourceBitrate)
+		}
+	}
+	if analyzed == 0 || len(bitrates)*2 <= analyzed {
+		return
+	}
+	sort.Ints(bitrates)
+	rel.SuspectedTranscode = true
+	rel.EstimatedSourceBitrate = bitrates[len(bitrates)/2]
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAudioProbe_FLAC(t *testing.T) {
+	raw := `{
+		"streams": [
+			{"codec_type": "audio", "codec_name": "flac", "sample_rate": "96000", "channels": 2,
+			 "sample_fmt": "s32", "bits_per_raw_sample": "24"},
+			{"codec_type": "video", "codec_name": "mjpeg"}
+		],
+		"format": {"duration": "300.000000", "bit_rate": "12345",
+			"tags": {"ARTIST": "Pink Floyd", "ALBUM": "Animals", "TITLE": "Dogs", "track": "2"}}
+	}`
+	var data audioProbeOutput
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := parseAudioProbe(data, 120_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Codec != "flac" || !track.Lossless {
+		t.Errorf("expected lossless flac, got %s lossless=%v", track.Codec, track.Lossless)
+	}
+	if track.SampleRate != 96000 || track.BitDepth != 24 || track.Channels != 2 {
+		t.Errorf("unexpected format: %d Hz, %d bit, %d ch", track.SampleRate, track.BitDepth, track.Channels)
+	}
+	// Bitrate derived from full file size: 120MB * 8 / 300s
+	if track.Bitrate != 3_200_000 {
+		t.Errorf("expected bitrate 3200000, got %d", track.Bitrate)
+	}
+	if track.Tags["artist"] != "Pink Floyd" || track.Tags["album"] != "Animals" || track.Tags["track"] != "2" {
+		t.Errorf("unexpected tags: %v", track.Tags)
+	}
+}
+
+func TestParseAudioProbe_MP3PartialDuration(t *testing.T) {
+	raw := `{
+		"streams": [{"codec_type": "audio", "codec_name": "mp3", "sample_rate": "44100", "channels": 2,
+			"bit_rate": "320000", "sample_fmt": "fltp"}],
+		"format": {"duration": "26.200000"}
+	}`
+	var data audioProbeOutput
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	// 10MB at 320kbps is 250s, header-only probe reported 26.2s
+	track, err := parseAudioProbe(data, 10_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Lossless || track.BitDepth != 0 {
+		t.Errorf("mp3 should be lossy without bit depth, got lossless=%v depth=%d", track.Lossless, track.BitDepth)
+	}
+	if track.Bitrate != 320000 {
+		t.Errorf("expected bitrate 320000, got %d", track.Bitrate)
+	}
+	if track.Duration != 250 {
+		t.Errorf("expected duration 250 from file size, got %f", track.Duration)
+	}
+}
+
+func TestParseAudioProbe_NoAudio(t *testing.T) {
+	var data audioProbeOutput
+	if err := json.Unmarshal([]byte(`{"streams": [{"codec_type": "video", "codec_name": "mjpeg"}]}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseAudioProbe(data, 1000); err == nil {
+		t.Error("expected error for file without audio stream")
+	}
+}
+
+func TestSummarizeRelease(t *testing.T) {
+	flac := func(rate, depth int) AudioFileTrack {
+		return AudioFileTrack{Codec: "flac", Lossless: true, SampleRate: rate, BitDepth: depth, Bitrate: 2_000_000, Duration: 200,
+			Tags: map[string]string{"album_artist": "Artist", "album": "Album"}}
+	}
+	mp3 := func(bitrate int) AudioFileTrack {
+		return AudioFileTrack{Codec: "mp3", SampleRate: 44100, Bitrate: bitrate, Duration: 180, Tags: map[string]string{}}
+	}
+
+	tests := []struct {
+		name   string
+		tracks []AudioFileTrack
+		want   string
+	}{
+		{"flac hi-res", []AudioFileTrack{flac(96000, 24), flac(96000, 24)}, "FLAC 24/96"},
+		{"flac cd", []AudioFileTrack{flac(44100, 16)}, "FLAC 16/44.1"},
+		{"flac mixed format", []AudioFileTrack{flac(44100, 16), flac(96000, 24)}, "FLAC"},
+		{"mp3 cbr", []AudioFileTrack{mp3(320000), mp3(320000)}, "MP3 320"},
+		{"mp3 vbr", []AudioFileTrack{mp3(230000), mp3(260000)}, "MP3 VBR ~245"},
+		{"mixed codecs", []AudioFileTrack{flac(44100, 16), mp3(320000)}, "Mixed"},
+		{"empty", nil, "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rel := &AudioRelease{Tracks: tt.tracks}
+			summarizeRelease(rel)
+			if rel.Summary != tt.want {
+				t.Errorf("summary = %q, want %q", rel.Summary, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeRelease_AlbumFields(t *testing.T) {
+	rel := &AudioRelease{Tracks: []AudioFileTrack{
+		{Codec: "flac", Lossless: true, SampleRate: 44100, BitDepth: 16, Duration: 100.5,
+			Tags: map[string]string{"artist": "Band", "album": "Debut"}},
+		{Codec: "flac", Lossless: true, SampleRate: 44100, BitDepth: 16, Duration: 200.25, Tags: map[string]string{}},
+	}}
+	summarizeRelease(rel)
+
+	if rel.Artist != "Band" || rel.Album != "Debut" {
+		t.Errorf("expected Band/Debut, got %q/%q", rel.Artist, rel.Album)
+	}
+	if rel.Duration != 300.75 {
+		t.Errorf("expected total duration 300.75, got %f", rel.Duration)
+	}
+	if rel.SampleRate != 44100 || rel.BitDepth != 16 || !rel.Lossless {
+		t.Errorf("unexpected album format: %d/%d lossless=%v", rel.SampleRate, rel.BitDepth, rel.Lossless)
+	}
+}
+
+func TestSummarizeTranscode(t *testing.T) {
+	tests := []struct {
+		name        string
+		tracks      []AudioFileTrack
+		wantSuspect bool
+		wantBitrate int
+	}{
+		{"majority transcoded", []AudioFileTrack{
+			{SpectralCutoff: 16000, SuspectedTranscode: true, EstimatedSourceBitrate: 128000},
+			{SpectralCutoff: 19000, SuspectedTranscode: true, EstimatedSourceBitrate: 192000},
+			{SpectralCutoff: 21500},
+		}, true, 192000},
+		{"minority transcoded", []AudioFileTrack{
+			{SpectralCutoff: 16000, SuspectedTranscode: true, EstimatedSourceBitrate: 128000},
+			{SpectralCutoff: 21500},
+		}, false, 0},
+		{"not analyzed", []AudioFileTrack{{}, {}}, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rel := &AudioRelease{Tracks: tt.tracks}
+			summarizeTranscode(rel)
+			if rel.SuspectedTranscode != tt.wantSuspect || rel.EstimatedSourceBitrate != tt.wantBitrate {
+				t.Errorf("got (%v, %d), want (%v, %d)", rel.SuspectedTranscode, rel.EstimatedSourceBitrate, tt.wantSuspect, tt.wantBitrate)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/mse"
+)
+
+// Protocol encryption modes (MSE/PE header obfuscation).
+const (
+	EncryptionPrefer  = "prefer"  // obfuscated handshakes first, plaintext fallback (library default)
+	EncryptionRequire = "require" // only RC4-encrypted connections
+	EncryptionOff     = "off"     // plaintext handshakes first, obfuscated fallback
+)
+
+// ParsePortRange parses a listen port or port range ("6881" or
+// "6881-6889"). An empty string means a random port and returns 0, 0.
+func ParsePortRange(s string) (lo, hi int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+	loStr, hiStr, isRange := strings.Cut(s, "-")
+	if !isRange {
+		hiStr = loStr
+	}
+	lo, err1 := strconv.Atoi(strings.TrimSpace(loStr))
+	hi, err2 := strconv.Atoi(strings.TrimSpace(hiStr))
+	if err1 != nil || err2 != nil || lo < 1 || hi > 65535 || lo > hi {
+		return 0, 0, fmt.Errorf("invalid port range %q (want PORT or LOW-HIGH within 1-65535)", s)
+	}
+	return lo, hi, nil
+}
+
+// listenPorts returns the ports of [lo, hi] in the order a worker tries
+// them, starting at a random one so concurrent workers rarely collide.
+// Returns [0] (any free port) when no range is set.
+func listenPorts(lo, hi int) []int {
+	if lo <= 0 {
+		return []int{0}
+	}
+	n := hi - lo + 1
+	start := rand.IntN(n)
+	ports := make([]int, n)
+	for i := range ports {
+		ports[i] = lo + (start+i)%n
+	}
+	return ports
+}
+
+// ResolveBindAddress returns the IP for a bind address, given either as an
+// IP literal or as a network interface name (its first IPv4 address, or
+// IPv6 if it has none or IPv4 is not wanted).
+func ResolveBindAddress(addr string, disableIPv6 bool) (net.IP, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		if disableIPv6 && ip.To4() == nil {
+			return nil, fmt.Errorf("bind address %s is IPv6 but IPv6 is disabled", addr)
+		}
+		return ip, nil
+	}
+	iface, err := net.InterfaceByName(addr)
+	if err != nil {
+		return nil, fmt.Errorf("bind address %q is neither an IP nor an interface: %w", addr, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("addresses of %s: %w", addr, err)
+	}
+	var v6 net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if v6 == nil {
+			v6 = ipNet.IP
+		}
+	}
+	if v6 == nil || disableIPv6 {
+		return nil, fmt.Errorf("interface %s has no usable address", addr)
+	}
+	return v6, nil
+}
+
+// ValidEncryption reports whether mode is a known encryption mode ("" is
+// the library default).
+func ValidEncryption(mode string) bool {
+	switch mode {
+	case "", EncryptionPrefer, EncryptionRequire, EncryptionOff:
+		return true
+	}
+	return false
+}
+
+// applyEncryption sets the header obfuscation policy and crypto methods of
+// a client config for an encryption mode.
+func applyEncryption(tcfg *torrent.ClientConfig, mode string) error {
+	switch mode {
+	case "", EncryptionPrefer:
+		tcfg.HeaderObfuscationPolicy = torrent.HeaderObfuscationPolicy{Preferred: true}
+	case EncryptionOff:
+		tcfg.HeaderObfuscationPolicy = torrent.HeaderObfuscationPolicy{Preferred: false}
+	case EncryptionRequire:
+		tcfg.HeaderObfuscationPolicy = torrent.HeaderObfuscationPolicy{Preferred: true, RequirePreferred: true}
+		tcfg.CryptoProvides = mse.CryptoMethodRC4
+		tcfg.CryptoSelector = func(provided mse.CryptoMethod) mse.CryptoMethod {
+			return provided & mse.CryptoMethodRC4 // 0 (refused) when the peer only offers plaintext
+		}
+	default:
+		return fmt.Errorf("unknown encryption mode %q (want prefer, require or off)", mode)
+	}
+	return nil
+}
+
+// bindClientConfig makes a client config listen on ip and dial trackers,
+// webseeds and peers from it. Peer dialers are added by bindPeerDialers once
+// the client exists, since the built-in TCP dialer ignores the listen
+// address.
+func bindClientConfig(tcfg *torrent.ClientConfig, ip net.IP) *net.Dialer {
+	host := ip.String()
+	tcfg.ListenHost = func(string) string { return host }
+	if ip.To4() != nil {
+		tcfg.DisableIPv6 = true
+	} else {
+		tcfg.DisableIPv4 = true
+	}
+	bound := &net.Dialer{LocalAddr: &net.TCPAddr{IP: ip}}
+	tcfg.DialForPeerConns = false
+	tcfg.TrackerDialContext = bound.DialContext
+	tcfg.HTTPDialContext = bound.DialContext
+	tcfg.TrackerListenPacket = func(network, _ string) (net.PacketConn, error) {
+		var lc net.ListenConfig
+		return lc.ListenPacket(context.Background(), network, net.JoinHostPort(host, "0"))
+	}
+	return bound
+}
+
+// bindPeerDialers adds the peer dialers of a client bound with
+// bindClientConfig: the uTP sockets (already bound to the listen address)
+// and a TCP dialer with the bound local address.
+func bindPeerDialers(client *torrent.Client, bound *net.Dialer, ip net.IP, disableTCP bool) {
+	for _, l := range client.Listeners() {
+		if d, ok := l.(torrent.Dialer); ok && strings.HasPrefix(d.DialerNetwork(), "udp") {
+			client.AddDialer(d)
+		}
+	}
+	if !disableTCP {
+		network := "tcp4"
+		if ip.To4() == nil {
+			network = "tcp6"
+		}
+		client.AddDialer(torrent.NetworkDialer{Network: network, Dialer: bound})
+	}
+}
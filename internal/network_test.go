@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/mse"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		lo, hi  int
+		wantErr bool
+	}{
+		{"", 0, 0, false},
+		{"6881", 6881, 6881, false},
+		{"6881-6889", 6881, 6889, false},
+		{" 6881 - 6889 ", 6881, 6889, false},
+		{"6889-6881", 0, 0, true},
+		{"0-10", 0, 0, true},
+		{"6881-70000", 0, 0, true},
+		{"abc", 0, 0, true},
+	}
+	for _, tt := range tests {
+		lo, hi, err := ParsePortRange(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePortRange(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if lo != tt.lo || hi != tt.hi {
+			t.Errorf("ParsePortRange(%q) = %d-%d, want %d-%d", tt.in, lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestListenPorts(t *testing.T) {
+	if got := listenPorts(0, 0); len(got) != 1 || got[0] != 0 {
+		t.Errorf("no range should try a random port, got %v", got)
+	}
+	got := listenPorts(6881, 6885)
+	sort.Ints(got)
+	for i, p := range got {
+		if p != 6881+i {
+			t.Fatalf("expected each port of the range once, got %v", got)
+		}
+	}
+}
+
+func TestResolveBindAddress(t *testing.T) {
+	if ip, err := ResolveBindAddress("", false); ip != nil || err != nil {
+		t.Errorf("empty address = %v, %v", ip, err)
+	}
+	if ip, err := ResolveBindAddress("127.0.0.1", false); err != nil || !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("IP literal = %v, %v", ip, err)
+	}
+	if _, err := ResolveBindAddress("::1", true); err == nil {
+		t.Error("expected error for an IPv6 address with IPv6 disabled")
+	}
+	if _, err := ResolveBindAddress("no-such-iface0", false); err == nil {
+		t.Error("expected error for an unknown interface")
+	}
+
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		ip, err := ResolveBindAddress(iface.Name, false)
+		if err != nil {
+			t.Fatalf("loopback interface %s: %v", iface.Name, err)
+		}
+		if !ip.IsLoopback() {
+			t.Errorf("expected a loopback address for %s, got %v", iface.Name, ip)
+		}
+		break
+	}
+}
+
+func TestApplyEncryption(t *testing.T) {
+	tcfg := torrent.NewDefaultClientConfig()
+	if err := applyEncryption(tcfg, EncryptionRequire); err != nil {
+		t.Fatal(err)
+	}
+	if !tcfg.HeaderObfuscationPolicy.RequirePreferred || tcfg.CryptoProvides != mse.CryptoMethodRC4 {
+		t.Errorf("require should force RC4, got %+v provides=%v", tcfg.HeaderObfuscationPolicy, tcfg.CryptoProvides)
+	}
+	if m := tcfg.CryptoSelector(mse.CryptoMethodPlaintext); m != 0 {
+		t.Errorf("require must refuse plaintext-only peers, selected %v", m)
+	}
+
+	tcfg = torrent.NewDefaultClientConfig()
+	applyEncryption(tcfg, EncryptionOff)
+	if tcfg.HeaderObfuscationPolicy.Preferred {
+		t.Error("off should prefer plaintext handshakes")
+	}
+	if err := applyEncryption(tcfg, "always"); err == nil {
+		t.Error("expected error for an unknown mode")
+	}
+}
+
+func TestNewDownloader_BindAndPortRange(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+	if busy >= 65535 {
+		t.Skip("no free port after the busy one")
+	}
+
+	// The range holds a busy port and a (likely) free one
+	dl, err := NewDownloader(DownloadConfig{
+		TempDir:     t.TempDir(),
+		BindAddress: "127.0.0.1",
+		PortMin:     busy,
+		PortMax:     busy + 1,
+		DisableUTP:  true,
+		Encryption:  EncryptionRequire,
+	})
+	if err != nil {
+		t.Skipf("port %d not free: %v", busy+1, err)
+	}
+	defer dl.Close()
+	if port := dl.client.LocalPort(); port != busy+1 {
+		t.Errorf("expected the free port %d of the range, got %d", busy+1, port)
+	}
+	for _, l := range dl.client.Listeners() {
+		if ip := l.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() {
+			t.Errorf("listener not bound to 127.0.0.1: %v", l.Addr())
+		}
+	}
+
+	if _, err := NewDownloader(DownloadConfig{TempDir: t.TempDir(), DisableUTP: true, DisableTCP: true}); err == nil {
+		t.Error("expected error with both uTP and TCP disabled")
+	}
+}
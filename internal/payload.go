@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"math"
+)
+
+// Payload classes that refine an ffprobe_failed status.
+const (
+	PayloadGarbage   = "garbage_data"        // no structure: zeros, repeated filler, random-looking text
+	PayloadEncrypted = "encrypted"           // uniformly random bytes with no container header
+	PayloadTruncated = "truncated_container" // real video container that ffprobe could not read (damaged/incomplete)
+	PayloadWrong     = "wrong_container"     // a different file type (HTML page, archive, executable) renamed as video
+)
+
+const (
+	payloadSampleBytes = 1024 * 1024 // bytes read from the start of the file
+	payloadMinBytes    = 512         // smaller samples are not classified
+	encryptedEntropy   = 7.95        // bits/byte above which data is indistinguishable from random
+	textPrintableRatio = 0.95        // share of printable bytes that makes a sample text
+)
+
+// videoMagics are signatures of video containers at a fixed offset.
+var videoMagics = []struct {
+	offset int
+	magic  []byte
+	name   string
+}{
+	{0, []byte{0x1A, 0x45, 0xDF, 0xA3}, "Matroska/WebM"},
+	{4, []byte("ftyp"), "MP4/MOV"},
+	{4, []byte("moov"), "MP4/MOV"},
+	{4, []byte("mdat"), "MP4/MOV"},
+	{4, []byte("free"), "MP4/MOV"},
+	{4, []byte("wide"), "MP4/MOV"},
+	{8, []byte("AVI "), "AVI"},
+	{0, []byte{0x00, 0x00, 0x01, 0xBA}, "MPEG-PS"},
+	{0, []byte("FLV"), "FLV"},
+	{0, []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11}, "ASF/WMV"},
+	{0, []byte("OggS"), "Ogg"},
+	{0, []byte(".RMF"), "RealMedia"},
+}
+
+// otherMagics are signatures of common non-video files found renamed as video.
+var otherMagics = []struct {
+	magic []byte
+	name  string
+}{
+	{[]byte("PK\x03\x04"), "ZIP archive"},
+	{[]byte("Rar!\x1A\x07"), "RAR archive"},
+	{[]byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}, "7z archive"},
+	{[]byte{0x1F, 0x8B}, "gzip data"},
+	{[]byte("%PDF"), "PDF document"},
+	{[]byte("MZ"), "Windows executable"},
+	{[]byte{0x7F, 'E', 'L', 'F'}, "ELF executable"},
+	{[]byte{0x89, 'P', 'N', 'G'}, "PNG image"},
+	{[]byte{0xFF, 0xD8, 0xFF}, "JPEG image"},
+	{[]byte("Salted__"), "OpenSSL encrypted data"},
+	{[]byte("age-encryption.org"), "age encrypted data"},
+}
+
+// ClassifyPayloadFile reads the start of a file that ffprobe could not parse
+// and classifies its content. Returns "" when the file cannot be read.
+func ClassifyPayloadFile(path string) (class, detail string) {
+	f, err := openDownloadedFile(path)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, payloadSampleBytes))
+	if err != nil {
+		return "", ""
+	}
+	return classifyPayload(data)
+}
+
+// classifyPayload classifies a byte sample: a known video container that
+// failed to parse is truncated; another known file type, markup or text is the
+// wrong container; high-entropy bytes without a header are encrypted; anything
+// else is garbage.
+func classifyPayload(data []byte) (class, detail string) {
+	if len(data) < payloadMinBytes {
+		return "", ""
+	}
+
+	for _, m := range videoMagics {
+		if len(data) >= m.offset+len(m.magic) && bytes.Equal(data[m.offset:m.offset+len(m.magic)], m.magic) {
+			return PayloadTruncated, m.name + " header found but the stream could not be read"
+		}
+	}
+	if isMPEGTS(data) {
+		return PayloadTruncated, "MPEG-TS header found but the stream could not be read"
+	}
+
+	for _, m := range otherMagics {
+		if bytes.HasPrefix(data, m.magic) {
+			if m.name == "OpenSSL encrypted data" || m.name == "age encrypted data" {
+				return PayloadEncrypted, m.name
+			}
+			return PayloadWrong, m.name
+		}
+	}
+
+	if bytes.Count(data, []byte{0}) == len(data) {
+		return PayloadGarbage, "all zero bytes"
+	}
+
+	head := data[:min(len(data), 4096)]
+	if isText(head) {
+		lower := bytes.ToLower(head)
+		if bytes.Contains(lower, []byte("<html")) || bytes.Contains(lower, []byte("<!doctype")) {
+			return PayloadWrong, "HTML document"
+		}
+		return PayloadWrong, "text file"
+	}
+
+	if e := byteEntropy(data); e >= encryptedEntropy {
+		return PayloadEncrypted, "random-looking data without a container header"
+	}
+	return PayloadGarbage, "no recognizable structure"
+}
+
+// isMPEGTS reports whether data has TS sync bytes every 188 bytes.
+func isMPEGTS(data []byte) bool {
+	const packet = 188
+	if len(data) < packet*4 {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		if data[i*packet] != 0x47 {
+			return false
+		}
+	}
+	return true
+}
+
+// isText reports whether a sample is mostly printable ASCII/UTF-8 text.
+func isText(data []byte) bool {
+	printable := 0
+	for _, b := range data {
+		if b == '\n' || b == '\r' || b == '\t' || (b >= 0x20 && b != 0x7F) {
+			printable++
+		}
+	}
+	return float64(printable) >= float64(len(data))*textPrintableRatio && bytes.IndexByte(data, 0) < 0
+}
+
+// byteEntropy returns the Shannon entropy of data in bits per byte (0–8).
+func byteEntropy(data []byte) float64 {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	var e float64
+	n := float64(len(data))
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyPayload(t *testing.T) {
+	random := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	pad := func(prefix []byte) []byte {
+		return append(prefix, bytes.Repeat([]byte{0x11, 0x22, 0x33}, 400)...)
+	}
+	ts := make([]byte, 188*8)
+	for i := 0; i < len(ts); i += 188 {
+		ts[i] = 0x47
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"truncated mkv", pad([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x01}), PayloadTruncated},
+		{"truncated mp4", pad([]byte("\x00\x00\x00\x20ftypisom")), PayloadTruncated},
+		{"truncated avi", pad([]byte("RIFF\x00\x10\x00\x00AVI LIST")), PayloadTruncated},
+		{"truncated ts", ts, PayloadTruncated},
+		{"html error page", []byte("<!DOCTYPE html>\n<html><head><title>404 Not Found</title></head><body>" + strings.Repeat("Not found. ", 60) + "</body></html>"), PayloadWrong},
+		{"zip renamed", pad([]byte("PK\x03\x04")), PayloadWrong},
+		{"exe renamed", pad([]byte("MZ\x90\x00")), PayloadWrong},
+		{"openssl blob", append([]byte("Salted__"), random[:1024]...), PayloadEncrypted},
+		{"random bytes", random, PayloadEncrypted},
+		{"zeros", make([]byte, 4096), PayloadGarbage},
+		{"repeated filler", bytes.Repeat([]byte{0xDE, 0xAD, 0xBE, 0xEF, 0x00}, 1000), PayloadGarbage},
+		{"too small", []byte("tiny"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, detail := classifyPayload(tt.data)
+			if got != tt.want {
+				t.Errorf("classifyPayload = %q (%s), want %q", got, detail, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyPayloadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movie.mkv")
+	content := "<html><body>" + strings.Repeat("This torrent has been removed. ", 40) + "</body></html>"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	class, detail := ClassifyPayloadFile(path)
+	if class != PayloadWrong || detail != "HTML document" {
+		t.Errorf("got (%q, %q), want (%q, %q)", class, detail, PayloadWrong, "HTML document")
+	}
+
+	if class, _ := ClassifyPayloadFile("/nonexistent/movie.mkv"); class != "" {
+		t.Errorf("expected empty class for missing file, got %q", class)
+	}
+}
@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+const (
+	phashSize       = 32 // keyframes are decoded at 32×32 for hashing
+	phashKeyframes  = 8  // hashed keyframes per release
+	phashDecodeMax  = 24 // keyframes decoded to find enough non-flat ones
+	phashMaxDist    = 10 // max pHash Hamming distance for matching frames
+	dhashMaxDist    = 10 // max dHash Hamming distance for matching frames
+	phashMatchRatio = 0.75
+)
+
+// decodeKeyframes decodes up to maxFrames keyframes of the first video
+// stream as 32×32 luma frames. Area scaling averages out resolution and
+// compression differences between encodes of the same picture. Reading
+// stops after span seconds (0 = no bound).
+func decodeKeyframes(ctx context.Context, ffmpegPath, filePath string, span float64, maxFrames int) ([]grayFrame, error) {
+	decCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	args := append([]string{"-v", "quiet", "-skip_frame", "nokey"}, inputArgs(filePath, span)...)
+	args = append(args,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("scale=%d:%d:flags=area", phashSize, phashSize),
+		"-fps_mode", "passthrough",
+		"-frames:v", fmt.Sprint(maxFrames),
+		"-f", "rawvideo",
+		"-pix_fmt", "gray",
+		"-",
+	)
+	return runGrayDecode(exec.CommandContext(decCtx, ffmpegPath, args...), phashSize, phashSize)
+}
+
+// dct1D computes an unnormalized DCT-II of in into out.
+func dct1D(in, out []float64) {
+	n := len(in)
+	for k := range out {
+		var sum float64
+		for i, v := range in {
+			sum += v * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+		}
+		out[k] = sum
+	}
+}
+
+// phash returns the 64-bit DCT perceptual hash of a 32×32 frame: one bit
+// per coefficient of the lowest 8×8 frequencies, set when it is above their
+// median (the DC term is left out of the median).
+func phash(f grayFrame) uint64 {
+	n := phashSize
+	rows := make([][]float64, n)
+	row := make([]float64, n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			row[x] = f.at(x, y)
+		}
+		rows[y] = make([]float64, 8)
+		dct1D(row, rows[y])
+	}
+
+	var coeffs [64]float64
+	col, out := make([]float64, n), make([]float64, 8)
+	for u := 0; u < 8; u++ {
+		for y := 0; y < n; y++ {
+			col[y] = rows[y][u]
+		}
+		dct1D(col, out)
+		for v := 0; v < 8; v++ {
+			coeffs[v*8+u] = out[v]
+		}
+	}
+
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	med := sorted[len(sorted)/2]
+
+	var h uint64
+	for i, c := range coeffs {
+		if c > med {
+			h |= 1 << uint(i)
+		}
+	}
+	return h
+}
+
+// dhash returns the 64-bit difference hash of a frame: it is averaged down
+// to 9×8 cells and each bit records whether a cell is brighter than its
+// right neighbour.
+func dhash(f grayFrame) uint64 {
+	var cells [8][9]float64
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			cells[y*8/f.height][x*9/f.width] += f.at(x, y)
+		}
+	}
+	var h uint64
+	bit := 0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if cells[y][x] > cells[y][x+1] {
+				h |= 1 << uint(bit)
+			}
+			bit++
+		}
+	}
+	return h
+}
+
+// fingerprintFrames hashes the first non-flat keyframes. Black frames,
+// fades and title cards on black would match across unrelated releases,
+// so they are skipped.
+func fingerprintFrames(frames []grayFrame) []FrameHash {
+	var hashes []FrameHash
+	for _, f := range frames {
+		if len(hashes) >= phashKeyframes {
+			break
+		}
+		if f.stddev() < upscaleMinStddev {
+			continue
+		}
+		hashes = append(hashes, FrameHash{
+			PHash: fmt.Sprintf("%016x", phash(f)),
+			DHash: fmt.Sprintf("%016x", dhash(f)),
+		})
+	}
+	return hashes
+}
+
+// ApplyFingerprint stores perceptual hashes of early keyframes in the
+// result for duplicate detection (see FindDuplicates), reading only the
+// first span seconds (see headSpan). Needs ffmpeg; the result is left
+// unchanged when decoding fails.
+// Modifies the result in-place.
+func ApplyFingerprint(ctx context.Context, ffmpegPath string, result *ScanResult, filePath string, span float64) {
+	if result == nil || result.Video == nil || ffmpegPath == "" || spanTooShort(span, minAnalysisSpan) {
+		return
+	}
+	frames, err := decodeKeyframes(ctx, ffmpegPath, filePath, span, phashDecodeMax)
+	if err != nil {
+		log.Printf("  [%s] fingerprint skipped: %v", TruncHash(result.InfoHash), err)
+		return
+	}
+	result.Fingerprints = fingerprintFrames(frames)
+	log.Printf("  [%s] fingerprint: %d keyframe hash(es)", TruncHash(result.InfoHash), len(result.Fingerprints))
+}
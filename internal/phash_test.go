@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+// sceneFrame returns a 32×32 frame of a few random low-frequency waves,
+// standing in for a downscaled keyframe.
+func sceneFrame(rng *rand.Rand) grayFrame {
+	type wave struct{ fx, fy, phase, amp float64 }
+	waves := make([]wave, 4)
+	for i := range waves {
+		waves[i] = wave{rng.Float64() * 3, rng.Float64() * 3, rng.Float64() * 2 * math.Pi, 20 + rng.Float64()*30}
+	}
+	pix := make([]byte, phashSize*phashSize)
+	for y := 0; y < phashSize; y++ {
+		for x := 0; x < phashSize; x++ {
+			v := 128.0
+			for _, w := range waves {
+				v += w.amp * math.Sin(2*math.Pi*(w.fx*float64(x)+w.fy*float64(y))/phashSize+w.phase)
+			}
+			pix[y*phashSize+x] = byte(min(255, max(0, v)))
+		}
+	}
+	return grayFrame{width: phashSize, height: phashSize, pix: pix}
+}
+
+// reencode simulates another encode of the same frame: a brightness shift
+// plus a little noise.
+func reencode(rng *rand.Rand, f grayFrame, shift int) grayFrame {
+	pix := make([]byte, len(f.pix))
+	for i, p := range f.pix {
+		pix[i] = byte(min(255, max(0, int(p)+shift+rng.Intn(7)-3)))
+	}
+	return grayFrame{width: f.width, height: f.height, pix: pix}
+}
+
+func TestPerceptualHashes_SamePicture(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		orig := sceneFrame(rng)
+		dup := reencode(rng, orig, 6)
+		if d := bits.OnesCount64(phash(orig) ^ phash(dup)); d > phashMaxDist {
+			t.Errorf("frame %d: pHash distance %d for re-encoded copy", i, d)
+		}
+		if d := bits.OnesCount64(dhash(orig) ^ dhash(dup)); d > dhashMaxDist {
+			t.Errorf("frame %d: dHash distance %d for re-encoded copy", i, d)
+		}
+	}
+}
+
+func TestPerceptualHashes_DifferentPictures(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 10; i++ {
+		a, b := sceneFrame(rng), sceneFrame(rng)
+		pd := bits.OnesCount64(phash(a) ^ phash(b))
+		dd := bits.OnesCount64(dhash(a) ^ dhash(b))
+		if pd <= phashMaxDist && dd <= dhashMaxDist {
+			t.Errorf("pair %d: unrelated frames matched (pHash %d, dHash %d)", i, pd, dd)
+		}
+	}
+}
+
+func TestFingerprintFrames_SkipsFlat(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	black := grayFrame{width: phashSize, height: phashSize, pix: make([]byte, phashSize*phashSize)}
+	frames := []grayFrame{black, black}
+	for i := 0; i < 10; i++ {
+		frames = append(frames, sceneFrame(rng))
+	}
+
+	hashes := fingerprintFrames(frames)
+	if len(hashes) != phashKeyframes {
+		t.Fatalf("expected %d hashes, got %d", phashKeyframes, len(hashes))
+	}
+	if want := fingerprintFrames(frames[2:3])[0]; hashes[0] != want {
+		t.Errorf("first hash should come from the first non-flat frame, got %+v want %+v", hashes[0], want)
+	}
+	if len(hashes[0].PHash) != 16 || len(hashes[0].DHash) != 16 {
+		t.Errorf("expected 16-digit hex hashes, got %+v", hashes[0])
+	}
+}
@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+const proxyDialTimeout = 15 * time.Second
+
+// errUDPOverProxy is returned for UDP traffic (uTP, DHT, UDP trackers) when
+// a proxy is set: SOCKS5 CONNECT and HTTP CONNECT only carry TCP, and sending
+// it directly would bypass the proxy.
+var errUDPOverProxy = errors.New("UDP is disabled when a proxy is set")
+
+// ErrProxyUnavailable is returned by CheckProxy when the proxy cannot be reached.
+var ErrProxyUnavailable = errors.New("proxy unavailable")
+
+// outboundTransport carries every outbound HTTP request of the project
+// (VirusTotal, ffprobe and whisper downloads). SetProxy routes it through
+// the configured proxy. The in-memory file server is loopback and does not
+// use it.
+var outboundTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+// newOutboundClient returns an HTTP client with a timeout that goes through
+// outboundTransport.
+func newOutboundClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: outboundTransport}
+}
+
+// parseProxy validates a proxy URL: socks5://, socks5h://, http:// or
+// https://, with optional user:password credentials. A missing port gets
+// the scheme's default.
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	var port string
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		port = "1080"
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (want socks5, socks5h, http or https)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u, nil
+}
+
+// proxyDialer returns a dialer that opens TCP connections through the
+// proxy, reaching it with forward.
+func proxyDialer(u *url.URL, forward *net.Dialer) (proxy.ContextDialer, error) {
+	if u.Scheme == "http" || u.Scheme == "https" {
+		return &httpConnectDialer{proxy: u, forward: forward}, nil
+	}
+	var auth *proxy.Auth
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: pass}
+	}
+	d, err := proxy.SOCKS5("tcp", u.Host, auth, forward)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy: %w", err)
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("SOCKS5 dialer does not support contexts")
+	}
+	return cd, nil
+}
+
+// httpConnectDialer tunnels TCP connections through an HTTP proxy with the
+// CONNECT method.
+type httpConnectDialer struct {
+	proxy   *url.URL
+	forward *net.Dialer
+}
+
+// DialContext implements proxy.ContextDialer.
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("HTTP proxy cannot dial %s", network)
+	}
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("dial proxy: %w", err)
+	}
+	if d.proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxy.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy TLS handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := d.proxy.User; u != nil {
+		pass, _ := u.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// The peer spoke first and its bytes were read along with the response
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose first bytes were already buffered.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// SetProxy routes all outbound HTTP requests through the proxy. It must be
+// called before any request is made. An empty URL leaves the transport as is.
+func SetProxy(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := parseProxy(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "http" || u.Scheme == "https" {
+		outboundTransport.Proxy = http.ProxyURL(u)
+		return nil
+	}
+	d, err := proxyDialer(u, &net.Dialer{Timeout: proxyDialTimeout})
+	if err != nil {
+		return err
+	}
+	outboundTransport.Proxy = nil
+	outboundTransport.DialContext = d.DialContext
+	return nil
+}
+
+// CheckProxy reports whether the proxy accepts TCP connections. An invalid
+// URL is returned as is; an unreachable proxy wraps ErrProxyUnavailable.
+func CheckProxy(ctx context.Context, raw string) error {
+	u, err := parseProxy(raw)
+	if err != nil {
+		return err
+	}
+	d := net.Dialer{Timeout: proxyDialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrProxyUnavailable, u.Host, err)
+	}
+	return conn.Close()
+}
+
+// RedactProxy returns the proxy URL with its password masked, for logging.
+func RedactProxy(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid)"
+	}
+	return u.Redacted()
+}
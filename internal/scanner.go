@@ -29,17 +29,22 @@ func ScanFromChannel(ctx context.Context, cfg Config, hashes <-chan string, stat
 		exePath, exePathErr := getExePath()
 		useIsolation := exePathErr == nil
 
+		// Temp-disk budget: each scan reserves the pieces it requests before
+		// downloading them (not needed when pieces stay in memory)
+		var budget *diskBudget
+		if cfg.DiskBudgetMB > 0 && cfg.MemoryCapMB <= 0 {
+			budget = newDiskBudget(int64(cfg.DiskBudgetMB) * 1024 * 1024)
+		}
+
 		var dl *Downloader
 		if !useIsolation {
 			// Fallback: create shared downloader for in-process mode
 			var dlErr error
-			dl, dlErr = NewDownloader(DownloadConfig{
-				TempDir:      cfg.TempDir,
-				StallTimeout: cfg.StallTimeout,
-				MaxTimeout:   cfg.MaxTimeout,
-				MinBytesMKV:  cfg.MinBytesMKV,
-				MinBytesMP4:  cfg.MinBytesMP4,
-			})
+			dlCfg := cfg.downloadConfig()
+			if budget != nil {
+				dlCfg.DiskReserve = budget.grow
+			}
+			dl, dlErr = NewDownloader(dlCfg)
 			if dlErr != nil {
 				// Drain the input channel to avoid blocking the sender
 				for h := range hashes {
@@ -85,7 +90,7 @@ func ScanFromChannel(ctx context.Context, cfg Config, hashes <-chan string, stat
 				if useIsolation {
 					// Subprocess isolation mode
 					workerInput := cfg.ToWorkerInput(h, idx, total)
-					workerOutput, wErr := processOneIsolated(ctx, exePath, workerInput, cfg.LogWriter)
+					workerOutput, wErr := processOneIsolated(ctx, exePath, workerInput, cfg.LogWriter, budget)
 					if wErr != nil {
 						result = ScanResult{
 							InfoHash:  h,
@@ -101,6 +106,15 @@ func ScanFromChannel(ctx context.Context, cfg Config, hashes <-chan string, stat
 				} else {
 					// Fallback in-process mode
 					result, downloaded, uploaded = processOneInProcess(ctx, dl, cfg, h, idx, total)
+					if budget != nil {
+						// The shared client holds one torrent per hash, and
+						// Cleanup has just dropped it
+						budget.release(h)
+					}
+				}
+
+				if result.InfoHashV1 == "" && result.InfoHashV2 == "" {
+					result.InfoHashV1, result.InfoHashV2 = hashVersions(h)
 				}
 
 				// Record stats
@@ -154,6 +168,13 @@ func Scan(ctx context.Context, cfg Config, hashes []string) <-chan ScanResult {
 // processOne handles a single torrent scan. It does NOT call Cleanup —
 // the caller is responsible for cleanup after capturing stats.
 func processOne(ctx context.Context, dl *Downloader, cfg Config, infoHash string) ScanResult {
+	result := scanTorrent(ctx, dl, cfg, infoHash)
+	result.InfoHashV1, result.InfoHashV2 = dl.InfoHashes(infoHash)
+	return result
+}
+
+// scanTorrent downloads and analyzes a torrent for processOne.
+func scanTorrent(ctx context.Context, dl *Downloader, cfg Config, infoHash string) ScanResult {
 	// Resolve language detection config once (cached after first call)
 	langCfg := ResolveLangDetect()
 	start := time.Now()
@@ -162,8 +183,14 @@ func processOne(ctx context.Context, dl *Downloader, cfg Config, infoHash string
 	// also request end pieces for MP4 files (for moov atom).
 	minBytes := cfg.MinBytesMKV
 
+	// Resolve ffprobe (done per-torrent to support concurrent access). With
+	// ffmpeg next to it the analyzers below run, and the download fetches
+	// enough gap-free media for them.
+	ffprobePath, ffprobeErr := ResolveFFprobe(cfg.FFprobePath)
+	analyze := ffprobeErr == nil && ResolveFFmpeg(ffprobePath) != ""
+
 	// Initial download
-	dlResult, err := dl.PartialDownload(ctx, infoHash, minBytes)
+	dlResult, err := dl.PartialDownload(ctx, infoHash, minBytes, analyze)
 	if err != nil {
 		// Even on download failure, try to capture file listing if metadata was resolved
 		result := errorResult(infoHash, err, start)
@@ -175,6 +202,10 @@ func processOne(ctx context.Context, dl *Downloader, cfg Config, infoHash string
 		if swarm != nil {
 			result.Swarm = swarm
 		}
+		// Music/audio-only torrents: analyze the audio files instead
+		if result.Status == "no_video" && result.Files != nil && len(result.Files.AudioFiles) > 0 {
+			return processAudioRelease(ctx, dl, cfg, infoHash, result.Files, result.Swarm, start)
+		}
 		return result
 	}
 
@@ -194,13 +225,11 @@ func processOne(ctx context.Context, dl *Downloader, cfg Config, infoHash string
 		minBytes = cfg.MinBytesMP4
 	}
 
-	// Resolve ffprobe (done per-torrent to support concurrent access)
-	ffprobePath, err := ResolveFFprobe(cfg.FFprobePath)
-	if err != nil {
+	if ffprobeErr != nil {
 		return ScanResult{
 			InfoHash:  infoHash,
 			Status:    "error",
-			Error:     err.Error(),
+			Error:     ffprobeErr.Error(),
 			ElapsedMs: time.Since(start).Milliseconds(),
 			Files:     torrentFiles,
 			Swarm:     swarmInfo,
@@ -240,8 +269,39 @@ func processOne(ctx context.Context, dl *Downloader, cfg Config, infoHash string
 				probeOtherVideoDurations(ctx, dl, infoHash, ffprobePath, dlResult.FileName, torrentFiles)
 			}
 
+			// The analyzers below stop at the gap-free head of the download;
+			// past it the file has holes up to the sampled tail.
+			var duration float64
+			if media.Video != nil {
+				duration = media.Video.Duration
+			}
+			head, size := dl.HeadBytes(infoHash, dlResult.TorrentPath)
+			span := headSpan(head, size, duration)
+
+			// Count subtitle cues in the downloaded data (forced vs full subs)
+			ApplySubtitleCues(ctx, ffprobePath, media, dlResult.FilePath, span)
+
+			// Analyze decoded frames (upscale detection)
+			ffmpegPath := ResolveFFmpeg(ffprobePath)
+			ApplyVideoAnalysis(ctx, ffmpegPath, media, dlResult.FilePath, span)
+
+			// Fake surround: stereo copied into 5.1/7.1 channels
+			ApplyUpmixDetection(ctx, ffmpegPath, media, dlResult.FilePath, span)
+
+			// Silent or copied "language" tracks padded in for multi-audio
+			ApplyDummyAudioDetection(ctx, ffmpegPath, media, dlResult.FilePath, span)
+
+			// Keyframe hashes for duplicate/re-release detection
+			ApplyFingerprint(ctx, ffmpegPath, media, dlResult.FilePath, span)
+
+			// Keyframe contact sheet for moderators (opt-in)
+			ApplyContactSheet(ctx, ffmpegPath, cfg, media, dlResult.FilePath, span)
+
 			// Detect language for single "und" audio tracks
-			ApplyLangDetection(ctx, langCfg, media, dlResult.FilePath)
+			ApplyLangDetection(ctx, langCfg, media, dlResult.FilePath, span)
+
+			// Probe external subtitle/audio files next to the main video
+			ApplySidecars(ctx, dl, ffprobePath, langCfg, media, dlResult.TorrentPath)
 
 			media.ElapsedMs = time.Since(start).Milliseconds()
 			return *media
@@ -263,8 +323,8 @@ func processOne(ctx context.Context, dl *Downloader, cfg Config, infoHash string
 		}
 	}
 
-	// All retries exhausted
-	return ScanResult{
+	// All retries exhausted — refine the failure by looking at the bytes
+	result := ScanResult{
 		InfoHash:  infoHash,
 		Status:    "ffprobe_failed",
 		File:      dlResult.FileName,
@@ -272,6 +332,12 @@ func processOne(ctx context.Context, dl *Downloader, cfg Config, infoHash string
 		Files:     torrentFiles,
 		Swarm:     swarmInfo,
 	}
+	if class, detail := ClassifyPayloadFile(dlResult.FilePath); class != "" {
+		log.Printf("  [%s] payload classified as %s: %s", TruncHash(infoHash), class, detail)
+		result.Status = class
+		result.Error = detail
+	}
+	return result
 }
 
 func errorResult(infoHash string, err error, start time.Time) ScanResult {
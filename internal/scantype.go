@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Scan types reported in VideoInfo.ScanType.
+const (
+	ScanProgressive = "progressive"
+	ScanInterlaced  = "interlaced"
+	ScanTelecined   = "telecined"
+)
+
+const (
+	idetFrames        = 300  // frames analyzed by idet
+	idetMinDetermined = 30   // classified frames needed for a verdict
+	fieldOrderConf    = 0.5  // confidence of a verdict taken from container flags only
+	telecineRepeated  = 0.3  // repeated-field share of soft telecine (3:2 repeats 2 fields in 5 frames)
+	telecineMinMixed  = 0.25 // interlaced share range of hard telecine (2 combed frames in 5)
+	telecineMaxMixed  = 0.55
+)
+
+var (
+	idetMultiRe    = regexp.MustCompile(`Multi frame detection:\s*TFF:\s*(\d+)\s*BFF:\s*(\d+)\s*Progressive:\s*(\d+)\s*Undetermined:\s*(\d+)`)
+	idetRepeatedRe = regexp.MustCompile(`Repeated Fields:\s*Neither:\s*(\d+)\s*Top:\s*(\d+)\s*Bottom:\s*(\d+)`)
+)
+
+// idetCounts holds the frame counts from ffmpeg's idet summary.
+type idetCounts struct {
+	tff, bff, progressive, undetermined int
+	neither, repeatTop, repeatBottom    int
+}
+
+// scanTypeFromFieldOrder maps ffprobe's field_order to a scan type.
+func scanTypeFromFieldOrder(fieldOrder string) string {
+	switch fieldOrder {
+	case "progressive":
+		return ScanProgressive
+	case "tt", "bb", "tb", "bt":
+		return ScanInterlaced
+	}
+	return ""
+}
+
+// runIdet runs ffmpeg's idet filter over the first frames of the downloaded
+// data, reading no more than span seconds (0 = no bound).
+func runIdet(ctx context.Context, ffmpegPath, filePath string, span float64) (idetCounts, error) {
+	decCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	args := append([]string{"-hide_banner"}, inputArgs(filePath, span)...)
+	args = append(args,
+		"-map", "0:v:0",
+		"-vf", "idet",
+		"-frames:v", fmt.Sprint(idetFrames),
+		"-f", "null",
+		"-",
+	)
+	cmd := exec.CommandContext(decCtx, ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	counts, ok := parseIdet(stderr.String())
+	if !ok {
+		if runErr != nil {
+			return counts, fmt.Errorf("ffmpeg idet failed: %w", runErr)
+		}
+		return counts, fmt.Errorf("no idet summary")
+	}
+	return counts, nil
+}
+
+// parseIdet extracts the multi-frame and repeated-field counts from idet's summary.
+func parseIdet(output string) (idetCounts, bool) {
+	var c idetCounts
+	m := idetMultiRe.FindStringSubmatch(output)
+	if m == nil {
+		return c, false
+	}
+	c.tff, _ = strconv.Atoi(m[1])
+	c.bff, _ = strconv.Atoi(m[2])
+	c.progressive, _ = strconv.Atoi(m[3])
+	c.undetermined, _ = strconv.Atoi(m[4])
+	if r := idetRepeatedRe.FindStringSubmatch(output); r != nil {
+		c.neither, _ = strconv.Atoi(r[1])
+		c.repeatTop, _ = strconv.Atoi(r[2])
+		c.repeatBottom, _ = strconv.Atoi(r[3])
+	}
+	return c, true
+}
+
+// classifyScanType turns idet counts into progressive, interlaced or
+// telecined with a confidence. Soft telecine shows up as repeated fields;
+// hard telecine as a steady ~40% of combed frames in a 29.97/30 fps stream.
+func classifyScanType(c idetCounts, frameRate float64) (string, float64, bool) {
+	interlaced := c.tff + c.bff
+	determined := interlaced + c.progressive
+	if determined < idetMinDetermined {
+		return "", 0, false
+	}
+	ratio := float64(interlaced) / float64(determined)
+
+	if total := c.neither + c.repeatTop + c.repeatBottom; total > 0 {
+		repeated := float64(c.repeatTop+c.repeatBottom) / float64(total)
+		if repeated >= telecineRepeated {
+			return ScanTelecined, roundConfidence(min(1, repeated/0.4)), true
+		}
+	}
+
+	ntsc := math.Abs(frameRate-29.97) < 0.1 || math.Abs(frameRate-30) < 0.1
+	switch {
+	case ntsc && ratio >= telecineMinMixed && ratio <= telecineMaxMixed:
+		return ScanTelecined, roundConfidence(1 - math.Abs(ratio-0.4)/0.4), true
+	case ratio > telecineMaxMixed || (!ntsc && ratio >= 0.5):
+		return ScanInterlaced, roundConfidence(ratio), true
+	default:
+		return ScanProgressive, roundConfidence(1 - ratio), true
+	}
+}
+
+// roundConfidence rounds a 0–1 confidence to two decimals.
+func roundConfidence(c float64) float64 {
+	return math.Round(max(0, min(1, c))*100) / 100
+}
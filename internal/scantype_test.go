@@ -0,0 +1,60 @@
+package internal
+
+import "testing"
+
+func TestParseIdet(t *testing.T) {
+	output := `[Parsed_idet_0 @ 0x5581] Repeated Fields: Neither:   241 Top:    30 Bottom:    29
+[Parsed_idet_0 @ 0x5581] Single frame detection: TFF:    12 BFF:     0 Progressive:   230 Undetermined:    58
+[Parsed_idet_0 @ 0x5581] Multi frame detection: TFF:     5 BFF:     1 Progressive:   290 Undetermined:     4`
+
+	c, ok := parseIdet(output)
+	if !ok {
+		t.Fatal("expected idet summary to parse")
+	}
+	want := idetCounts{tff: 5, bff: 1, progressive: 290, undetermined: 4, neither: 241, repeatTop: 30, repeatBottom: 29}
+	if c != want {
+		t.Errorf("parseIdet = %+v, want %+v", c, want)
+	}
+
+	if _, ok := parseIdet("frame=  300 fps=0.0"); ok {
+		t.Error("expected no summary")
+	}
+}
+
+func TestClassifyScanType(t *testing.T) {
+	tests := []struct {
+		name      string
+		counts    idetCounts
+		frameRate float64
+		want      string
+		ok        bool
+	}{
+		{"progressive", idetCounts{progressive: 295, tff: 2, neither: 300}, 23.976, ScanProgressive, true},
+		{"interlaced", idetCounts{tff: 280, progressive: 15, neither: 300}, 25, ScanInterlaced, true},
+		{"hard telecine", idetCounts{tff: 118, progressive: 180, neither: 300}, 29.97, ScanTelecined, true},
+		{"soft telecine", idetCounts{progressive: 300, neither: 180, repeatTop: 60, repeatBottom: 60}, 29.97, ScanTelecined, true},
+		{"mixed PAL stays progressive", idetCounts{tff: 90, progressive: 210, neither: 300}, 25, ScanProgressive, true},
+		{"undetermined", idetCounts{undetermined: 300}, 25, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conf, ok := classifyScanType(tt.counts, tt.frameRate)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("classifyScanType = (%q, %.2f, %v), want (%q, _, %v)", got, conf, ok, tt.want, tt.ok)
+			}
+			if ok && (conf <= 0 || conf > 1) {
+				t.Errorf("confidence out of range: %.2f", conf)
+			}
+		})
+	}
+}
+
+func TestScanTypeFromFieldOrder(t *testing.T) {
+	tests := map[string]string{"progressive": ScanProgressive, "tt": ScanInterlaced, "bt": ScanInterlaced, "unknown": ""}
+	for in, want := range tests {
+		if got := scanTypeFromFieldOrder(in); got != want {
+			t.Errorf("scanTypeFromFieldOrder(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sidecarSubExts are external subtitle formats probed alongside the main video.
+var sidecarSubExts = map[string]bool{
+	".srt": true, ".ass": true, ".ssa": true, ".vtt": true,
+	".sup": true, ".idx": true, ".sub": true, ".smi": true,
+}
+
+// sidecarAudioExts are external audio formats commonly used for extra dubs.
+// Generic music formats (.mp3, .flac) are excluded to avoid treating a
+// bundled soundtrack as alternate audio tracks.
+var sidecarAudioExts = map[string]bool{
+	".mka": true, ".ac3": true, ".eac3": true, ".dts": true, ".aac": true,
+}
+
+// textSidecarExts are sidecar formats whose content can be read for language detection.
+var textSidecarExts = map[string]bool{
+	".srt": true, ".ass": true, ".ssa": true, ".vtt": true, ".smi": true,
+}
+
+const (
+	maxSidecars         = 16              // max sidecar files probed per torrent
+	sidecarFullMaxBytes = 8 * 1024 * 1024 // sidecars up to this size are downloaded in full
+	sidecarHeaderBytes  = 4 * 1024 * 1024 // larger sidecars only get their header downloaded
+	sidecarTextSample   = 256 * 1024      // bytes of subtitle text read for language detection
+)
+
+// sidecarTagRe strips markup from subtitle lines: ASS override blocks and HTML-like tags.
+var sidecarTagRe = regexp.MustCompile(`\{[^}]*\}|<[^>]*>`)
+
+// selectSidecars picks the external subtitle and audio files worth probing,
+// most relevant first: files named after the main video, then files in the
+// same directory (or a subdirectory like "Subs/"). Audio sidecars must be
+// related to the main video by name or location.
+func selectSidecars(tf *TorrentFiles, mainPath string) []FileInfo {
+	if tf == nil {
+		return nil
+	}
+	mainDir := path.Dir(mainPath)
+	mainStem := fileStem(mainPath)
+
+	// VobSub .sub files are read through their .idx — skip them when paired.
+	idxStems := make(map[string]bool)
+	for _, f := range tf.SubFiles {
+		if strings.ToLower(f.Ext) == ".idx" {
+			idxStems[strings.TrimSuffix(f.Path, path.Ext(f.Path))] = true
+		}
+	}
+
+	type candidate struct {
+		file  FileInfo
+		score int
+	}
+	var candidates []candidate
+
+	score := func(f FileInfo) int {
+		s := 0
+		if mainStem != "" && strings.HasPrefix(fileStem(f.Path), mainStem) {
+			s += 2
+		}
+		dir := path.Dir(f.Path)
+		if dir == mainDir || path.Dir(dir) == mainDir {
+			s++
+		}
+		return s
+	}
+
+	for _, f := range tf.SubFiles {
+		ext := strings.ToLower(f.Ext)
+		if !sidecarSubExts[ext] {
+			continue
+		}
+		if ext == ".sub" && idxStems[strings.TrimSuffix(f.Path, path.Ext(f.Path))] {
+			continue
+		}
+		candidates = append(candidates, candidate{f, score(f)})
+	}
+	for _, f := range tf.AudioFiles {
+		if !sidecarAudioExts[strings.ToLower(f.Ext)] {
+			continue
+		}
+		if s := score(f); s > 0 {
+			candidates = append(candidates, candidate{f, s})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	if len(candidates) > maxSidecars {
+		candidates = candidates[:maxSidecars]
+	}
+
+	result := make([]FileInfo, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.file
+	}
+	return result
+}
+
+// fileStem returns the lowercased base name of a torrent path without its extension.
+func fileStem(p string) string {
+	base := path.Base(p)
+	return strings.ToLower(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// sidecarNameTags extracts language and flags from a sidecar file name, e.g.
+// "Movie.2019.en.forced.srt" → ("en", forced), "2_English.srt" → ("en").
+// Tokens are read backwards from the extension and parsing stops at the first
+// token that is not a language, flag or short index, so title words such as
+// "It" in "It.2017.srt" are not mistaken for a language. "hi" is the ISO 639-1
+// code for Hindi and only means hearing impaired next to another language
+// ("Movie.en.hi.srt").
+func sidecarNameTags(name, mainStem string) (lang string, forced, sdh bool) {
+	stem := fileStem(name)
+	if mainStem != "" && strings.HasPrefix(stem, mainStem) {
+		stem = stem[len(mainStem):]
+	}
+	tokens := strings.FieldsFunc(stem, func(r rune) bool {
+		return strings.ContainsRune("._- []()", r)
+	})
+
+	for i := len(tokens) - 1; i >= 0; i-- {
+		tok := tokens[i]
+		switch tok {
+		case "forced", "forzados", "foreign":
+			forced = true
+			continue
+		case "sdh", "cc":
+			sdh = true
+			continue
+		case "hi":
+			if lang != "" || precedingLang(tokens[:i]) {
+				sdh = true
+				continue
+			}
+		case "default", "full", "subs", "sub", "subtitles":
+			continue
+		}
+		if l := LangFromName(tok); l != "" {
+			if lang == "" {
+				lang = l
+			}
+			continue
+		}
+		if len(tok) <= 2 && strings.Trim(tok, "0123456789") == "" {
+			continue // track index prefix, e.g. "2_English"
+		}
+		if len(tok) == 2 && i > 0 && LangFromName(tokens[i-1]) != "" {
+			continue // region suffix, e.g. "pt-BR"
+		}
+		break
+	}
+	return lang, forced, sdh
+}
+
+// precedingLang reports whether the last token before the flag tokens at the
+// end of tokens is a language.
+func precedingLang(tokens []string) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch tokens[i] {
+		case "forced", "forzados", "foreign", "sdh", "cc", "hi", "default", "full", "subs", "sub", "subtitles":
+			continue
+		}
+		return LangFromName(tokens[i]) != ""
+	}
+	return false
+}
+
+// fetchSidecar downloads a sidecar file: small files in full, large ones
+// (e.g. .mka dubs, long .sup tracks) only their header.
+func fetchSidecar(ctx context.Context, dl *Downloader, infoHash string, f FileInfo) (string, error) {
+	if f.Size <= sidecarFullMaxBytes {
+		return dl.DownloadFullFile(ctx, infoHash, f.Path)
+	}
+	return dl.DownloadFileHeader(ctx, infoHash, f.Path, sidecarHeaderBytes)
+}
+
+// readSubtitleText reads the dialogue text of a text subtitle sidecar,
+// dropping cue numbers, timings, ASS metadata and markup.
+func readSubtitleText(localPath, ext string) string {
+	f, err := openDownloadedFile(localPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, sidecarTextSample))
+	if err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		l := strings.TrimSpace(string(line))
+		if ext == ".ass" || ext == ".ssa" {
+			if !strings.HasPrefix(l, "Dialogue:") {
+				continue
+			}
+			// Dialogue: Layer,Start,End,Style,Name,MarginL,MarginR,MarginV,Effect,Text
+			parts := strings.SplitN(l, ",", 10)
+			if len(parts) < 10 {
+				continue
+			}
+			l = strings.ReplaceAll(parts[9], `\N`, " ")
+		} else if l == "" || strings.Contains(l, "-->") || strings.Trim(l, "0123456789") == "" {
+			continue
+		}
+		sb.WriteString(sidecarTagRe.ReplaceAllString(l, " "))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// ApplySidecars downloads and probes external subtitle and audio files next to
+// the main video, and merges them into the result as external tracks.
+// Languages come from stream tags, then file name tokens, then content
+// (subtitle text, or Whisper for audio when enabled). External audio counts
+// toward Languages like embedded audio. Modifies the result in-place.
+func ApplySidecars(ctx context.Context, dl *Downloader, ffprobePath string, langCfg LangDetectConfig, result *ScanResult, mainPath string) {
+	if result == nil {
+		return
+	}
+	sidecars := selectSidecars(result.Files, mainPath)
+	if len(sidecars) == 0 {
+		return
+	}
+
+	log.Printf("  [%s] probing %d sidecar file(s)", TruncHash(result.InfoHash), len(sidecars))
+	mainStem := fileStem(mainPath)
+
+	for _, f := range sidecars {
+		ext := strings.ToLower(f.Ext)
+		name := path.Base(f.Path)
+
+		localPath, err := fetchSidecar(ctx, dl, result.InfoHash, f)
+		if err != nil {
+			log.Printf("  [%s] sidecar skip %s: %v", TruncHash(result.InfoHash), name, err)
+			continue
+		}
+		// VobSub: ffprobe reads the bitmaps from the .sub next to the .idx
+		if ext == ".idx" {
+			companion := strings.TrimSuffix(f.Path, path.Ext(f.Path)) + ".sub"
+			for _, sf := range result.Files.SubFiles {
+				if sf.Path == companion {
+					if _, err := fetchSidecar(ctx, dl, result.InfoHash, sf); err != nil {
+						log.Printf("  [%s] sidecar skip %s: %v", TruncHash(result.InfoHash), path.Base(companion), err)
+					}
+					break
+				}
+			}
+		}
+
+		media, err := ExtractMediaInfo(ctx, ffprobePath, localPath)
+		if err != nil {
+			log.Printf("  [%s] sidecar probe failed %s: %v", TruncHash(result.InfoHash), name, err)
+			continue
+		}
+
+		nameLang, forced, sdh := sidecarNameTags(name, mainStem)
+
+		if len(media.Subtitles) > 0 {
+			if cues, err := probeSubtitleCues(ctx, ffprobePath, localPath); err == nil && len(cues) == len(media.Subtitles) {
+				applySubtitleCues(media.Subtitles, cues, 0)
+			}
+			contentLang := ""
+			for i := range media.Subtitles {
+				track := &media.Subtitles[i]
+				if isUnknownLang(track.Lang) {
+					track.Lang = nameLang
+				}
+				if isUnknownLang(track.Lang) && textSidecarExts[ext] {
+					if contentLang == "" {
+						contentLang = DetectTextLanguage(readSubtitleText(localPath, ext))
+					}
+					track.Lang = contentLang
+				}
+				if isUnknownLang(track.Lang) {
+					track.Lang = "und"
+				}
+				track.Forced = track.Forced || forced
+				track.SDH = track.SDH || sdh
+				track.External = true
+				track.File = f.Path
+				result.Subtitles = append(result.Subtitles, *track)
+			}
+		}
+
+		for i := range media.Audio {
+			track := &media.Audio[i]
+			if isUnknownLang(track.Lang) && nameLang != "" {
+				track.Lang = nameLang
+			}
+			if isUnknownLang(track.Lang) && langCfg.Enabled {
+				if detected, err := DetectAudioLanguage(ctx, langCfg, localPath, 0, i); err == nil && detected != nil && detected.Language != "" {
+					track.Lang = NormalizeLang(detected.Language)
+				}
+			}
+			if track.Role == RoleMain {
+				track.Role = ClassifyAudioRole(nil, strings.TrimSuffix(name, path.Ext(name)))
+			}
+			track.External = true
+			track.File = f.Path
+			result.Audio = append(result.Audio, *track)
+		}
+
+		log.Printf("  [%s] sidecar %s: audio=%d subs=%d lang=%s",
+			TruncHash(result.InfoHash), name, len(media.Audio), len(media.Subtitles), valueOrNA(nameLang))
+	}
+
+	result.Languages = ComputeLanguages(nil, result.Audio)
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSidecarNameTags(t *testing.T) {
+	tests := []struct {
+		name       string
+		mainStem   string
+		wantLang   string
+		wantForced bool
+		wantSDH    bool
+	}{
+		{"Movie.2019.en.forced.srt", "movie.2019", "en", true, false},
+		{"Movie.2019.spa.srt", "movie.2019", "es", false, false},
+		{"Movie.2019.English.SDH.srt", "movie.2019", "en", false, true},
+		{"2_English.srt", "movie.2019", "en", false, false},
+		{"Movie.2019.pt-BR.srt", "movie.2019", "pt", false, false},
+		{"Movie.2019.en.hi.srt", "movie.2019", "en", false, true},
+		{"Movie.en.hi.srt", "", "en", false, true},
+		{"Movie.hi.srt", "", "hi", false, false},
+		{"Movie.2019.hi.forced.srt", "movie.2019", "hi", true, false},
+		{"It.2017.1080p.srt", "", "", false, false},
+		{"Movie.2019.srt", "movie.2019", "", false, false},
+		{"Other.Release.French.ac3", "movie.2019", "fr", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lang, forced, sdh := sidecarNameTags(tt.name, tt.mainStem)
+			if lang != tt.wantLang || forced != tt.wantForced || sdh != tt.wantSDH {
+				t.Errorf("sidecarNameTags(%q) = (%q, %v, %v), want (%q, %v, %v)",
+					tt.name, lang, forced, sdh, tt.wantLang, tt.wantForced, tt.wantSDH)
+			}
+		})
+	}
+}
+
+func TestSelectSidecars(t *testing.T) {
+	tf := AnalyzeFiles([]FileInfo{
+		{Path: "Movie/Movie.2019.mkv", Size: 4_000_000_000, Ext: ".mkv"},
+		{Path: "Movie/Subs/2_English.srt", Size: 80_000, Ext: ".srt"},
+		{Path: "Movie/Movie.2019.es.srt", Size: 70_000, Ext: ".srt"},
+		{Path: "Movie/Movie.2019.idx", Size: 100_000, Ext: ".idx"},
+		{Path: "Movie/Movie.2019.sub", Size: 20_000_000, Ext: ".sub"},
+		{Path: "Movie/Movie.2019.Latino.ac3", Size: 300_000_000, Ext: ".ac3"},
+		{Path: "Movie/OST/01 - Theme.flac", Size: 30_000_000, Ext: ".flac"},
+		{Path: "Extras/Bonus.ac3", Size: 10_000_000, Ext: ".ac3"},
+	})
+
+	got := selectSidecars(tf, "Movie/Movie.2019.mkv")
+
+	paths := make([]string, len(got))
+	for i, f := range got {
+		paths[i] = f.Path
+	}
+	joined := strings.Join(paths, ",")
+
+	if len(got) != 4 {
+		t.Fatalf("expected 4 sidecars, got %d: %s", len(got), joined)
+	}
+	// Files named after the main video come first
+	if !strings.HasPrefix(got[0].Path, "Movie/Movie.2019.") {
+		t.Errorf("expected stem-matching sidecar first, got %s", got[0].Path)
+	}
+	if strings.Contains(joined, "Movie.2019.sub") {
+		t.Error(".sub paired with .idx should be skipped")
+	}
+	if strings.Contains(joined, "Theme.flac") {
+		t.Error("music files should not be treated as audio sidecars")
+	}
+	if strings.Contains(joined, "Bonus.ac3") {
+		t.Error("unrelated audio files should not be treated as sidecars")
+	}
+	if !strings.Contains(joined, "Subs/2_English.srt") {
+		t.Error("subtitle in Subs/ folder should be selected")
+	}
+}
+
+func TestSelectSidecars_Cap(t *testing.T) {
+	var files []FileInfo
+	files = append(files, FileInfo{Path: "Show/E01.mkv", Size: 1_000_000_000, Ext: ".mkv"})
+	for i := 0; i < 40; i++ {
+		files = append(files, FileInfo{Path: "Show/Subs/sub" + string(rune('a'+i%26)) + ".srt", Size: 1000, Ext: ".srt"})
+	}
+
+	got := selectSidecars(AnalyzeFiles(files), "Show/E01.mkv")
+	if len(got) != maxSidecars {
+		t.Errorf("expected %d sidecars, got %d", maxSidecars, len(got))
+	}
+}
+
+func TestSelectSidecars_NilFiles(t *testing.T) {
+	if got := selectSidecars(nil, "movie.mkv"); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
+
+func TestReadSubtitleText_SRT(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub.srt")
+	content := "1\n00:00:01,000 --> 00:00:02,000\n<i>Hello there</i>\n\n2\n00:00:03,000 --> 00:00:04,000\nGeneral Kenobi\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readSubtitleText(path, ".srt")
+	if strings.Contains(got, "-->") || strings.Contains(got, "<i>") {
+		t.Errorf("timings/tags not stripped: %q", got)
+	}
+	if !strings.Contains(got, "Hello there") || !strings.Contains(got, "General Kenobi") {
+		t.Errorf("dialogue missing: %q", got)
+	}
+}
+
+func TestReadSubtitleText_ASS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub.ass")
+	content := "[Script Info]\nTitle: Test\n\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+		"Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,{\\i1}Hola, ¿qué tal?\\Nmuy bien\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readSubtitleText(path, ".ass")
+	if strings.Contains(got, "Title") || strings.Contains(got, "{") {
+		t.Errorf("metadata/override tags not stripped: %q", got)
+	}
+	if !strings.Contains(got, "Hola, ¿qué tal? muy bien") {
+		t.Errorf("dialogue missing: %q", got)
+	}
+}
+
+func TestReadSubtitleText_Missing(t *testing.T) {
+	if got := readSubtitleText("/nonexistent/sub.srt", ".srt"); got != "" {
+		t.Errorf("expected empty string, got %q", got)
+	}
+}
@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+const (
+	spectrumFFTSize   = 4096 // samples per FFT frame
+	spectrumSeconds   = 20   // seconds of audio decoded per track
+	spectrumMinFrames = 16   // non-silent frames needed for a verdict
+	spectrumSilence   = 1e-4 // frame RMS below this (~-80 dBFS) is skipped
+	spectrumSmoothHz  = 200  // moving-average width applied to the spectrum
+	spectrumAboveDB   = 12   // level above the noise floor that counts as content
+	spectrumCliffDB   = 25   // drop within 500 Hz of the cutoff that counts as a lowpass
+	spectrumMinRange  = 30   // minimum dB between program level and noise floor
+)
+
+// transcodeMaxCutoff is the highest cutoff still flagged as a transcode.
+// Encoders at 320 kbps lowpass around 20–20.5 kHz, which is indistinguishable
+// from the anti-alias rolloff of many genuine masters, so those are not flagged.
+const transcodeMaxCutoff = 19800
+
+// lossyCutoffs maps the lowpass frequency of common MP3/AAC encoder settings
+// to the source bitrate (bits per second), lowest first.
+var lossyCutoffs = []struct {
+	maxHz   float64
+	bitrate int
+}{
+	{13000, 64000},
+	{15500, 96000},
+	{17200, 128000},
+	{18200, 160000},
+	{19200, 192000},
+	{19800, 256000},
+	{20600, 320000},
+}
+
+// SpectrumResult is the outcome of a spectral analysis of decoded PCM.
+type SpectrumResult struct {
+	CutoffHz    int  // highest frequency with content above the noise floor
+	Sharp       bool // content ends in a steep cliff (encoder lowpass) rather than a natural rolloff
+	Transcode   bool // sharp cutoff well below Nyquist: likely decoded from a lossy source
+	FromBitrate int  // estimated source bitrate in bits/s (0 if not a transcode)
+}
+
+// decodePCM decodes up to seconds of the first audio stream to mono float32
+// PCM, resampled to sampleRate (0 keeps the native rate), reading no more
+// than span seconds of the input (0 = no bound). Partial files make ffmpeg
+// exit with an error once the data runs out — whatever was decoded is still
+// returned.
+func decodePCM(ctx context.Context, ffmpegPath, filePath string, span float64, seconds, sampleRate int) ([]float64, error) {
+	decCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	args := append([]string{"-v", "quiet"}, inputArgs(filePath, span)...)
+	args = append(args,
+		"-map", "0:a:0",
+		"-t", fmt.Sprint(seconds),
+		"-ac", "1",
+	)
+	if sampleRate > 0 {
+		args = append(args, "-ar", fmt.Sprint(sampleRate))
+	}
+	args = append(args, "-f", "f32le", "-acodec", "pcm_f32le", "-")
+	cmd := exec.CommandContext(decCtx, ffmpegPath, args...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	runErr := cmd.Run()
+
+	data := stdout.Bytes()
+	if len(data) < 4*spectrumFFTSize*spectrumMinFrames {
+		if runErr != nil {
+			return nil, fmt.Errorf("ffmpeg decode failed: %w", runErr)
+		}
+		return nil, fmt.Errorf("decoded audio too short")
+	}
+
+	samples := make([]float64, len(data)/4)
+	for i := range samples {
+		samples[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+	}
+	return samples, nil
+}
+
+// fft computes an in-place iterative radix-2 FFT. len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+// averageSpectrum returns the mean power spectrum in dB of the non-silent
+// Hann-windowed frames, and the number of frames used.
+func averageSpectrum(samples []float64) ([]float64, int) {
+	n := spectrumFFTSize
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+
+	power := make([]float64, n/2)
+	buf := make([]complex128, n)
+	frames := 0
+	for off := 0; off+n <= len(samples); off += n {
+		frame := samples[off : off+n]
+		var sum float64
+		for _, s := range frame {
+			sum += s * s
+		}
+		if math.Sqrt(sum/float64(n)) < spectrumSilence {
+			continue
+		}
+		for i, s := range frame {
+			buf[i] = complex(s*window[i], 0)
+		}
+		fft(buf)
+		for k := range power {
+			power[k] += real(buf[k])*real(buf[k]) + imag(buf[k])*imag(buf[k])
+		}
+		frames++
+	}
+
+	db := make([]float64, len(power))
+	for k, p := range power {
+		db[k] = 10 * math.Log10(p/float64(max(frames, 1))+1e-20)
+	}
+	return db, frames
+}
+
+// AnalyzeSpectrum looks for the high-frequency cutoff of decoded PCM.
+// Lossy encoders apply a steep lowpass (e.g. 16 kHz for 128 kbps MP3) that
+// survives decoding, so a lossless file with such a cliff well below Nyquist
+// was most likely transcoded from a lossy source. Returns false when there
+// is not enough non-silent audio or dynamic range for a verdict.
+func AnalyzeSpectrum(samples []float64, sampleRate int) (SpectrumResult, bool) {
+	var res SpectrumResult
+	if sampleRate <= 0 {
+		return res, false
+	}
+	db, frames := averageSpectrum(samples)
+	if frames < spectrumMinFrames {
+		return res, false
+	}
+
+	binHz := float64(sampleRate) / spectrumFFTSize
+	width := max(1, int(spectrumSmoothHz/binHz))
+	smoothed := movingAverage(db, width)
+	bin := func(hz float64) int { return min(len(smoothed)-1, max(0, int(hz/binHz))) }
+
+	// Noise floor: median of the top 3% of the spectrum (excluding the edge bins)
+	top := append([]float64(nil), smoothed[len(smoothed)*97/100:len(smoothed)-2]...)
+	sort.Float64s(top)
+	floor := top[len(top)/2]
+
+	// Program level: mean of the 1–4 kHz band
+	var ref float64
+	lo, hi := bin(1000), bin(4000)
+	for k := lo; k < hi; k++ {
+		ref += smoothed[k]
+	}
+	ref /= float64(hi - lo)
+	if ref-floor < spectrumMinRange {
+		return res, false
+	}
+
+	cutoff := 0
+	for k := len(smoothed) - 1; k > lo; k-- {
+		if smoothed[k] > floor+spectrumAboveDB {
+			cutoff = k
+			break
+		}
+	}
+	if cutoff == 0 {
+		return res, false
+	}
+	// The cliff is measured 500 Hz below the edge, where a lowpassed signal
+	// is still at full level and a natural rolloff is already near the floor.
+	level := smoothed[bin(float64(cutoff)*binHz-500)]
+	res.Sharp = level-floor >= spectrumCliffDB
+
+	// Smoothing and window leakage spread the edge; refine it on the raw
+	// spectrum as the highest bin above the midpoint of the cliff.
+	edge := (level + floor) / 2
+	for k := min(len(db)-1, cutoff+width); k > cutoff-width; k-- {
+		if db[k] > edge {
+			cutoff = k
+			break
+		}
+	}
+	res.CutoffHz = int(math.Round(float64(cutoff) * binHz))
+
+	if res.Sharp && res.CutoffHz <= transcodeMaxCutoff && float64(res.CutoffHz) < float64(sampleRate)/2*0.9 {
+		res.Transcode = true
+		res.FromBitrate = sourceBitrateForCutoff(float64(res.CutoffHz))
+	}
+	return res, true
+}
+
+// sourceBitrateForCutoff maps a lowpass frequency to the typical bitrate of
+// the lossy encoder setting that produces it.
+func sourceBitrateForCutoff(hz float64) int {
+	for _, c := range lossyCutoffs {
+		if hz < c.maxHz {
+			return c.bitrate
+		}
+	}
+	return lossyCutoffs[len(lossyCutoffs)-1].bitrate
+}
+
+// movingAverage smooths values with a centered window of the given width.
+func movingAverage(values []float64, width int) []float64 {
+	out := make([]float64, len(values))
+	half := width / 2
+	for i := range values {
+		lo, hi := max(0, i-half), min(len(values), i+half+1)
+		var sum float64
+		for _, v := range values[lo:hi] {
+			sum += v
+		}
+		out[i] = sum / float64(hi-lo)
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// synthMusic builds a test signal: sines every 100 Hz up to maxHz with
+// amplitudes falling slopeDB per kHz, plus a faint noise floor.
+func synthMusic(sampleRate int, seconds float64, maxHz, slopeDB float64) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	n := int(float64(sampleRate) * seconds)
+	samples := make([]float64, n)
+	for f := 100.0; f <= maxHz; f += 100 {
+		amp := 0.01 * math.Pow(10, -slopeDB*f/1000/20)
+		phase := rng.Float64() * 2 * math.Pi
+		w := 2 * math.Pi * f / float64(sampleRate)
+		for i := range samples {
+			samples[i] += amp * math.Sin(w*float64(i)+phase)
+		}
+	}
+	for i := range samples {
+		samples[i] += (rng.Float64() - 0.5) * 1e-6
+	}
+	return samples
+}
+
+func TestAnalyzeSpectrum_MP3Transcode(t *testing.T) {
+	res, ok := AnalyzeSpectrum(synthMusic(44100, 2, 16000, 1), 44100)
+	if !ok {
+		t.Fatal("expected a verdict")
+	}
+	if res.CutoffHz < 15500 || res.CutoffHz > 16500 {
+		t.Errorf("expected cutoff near 16 kHz, got %d", res.CutoffHz)
+	}
+	if !res.Sharp || !res.Transcode {
+		t.Errorf("expected sharp cutoff flagged as transcode, got %+v", res)
+	}
+	if res.FromBitrate != 128000 {
+		t.Errorf("expected 128 kbps source, got %d", res.FromBitrate)
+	}
+}
+
+func TestAnalyzeSpectrum_NaturalRolloff(t *testing.T) {
+	// Content fades gradually into the noise floor: no lowpass cliff
+	res, ok := AnalyzeSpectrum(synthMusic(44100, 2, 22000, 4), 44100)
+	if ok && res.Transcode {
+		t.Errorf("natural rolloff should not be flagged, got %+v", res)
+	}
+}
+
+func TestAnalyzeSpectrum_FullBand(t *testing.T) {
+	res, ok := AnalyzeSpectrum(synthMusic(44100, 2, 21000, 1), 44100)
+	if ok && res.Transcode {
+		t.Errorf("full-band content should not be flagged, got %+v", res)
+	}
+}
+
+func TestAnalyzeSpectrum_Silence(t *testing.T) {
+	if _, ok := AnalyzeSpectrum(make([]float64, 44100*2), 44100); ok {
+		t.Error("expected no verdict for silence")
+	}
+}
+
+func TestSourceBitrateForCutoff(t *testing.T) {
+	tests := []struct {
+		hz   float64
+		want int
+	}{
+		{11000, 64000},
+		{16000, 128000},
+		{19000, 192000},
+		{20500, 320000},
+	}
+	for _, tt := range tests {
+		if got := sourceBitrateForCutoff(tt.hz); got != tt.want {
+			t.Errorf("sourceBitrateForCutoff(%.0f) = %d, want %d", tt.hz, got, tt.want)
+		}
+	}
+}
+
+func TestFFT(t *testing.T) {
+	// A pure sine at bin 8 puts all energy into bins 8 and n-8
+	n := 64
+	x := make([]complex128, n)
+	for i := range x {
+		x[i] = complex(math.Sin(2*math.Pi*8*float64(i)/float64(n)), 0)
+	}
+	fft(x)
+	for k := range x {
+		mag := math.Hypot(real(x[k]), imag(x[k]))
+		if k == 8 || k == n-8 {
+			if math.Abs(mag-float64(n)/2) > 1e-9 {
+				t.Errorf("bin %d: expected %f, got %f", k, float64(n)/2, mag)
+			}
+		} else if mag > 1e-9 {
+			t.Errorf("bin %d: expected 0, got %f", k, mag)
+		}
+	}
+}
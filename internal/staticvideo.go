@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	staticSampleEvery = 24   // decode every Nth frame (~1 s at 24 fps)
+	staticSampleMax   = 40   // max frames decoded for motion analysis
+	staticFrameWidth  = 160  // frames are downscaled; motion survives, compression noise mostly doesn't
+	staticMinPairs    = 8    // frame pairs needed for a verdict
+	staticDiffScore   = 0.01 // mean absolute luma difference (0–1) below which a pair is static
+	staticRatio       = 0.9  // share of static pairs that flags a fake video
+)
+
+// staticFrameSize returns the downscaled size used for motion analysis,
+// keeping the aspect ratio with an even height.
+func staticFrameSize(width, height int) (int, int) {
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	w := min(width, staticFrameWidth)
+	h := max(2, int(math.Round(float64(height)*float64(w)/float64(width)/2))*2)
+	return w, h
+}
+
+// frameDifference returns the mean absolute luma difference between two
+// frames of equal size, scaled to 0–1 like ffmpeg's scene score.
+func frameDifference(a, b grayFrame) float64 {
+	if len(a.pix) != len(b.pix) || len(a.pix) == 0 {
+		return 1
+	}
+	var sum int
+	for i, p := range a.pix {
+		d := int(p) - int(b.pix[i])
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return float64(sum) / float64(len(a.pix)) / 255
+}
+
+// assessStaticVideo compares consecutive sampled frames. Spam releases are
+// often a still image with a URL overlay and a voiceover, so almost every
+// pair is identical apart from compression noise; even a slideshow only
+// changes at a few scene cuts. Pairs of flat frames (black screens, fades)
+// are skipped so a dark intro doesn't count as static. Returns the share of
+// static pairs and false when too few pairs were usable.
+func assessStaticVideo(frames []grayFrame) (suspect bool, ratio float64, ok bool) {
+	pairs, static := 0, 0
+	for i := 1; i < len(frames); i++ {
+		a, b := frames[i-1], frames[i]
+		if a.stddev() < upscaleMinStddev && b.stddev() < upscaleMinStddev {
+			continue
+		}
+		pairs++
+		if frameDifference(a, b) < staticDiffScore {
+			static++
+		}
+	}
+	if pairs < staticMinPairs {
+		return false, 0, false
+	}
+	ratio = float64(static) / float64(pairs)
+	return ratio >= staticRatio, math.Round(ratio*100) / 100, true
+}
+
+// flagFakeVideo raises a clean threat level to warning and notes the reason
+// on the main video file, so static spam shows up next to the other threats.
+func flagFakeVideo(tf *TorrentFiles, fileName string) {
+	if tf == nil {
+		return
+	}
+	for i, vf := range tf.VideoFiles {
+		if vf.Path == fileName || strings.HasSuffix(vf.Path, "/"+fileName) {
+			tf.VideoFiles[i].Reason = "Static video (still image or slideshow)"
+			break
+		}
+	}
+	if tf.ThreatLevel == "clean" {
+		tf.ThreatLevel = "warning"
+	}
+}
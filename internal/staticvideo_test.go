@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// noisyCopy returns f with ±amp luma noise, like re-encoding the same image.
+func noisyCopy(rng *rand.Rand, f grayFrame, amp int) grayFrame {
+	pix := make([]byte, len(f.pix))
+	for i, p := range f.pix {
+		pix[i] = byte(min(255, max(0, int(p)+rng.Intn(2*amp+1)-amp)))
+	}
+	return grayFrame{width: f.width, height: f.height, pix: pix}
+}
+
+func TestAssessStaticVideo_StillImage(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	still := texturedFrame(rng, 160, 90, 16, 235, 0, 0)
+	var frames []grayFrame
+	for i := 0; i < 20; i++ {
+		frames = append(frames, noisyCopy(rng, still, 2))
+	}
+
+	suspect, ratio, ok := assessStaticVideo(frames)
+	if !ok || !suspect || ratio < 0.99 {
+		t.Errorf("expected static video, got suspect=%v ratio=%.2f ok=%v", suspect, ratio, ok)
+	}
+}
+
+func TestAssessStaticVideo_Slideshow(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	var frames []grayFrame
+	for slide := 0; slide < 2; slide++ {
+		img := texturedFrame(rng, 160, 90, 16, 235, 0, 0)
+		for i := 0; i < 15; i++ {
+			frames = append(frames, noisyCopy(rng, img, 1))
+		}
+	}
+
+	if suspect, ratio, _ := assessStaticVideo(frames); !suspect {
+		t.Errorf("expected slideshow flagged, ratio=%.2f", ratio)
+	}
+}
+
+func TestAssessStaticVideo_Motion(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	var frames []grayFrame
+	for i := 0; i < 20; i++ {
+		frames = append(frames, texturedFrame(rng, 160, 90, 16, 235, 0, 10))
+	}
+
+	suspect, ratio, ok := assessStaticVideo(frames)
+	if !ok || suspect || ratio != 0 {
+		t.Errorf("expected moving video, got suspect=%v ratio=%.2f ok=%v", suspect, ratio, ok)
+	}
+}
+
+func TestAssessStaticVideo_BlackFramesSkipped(t *testing.T) {
+	frames := make([]grayFrame, 20)
+	for i := range frames {
+		frames[i] = grayFrame{width: 160, height: 90, pix: make([]byte, 160*90)}
+	}
+	if _, _, ok := assessStaticVideo(frames); ok {
+		t.Error("expected no verdict for black frames")
+	}
+}
+
+func TestStaticFrameSize(t *testing.T) {
+	tests := []struct{ w, h, wantW, wantH int }{
+		{1920, 1080, 160, 90},
+		{1920, 800, 160, 66},
+		{720, 576, 160, 128},
+		{128, 72, 128, 72},
+		{0, 1080, 0, 0},
+	}
+	for _, tt := range tests {
+		if w, h := staticFrameSize(tt.w, tt.h); w != tt.wantW || h != tt.wantH {
+			t.Errorf("staticFrameSize(%d, %d) = %dx%d, want %dx%d", tt.w, tt.h, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestFlagFakeVideo(t *testing.T) {
+	tf := &TorrentFiles{
+		VideoFiles:  []FileInfo{{Path: "Movie/sample.mkv"}, {Path: "Movie/movie.mkv"}},
+		ThreatLevel: "clean",
+	}
+	flagFakeVideo(tf, "movie.mkv")
+	if tf.ThreatLevel != "warning" {
+		t.Errorf("expected warning, got %s", tf.ThreatLevel)
+	}
+	if tf.VideoFiles[1].Reason == "" || tf.VideoFiles[0].Reason != "" {
+		t.Errorf("expected reason on main video only, got %+v", tf.VideoFiles)
+	}
+
+	tf = &TorrentFiles{ThreatLevel: "dangerous"}
+	flagFakeVideo(tf, "movie.mkv")
+	if tf.ThreatLevel != "dangerous" {
+		t.Errorf("expected dangerous unchanged, got %s", tf.ThreatLevel)
+	}
+	flagFakeVideo(nil, "movie.mkv")
+}
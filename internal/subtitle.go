@@ -0,0 +1,273 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bitmapSubCodecs are image-based subtitle formats (rendered bitmaps, not OCR-able text).
+var bitmapSubCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true, "dvd_subtitle": true, "dvb_subtitle": true,
+	"xsub": true, "pgssub": true,
+}
+
+// textSubCodecs are text-based subtitle formats.
+var textSubCodecs = map[string]bool{
+	"subrip": true, "srt": true, "ass": true, "ssa": true, "webvtt": true,
+	"mov_text": true, "text": true, "microdvd": true, "subviewer": true,
+	"subviewer1": true, "sami": true, "eia_608": true, "ttml": true,
+	"jacosub": true, "realtext": true, "mpl2": true, "pjs": true,
+	"stl": true, "vplayer": true, "dvb_teletext": true, "arib_caption": true,
+}
+
+// sdhKeywords are title keywords that mark subtitles for the deaf and hard of hearing.
+var sdhKeywords = []string{"sdh", "hearing impaired", "hard of hearing", "closed caption", "sordos"}
+
+// sdhTokenRe matches standalone "HI" / "CC" tokens (e.g. "English (HI)", "[CC]").
+var sdhTokenRe = regexp.MustCompile(`(^|[^a-z0-9])(hi|cc)([^a-z0-9]|$)`)
+
+// forcedRe matches standalone title keywords that mark forced/foreign-parts-only
+// subtitles. A leading "non"/"not"/"no" is captured so that "Non-Forced" or
+// "not forced" can be told apart from a forced track.
+var forcedRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}])(?:(non|not|no)[\s-]+)?(forced|forzados|forcés|foreign parts|signs|non-english parts)(?:[^\p{L}\p{N}]|$)`)
+
+const (
+	// pgsClearPacketMax is the packet size at or below which a PGS packet is
+	// treated as a "clear screen" display set rather than a new cue.
+	pgsClearPacketMax = 64
+
+	// forcedMinWindow is the minimum span (seconds) of downloaded subtitle data
+	// required before cue density is used to infer a forced-only track.
+	forcedMinWindow = 600
+
+	// forcedMaxCuesPerMin is the cue density below which a track spanning at
+	// least forcedMinWindow may be forced-only (full subs run ~10/min).
+	forcedMaxCuesPerMin = 1.0
+
+	// forcedSiblingRatio is how many times denser a full track in the same
+	// language must be before a sparse track next to it is inferred forced.
+	// A lone sparse track may just have a quiet opening and is left unflagged.
+	forcedSiblingRatio = 4.0
+)
+
+// classifySubtitle fills in the text/bitmap, SDH and forced flags of a
+// subtitle track from its codec, ffprobe dispositions and title.
+func classifySubtitle(track *SubtitleTrack, disposition map[string]int) {
+	codec := strings.ToLower(track.Codec)
+	track.IsBitmap = bitmapSubCodecs[codec]
+	track.IsText = textSubCodecs[codec]
+
+	title := strings.ToLower(track.Title)
+	if disposition["hearing_impaired"] == 1 || containsAny(title, sdhKeywords...) || sdhTokenRe.MatchString(title) {
+		track.SDH = true
+	}
+	if forcedTitle(title) {
+		track.Forced = true
+	}
+}
+
+// forcedTitle reports whether a lowercased subtitle title marks a forced
+// track, ignoring negated keywords.
+func forcedTitle(title string) bool {
+	for _, m := range forcedRe.FindAllStringSubmatch(title, -1) {
+		if m[1] == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// subtitleCues holds cue statistics for a single subtitle stream.
+type subtitleCues struct {
+	count int
+	first float64
+	last  float64
+	times []float64 // cue timestamps, in packet order
+}
+
+// within returns the number of cues and the latest cue timestamp up to span
+// seconds (all cues when span is 0).
+func (c subtitleCues) within(span float64) (int, float64) {
+	if span <= 0 {
+		return c.count, c.last
+	}
+	n, last := 0, 0.0
+	for _, t := range c.times {
+		if t <= span {
+			n++
+			last = math.Max(last, t)
+		}
+	}
+	return n, last
+}
+
+// ffprobePacketsOutput matches `ffprobe -show_entries stream=...:packet=...`.
+type ffprobePacketsOutput struct {
+	Streams []struct {
+		Index     int    `json:"index"`
+		CodecName string `json:"codec_name"`
+	} `json:"streams"`
+	Packets []struct {
+		StreamIndex int    `json:"stream_index"`
+		PtsTime     string `json:"pts_time"`
+		Size        string `json:"size"`
+	} `json:"packets"`
+}
+
+// probeSubtitleCues counts subtitle packets per subtitle stream in a (partial) file.
+// The returned slice is indexed by subtitle ordinal (the n-th subtitle stream),
+// matching the order of ScanResult.Subtitles.
+func probeSubtitleCues(ctx context.Context, ffprobePath, filePath string) ([]subtitleCues, error) {
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-select_streams", "s",
+		"-show_entries", "stream=index,codec_name:packet=stream_index,pts_time,size",
+		"--", filePath,
+	)
+	// A truncated file makes ffprobe exit non-zero after printing what it
+	// could read, so parse the output regardless of the exit status.
+	output, runErr := cmd.Output()
+	if len(output) == 0 {
+		if runErr != nil {
+			return nil, fmt.Errorf("ffprobe packets: %w", runErr)
+		}
+		return nil, fmt.Errorf("ffprobe packets: empty output")
+	}
+
+	return countSubtitleCues(output)
+}
+
+// countSubtitleCues parses ffprobe packet output into per-stream cue statistics.
+func countSubtitleCues(output []byte) ([]subtitleCues, error) {
+	var data ffprobePacketsOutput
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("ffprobe packets JSON parse failed: %w", err)
+	}
+
+	ordinal := make(map[int]int, len(data.Streams))
+	codecs := make([]string, len(data.Streams))
+	for i, s := range data.Streams {
+		ordinal[s.Index] = i
+		codecs[i] = s.CodecName
+	}
+
+	cues := make([]subtitleCues, len(data.Streams))
+	for _, p := range data.Packets {
+		i, ok := ordinal[p.StreamIndex]
+		if !ok {
+			continue
+		}
+		if codecs[i] == "hdmv_pgs_subtitle" {
+			if size, err := strconv.Atoi(p.Size); err == nil && size <= pgsClearPacketMax {
+				continue
+			}
+		}
+		pts, err := strconv.ParseFloat(p.PtsTime, 64)
+		if err != nil {
+			continue
+		}
+		c := &cues[i]
+		if c.count == 0 || pts < c.first {
+			c.first = pts
+		}
+		if pts > c.last {
+			c.last = pts
+		}
+		c.count++
+		c.times = append(c.times, pts)
+	}
+	return cues, nil
+}
+
+// ApplySubtitleCues probes the downloaded data for subtitle packets and records
+// approximate cue counts and first/last cue timestamps on each subtitle track.
+// Text tracks whose cue density over a long enough window is too low for full
+// dialogue, next to a full track in the same language, are marked as forced.
+// The density only counts the first span seconds, the gap-free head of the
+// download (see headSpan; 0 = the whole file): cues from a sampled tail would
+// otherwise stretch the window over the missing middle. Modifies the result
+// in-place; failures are logged.
+func ApplySubtitleCues(ctx context.Context, ffprobePath string, result *ScanResult, filePath string, span float64) {
+	if result == nil || len(result.Subtitles) == 0 {
+		return
+	}
+
+	cues, err := probeSubtitleCues(ctx, ffprobePath, filePath)
+	if err != nil {
+		log.Printf("  [%s] subtitle cue probe failed: %v", TruncHash(result.InfoHash), err)
+		return
+	}
+	if len(cues) != len(result.Subtitles) {
+		log.Printf("  [%s] subtitle cue probe: stream count mismatch (%d vs %d), skipping",
+			TruncHash(result.InfoHash), len(cues), len(result.Subtitles))
+		return
+	}
+
+	applySubtitleCues(result.Subtitles, cues, span)
+}
+
+// applySubtitleCues copies cue statistics onto subtitle tracks (same length and order)
+// and flags sparse text tracks as forced, judging density over the first span
+// seconds (0 = all cues). A track is only inferred forced when another track
+// in the same language carries full dialogue over the same window, so a quiet
+// opening on the only track for a language is not mistaken for forced subs.
+func applySubtitleCues(tracks []SubtitleTrack, cues []subtitleCues, span float64) {
+	// The contiguous window is bounded by the latest cue seen on any track.
+	window := 0.0
+	for _, c := range cues {
+		_, last := c.within(span)
+		window = math.Max(window, last)
+	}
+
+	density := make([]float64, len(cues))
+	for i, c := range cues {
+		track := &tracks[i]
+		track.CueCount = c.count
+		if c.count > 0 {
+			track.FirstCue = math.Round(c.first*1000) / 1000
+			track.LastCue = math.Round(c.last*1000) / 1000
+		}
+		if window > 0 {
+			count, _ := c.within(span)
+			density[i] = float64(count) / (window / 60)
+		}
+	}
+	if window < forcedMinWindow {
+		return
+	}
+
+	for i := range tracks {
+		track := &tracks[i]
+		if !track.Forced && track.IsText && density[i] < forcedMaxCuesPerMin &&
+			sparserThanSibling(tracks, density, i) {
+			track.Forced = true
+		}
+	}
+}
+
+// sparserThanSibling reports whether another full (not forced) track in the
+// same language as tracks[i] is at least forcedSiblingRatio times denser.
+// Tracks without a known language have no siblings.
+func sparserThanSibling(tracks []SubtitleTrack, density []float64, i int) bool {
+	lang := tracks[i].Lang
+	if lang == "" || lang == "und" {
+		return false
+	}
+	for j, t := range tracks {
+		if j == i || t.Forced || t.Lang != lang {
+			continue
+		}
+		if density[j] >= forcedMaxCuesPerMin && density[j] >= density[i]*forcedSiblingRatio {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestClassifySubtitle_TextVsBitmap(t *testing.T) {
+	tests := []struct {
+		codec      string
+		wantText   bool
+		wantBitmap bool
+	}{
+		{"subrip", true, false},
+		{"ass", true, false},
+		{"webvtt", true, false},
+		{"mov_text", true, false},
+		{"hdmv_pgs_subtitle", false, true},
+		{"dvd_subtitle", false, true},
+		{"dvb_subtitle", false, true},
+		{"unknown_codec", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			track := SubtitleTrack{Codec: tt.codec}
+			classifySubtitle(&track, nil)
+			if track.IsText != tt.wantText {
+				t.Errorf("IsText = %v, want %v", track.IsText, tt.wantText)
+			}
+			if track.IsBitmap != tt.wantBitmap {
+				t.Errorf("IsBitmap = %v, want %v", track.IsBitmap, tt.wantBitmap)
+			}
+		})
+	}
+}
+
+func TestClassifySubtitle_SDHAndForced(t *testing.T) {
+	tests := []struct {
+		name        string
+		title       string
+		disposition map[string]int
+		wantSDH     bool
+		wantForced  bool
+	}{
+		{"plain", "English", nil, false, false},
+		{"sdh title", "English SDH", nil, true, false},
+		{"hi token", "English (HI)", nil, true, false},
+		{"cc token", "[CC]", nil, true, false},
+		{"hi inside word", "Chinese", nil, false, false},
+		{"hearing impaired disposition", "", map[string]int{"hearing_impaired": 1}, true, false},
+		{"forced title", "English Forced", nil, false, true},
+		{"signs", "Signs & Songs", nil, false, true},
+		{"spanish forced", "Español (Forzados)", nil, false, true},
+		{"non-english parts", "Non-English Parts", nil, false, true},
+		{"non-forced", "Non-Forced", nil, false, false},
+		{"not forced", "English (Full, not forced)", nil, false, false},
+		{"no forzados", "Español (No Forzados)", nil, false, false},
+		{"forced inside word", "Enforcedly", nil, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := SubtitleTrack{Codec: "subrip", Title: tt.title}
+			classifySubtitle(&track, tt.disposition)
+			if track.SDH != tt.wantSDH {
+				t.Errorf("SDH = %v, want %v", track.SDH, tt.wantSDH)
+			}
+			if track.Forced != tt.wantForced {
+				t.Errorf("Forced = %v, want %v", track.Forced, tt.wantForced)
+			}
+		})
+	}
+}
+
+func TestCountSubtitleCues(t *testing.T) {
+	output := []byte(`{
+		"streams": [
+			{"index": 2, "codec_name": "subrip"},
+			{"index": 3, "codec_name": "hdmv_pgs_subtitle"},
+			{"index": 4, "codec_name": "ass"}
+		],
+		"packets": [
+			{"stream_index": 2, "pts_time": "12.500", "size": "40"},
+			{"stream_index": 3, "pts_time": "13.000", "size": "8000"},
+			{"stream_index": 2, "pts_time": "15.250", "size": "52"},
+			{"stream_index": 3, "pts_time": "15.000", "size": "30"},
+			{"stream_index": 2, "pts_time": "N/A", "size": "20"},
+			{"stream_index": 9, "pts_time": "1.0", "size": "20"},
+			{"stream_index": 2, "pts_time": "20.000", "size": "61"}
+		]
+	}`)
+
+	cues, err := countSubtitleCues(output)
+	if err != nil {
+		t.Fatalf("countSubtitleCues: %v", err)
+	}
+	if len(cues) != 3 {
+		t.Fatalf("expected 3 streams, got %d", len(cues))
+	}
+	if cues[0].count != 3 || cues[0].first != 12.5 || cues[0].last != 20 {
+		t.Errorf("subrip cues = %+v, want count=3 first=12.5 last=20", cues[0])
+	}
+	// PGS clear packets (small) are not counted as cues
+	if cues[1].count != 1 || cues[1].first != 13 {
+		t.Errorf("pgs cues = %+v, want count=1 first=13", cues[1])
+	}
+	if cues[2].count != 0 {
+		t.Errorf("ass cues = %+v, want count=0", cues[2])
+	}
+}
+
+func TestCountSubtitleCues_InvalidJSON(t *testing.T) {
+	if _, err := countSubtitleCues([]byte("not json")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestApplySubtitleCues_ForcedHeuristic(t *testing.T) {
+	tracks := []SubtitleTrack{
+		{Lang: "en", Codec: "subrip", IsText: true},
+		{Lang: "en", Codec: "subrip", IsText: true},
+		{Lang: "en", Codec: "hdmv_pgs_subtitle", IsBitmap: true},
+	}
+	cues := []subtitleCues{
+		{count: 150, first: 30, last: 900}, // full subs, 15 min window
+		{count: 4, first: 120, last: 600},  // forced-only
+		{count: 2, first: 100, last: 200},  // bitmap: density not used
+	}
+
+	applySubtitleCues(tracks, cues, 0)
+
+	if tracks[0].CueCount != 150 || tracks[0].FirstCue != 30 || tracks[0].LastCue != 900 {
+		t.Errorf("track 0 cues not applied: %+v", tracks[0])
+	}
+	if tracks[0].Forced {
+		t.Error("dense track should not be forced")
+	}
+	if !tracks[1].Forced {
+		t.Error("sparse text track should be forced")
+	}
+	if tracks[2].Forced {
+		t.Error("bitmap track should not be flagged forced by cue density")
+	}
+}
+
+func TestApplySubtitleCues_ShortWindowNoForced(t *testing.T) {
+	tracks := []SubtitleTrack{{Codec: "subrip", IsText: true}}
+	cues := []subtitleCues{{count: 1, first: 10, last: 120}}
+
+	applySubtitleCues(tracks, cues, 0)
+
+	if tracks[0].Forced {
+		t.Error("window shorter than forcedMinWindow should not infer forced")
+	}
+}
+
+func TestApplySubtitleCues_GapAfterHead(t *testing.T) {
+	// 5 minutes of full subs in the head, then one cue from the sampled tail
+	// 90 minutes in: the gap must not count as a window without dialogue.
+	var times []float64
+	for ts := 10.0; ts < 300; ts += 6 {
+		times = append(times, ts)
+	}
+	times = append(times, 5400)
+	tracks := []SubtitleTrack{{Lang: "en", Codec: "subrip", IsText: true}}
+	cues := []subtitleCues{{count: len(times), first: 10, last: 5400, times: times}}
+
+	applySubtitleCues(tracks, cues, 320)
+	if tracks[0].Forced {
+		t.Error("density should only be judged over the contiguous head")
+	}
+	if tracks[0].CueCount != len(times) || tracks[0].LastCue != 5400 {
+		t.Errorf("cue statistics should still cover all downloaded data: %+v", tracks[0])
+	}
+
+	// A long enough head still catches a forced track next to a full one.
+	full, sparse := []float64{}, []float64{120, 400, 610, 5400}
+	for ts := 10.0; ts < 900; ts += 6 {
+		full = append(full, ts)
+	}
+	tracks = []SubtitleTrack{{Lang: "en", Codec: "subrip", IsText: true}, {Lang: "en", Codec: "subrip", IsText: true}}
+	cues = []subtitleCues{
+		{count: len(full), first: 10, last: full[len(full)-1], times: full},
+		{count: len(sparse), first: 120, last: 5400, times: sparse},
+	}
+	applySubtitleCues(tracks, cues, 900)
+	if tracks[0].Forced || !tracks[1].Forced {
+		t.Errorf("head of 900s: full forced=%v, sparse forced=%v", tracks[0].Forced, tracks[1].Forced)
+	}
+}
+
+func TestApplySubtitleCues_QuietOpeningNotForced(t *testing.T) {
+	// A full subtitle track whose downloaded head covers a quiet 10-minute
+	// opening looks as sparse as a forced track.
+	quiet := []float64{200, 480, 650}
+	var full []float64
+	for ts := 10.0; ts < 650; ts += 6 {
+		full = append(full, ts)
+	}
+	cases := []struct {
+		name   string
+		tracks []SubtitleTrack
+		cues   []subtitleCues
+	}{
+		{
+			name:   "sole track",
+			tracks: []SubtitleTrack{{Lang: "en", Codec: "subrip", IsText: true}},
+			cues:   []subtitleCues{{count: len(quiet), first: quiet[0], last: 650, times: quiet}},
+		},
+		{
+			name: "full track in another language",
+			tracks: []SubtitleTrack{
+				{Lang: "en", Codec: "subrip", IsText: true},
+				{Lang: "es", Codec: "subrip", IsText: true},
+			},
+			cues: []subtitleCues{
+				{count: len(quiet), first: quiet[0], last: 650, times: quiet},
+				{count: len(full), first: 10, last: full[len(full)-1], times: full},
+			},
+		},
+		{
+			name: "untagged siblings",
+			tracks: []SubtitleTrack{
+				{Codec: "subrip", IsText: true},
+				{Codec: "subrip", IsText: true},
+			},
+			cues: []subtitleCues{
+				{count: len(quiet), first: quiet[0], last: 650, times: quiet},
+				{count: len(full), first: 10, last: full[len(full)-1], times: full},
+			},
+		},
+	}
+	for _, tc := range cases {
+		applySubtitleCues(tc.tracks, tc.cues, 0)
+		if tc.tracks[0].Forced {
+			t.Errorf("%s: quiet track inferred forced", tc.name)
+		}
+	}
+}
+
+func TestSubtitleTrack_CueFieldsAlwaysEmitted(t *testing.T) {
+	for _, track := range []SubtitleTrack{
+		{Codec: "subrip", CueCount: 3, FirstCue: 0, LastCue: 12},
+		{Codec: "hdmv_pgs_subtitle"},
+	} {
+		data, err := json.Marshal(track)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{`"first_cue":`, `"last_cue":`} {
+			if !strings.Contains(string(data), key) {
+				t.Errorf("%s missing from %s", key, data)
+			}
+		}
+	}
+}
@@ -49,19 +49,23 @@ var warningExts = map[string]string{
 	".js":       "JavaScript file (review if unexpected)",
 }
 
-// Known safe extensions for media torrents.
+// Known safe extensions for media torrents. videoExts also decides which
+// files the downloader considers as the main video; each one needs an entry
+// in containerStrategies.
 var videoExts = map[string]bool{
 	".mkv": true, ".mp4": true, ".avi": true, ".m4v": true,
 	".wmv": true, ".ts": true, ".mov": true, ".flv": true,
 	".webm": true, ".mpg": true, ".mpeg": true, ".m2ts": true,
 	".vob": true, ".ogv": true, ".divx": true, ".3gp": true,
+	".mts": true, ".asf": true, ".mk3d": true,
 }
 
 var audioExts = map[string]bool{
 	".mp3": true, ".flac": true, ".aac": true, ".ogg": true,
 	".wav": true, ".wma": true, ".m4a": true, ".opus": true,
 	".ac3": true, ".dts": true, ".eac3": true, ".mka": true,
-	".ape": true, ".alac": true, ".aiff": true,
+	".ape": true, ".alac": true, ".aiff": true, ".aif": true,
+	".wv": true, ".tta": true, ".dsf": true, ".dff": true,
 }
 
 var subtitleExts = map[string]bool{
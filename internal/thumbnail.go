@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	thumbTileWidth   = 320 // width of each frame in the contact sheet
+	thumbMinInterval = 2   // seconds between picked keyframes
+	thumbMaxFrames   = 36
+)
+
+// thumbFormats maps a contact sheet format to its file extension, MIME type
+// and ffmpeg encoder arguments.
+var thumbFormats = map[string]struct {
+	ext, mime string
+	args      []string
+}{
+	"jpg":  {".jpg", "image/jpeg", []string{"-q:v", "4"}},
+	"webp": {".webp", "image/webp", []string{"-c:v", "libwebp", "-quality", "75"}},
+}
+
+// ValidThumbnailFormat reports whether format is a supported contact sheet
+// format ("" is jpg).
+func ValidThumbnailFormat(format string) bool {
+	_, ok := thumbFormats[format]
+	return ok || format == ""
+}
+
+// thumbGrid returns the columns and rows of a contact sheet for n frames:
+// as square as possible, wider than tall.
+func thumbGrid(n int) (cols, rows int) {
+	if n <= 0 {
+		return 0, 0
+	}
+	cols = int(math.Ceil(math.Sqrt(float64(n))))
+	rows = (n + cols - 1) / cols
+	return cols, rows
+}
+
+// renderContactSheet extracts up to n keyframes at least thumbMinInterval
+// seconds apart and tiles them into a single image at outPath. Only the
+// keyframes in the downloaded data are used; the tile filter flushes an
+// incomplete sheet when the partial file runs out, so ffmpeg's error exit
+// is ignored if the image was written. Reading stops after span seconds
+// (0 = no bound).
+func renderContactSheet(ctx context.Context, ffmpegPath, filePath string, span float64, outPath, format string, n int) error {
+	f, ok := thumbFormats[format]
+	if !ok {
+		return fmt.Errorf("unsupported thumbnail format %q", format)
+	}
+	cols, rows := thumbGrid(n)
+	renderCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	args := append([]string{"-v", "quiet", "-skip_frame", "nokey"}, inputArgs(filePath, span)...)
+	args = append(args,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf(`select=isnan(prev_selected_t)+gte(t-prev_selected_t\,%d),scale=%d:-2,tile=%dx%d:padding=4:margin=4`,
+			thumbMinInterval, thumbTileWidth, cols, rows),
+		"-fps_mode", "passthrough",
+		"-frames:v", "1",
+	)
+	args = append(args, f.args...)
+	args = append(args, "-y", outPath)
+	runErr := exec.CommandContext(renderCtx, ffmpegPath, args...).Run()
+
+	if info, err := os.Stat(outPath); err != nil || info.Size() == 0 {
+		if runErr != nil {
+			return fmt.Errorf("ffmpeg contact sheet failed: %w", runErr)
+		}
+		return fmt.Errorf("no keyframes decoded")
+	}
+	return nil
+}
+
+// ApplyContactSheet renders a contact sheet of the main video's keyframes
+// when cfg.Thumbnails > 0. The image is written to cfg.ThumbnailDir as
+// <info_hash>.<ext>, or embedded as base64 when no directory is set (pipe
+// mode). Only the first span seconds are read (see headSpan). Needs ffmpeg;
+// failures are logged and leave the result unchanged.
+// Modifies the result in-place.
+func ApplyContactSheet(ctx context.Context, ffmpegPath string, cfg Config, result *ScanResult, filePath string, span float64) {
+	if cfg.Thumbnails <= 0 || result == nil || result.Video == nil || ffmpegPath == "" || spanTooShort(span, minAnalysisSpan) {
+		return
+	}
+	format := cfg.ThumbnailFormat
+	if format == "" {
+		format = "jpg"
+	}
+	f, ok := thumbFormats[format]
+	if !ok {
+		log.Printf("  [%s] contact sheet skipped: unsupported format %q", TruncHash(result.InfoHash), format)
+		return
+	}
+	n := min(cfg.Thumbnails, thumbMaxFrames)
+
+	tmp := filepath.Join(cfg.TempDir, result.InfoHash+"-sheet"+f.ext)
+	defer os.Remove(tmp)
+	if err := renderContactSheet(ctx, ffmpegPath, filePath, span, tmp, format, n); err != nil {
+		log.Printf("  [%s] contact sheet skipped: %v", TruncHash(result.InfoHash), err)
+		return
+	}
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		log.Printf("  [%s] contact sheet skipped: %v", TruncHash(result.InfoHash), err)
+		return
+	}
+
+	cols, rows := thumbGrid(n)
+	sheet := &ContactSheet{Format: format, MIME: f.mime, Grid: fmt.Sprintf("%dx%d", cols, rows)}
+	if cfg.ThumbnailDir == "" {
+		sheet.Data = base64.StdEncoding.EncodeToString(data)
+	} else {
+		dst := filepath.Join(cfg.ThumbnailDir, result.InfoHash+f.ext)
+		if err := os.MkdirAll(cfg.ThumbnailDir, 0o755); err != nil {
+			log.Printf("  [%s] contact sheet skipped: %v", TruncHash(result.InfoHash), err)
+			return
+		}
+		if err := os.WriteFile(dst, data, 0o644); err != nil {
+			log.Printf("  [%s] contact sheet skipped: %v", TruncHash(result.InfoHash), err)
+			return
+		}
+		sheet.Path = dst
+	}
+	result.ContactSheet = sheet
+	log.Printf("  [%s] contact sheet: %d bytes (%s)", TruncHash(result.InfoHash), len(data), format)
+}
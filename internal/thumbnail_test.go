@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"context"
+	"testing"
+)
+
+func TestThumbGrid(t *testing.T) {
+	tests := []struct{ n, cols, rows int }{
+		{1, 1, 1},
+		{4, 2, 2},
+		{5, 3, 2},
+		{9, 3, 3},
+		{10, 4, 3},
+		{16, 4, 4},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if cols, rows := thumbGrid(tt.n); cols != tt.cols || rows != tt.rows {
+			t.Errorf("thumbGrid(%d) = %dx%d, want %dx%d", tt.n, cols, rows, tt.cols, tt.rows)
+		}
+	}
+}
+
+func TestApplyContactSheet_Disabled(t *testing.T) {
+	result := &ScanResult{InfoHash: "abc123", Video: &VideoInfo{Width: 1920, Height: 1080}}
+
+	ApplyContactSheet(context.Background(), "ffmpeg", Config{}, result, "movie.mkv", 0)
+	if result.ContactSheet != nil {
+		t.Error("expected no contact sheet when thumbnails are disabled")
+	}
+
+	ApplyContactSheet(context.Background(), "", Config{Thumbnails: 9}, result, "movie.mkv", 0)
+	if result.ContactSheet != nil {
+		t.Error("expected no contact sheet without ffmpeg")
+	}
+}
+
+func TestRenderContactSheet_UnsupportedFormat(t *testing.T) {
+	err := renderContactSheet(context.Background(), "ffmpeg", "movie.mkv", 0, "out.png", "png", 9)
+	if err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestValidThumbnailFormat(t *testing.T) {
+	for _, f := range []string{"", "jpg", "webp"} {
+		if !ValidThumbnailFormat(f) {
+			t.Errorf("ValidThumbnailFormat(%q) = false, want true", f)
+		}
+	}
+	for _, f := range []string{"png", "JPG", "jpeg"} {
+		if ValidThumbnailFormat(f) {
+			t.Errorf("ValidThumbnailFormat(%q) = true, want false", f)
+		}
+	}
+}
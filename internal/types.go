@@ -11,23 +11,43 @@ type ScanReport struct {
 }
 
 // ScanResult is the output for a single torrent scan.
-// All fields are always present (null/empty for missing data, never omitted).
+// The core fields up to Error are always present (null/empty for missing
+// data); optional sections are omitted when they did not run or found nothing.
 type ScanResult struct {
-	InfoHash  string          `json:"info_hash"`
-	Status    string          `json:"status"` // success, stall_metadata, stall_download, no_video, file_not_found, ffprobe_failed, timeout, error
-	File      string          `json:"file"`
-	Audio     []AudioTrack    `json:"audio"`
-	Subtitles []SubtitleTrack `json:"subtitles"`
-	Video     *VideoInfo      `json:"video"`
-	Languages []string        `json:"languages"`
-	ElapsedMs int64           `json:"elapsed_ms"`
-	Error     string          `json:"error"`
+	InfoHash   string          `json:"info_hash"`    // the hash the scan was requested with
+	InfoHashV1 string          `json:"info_hash_v1"` // SHA-1 info hash (v1 and hybrid torrents, else empty)
+	InfoHashV2 string          `json:"info_hash_v2"` // SHA-256 info hash (v2 and hybrid torrents, else empty)
+	Status     string          `json:"status"`       // success, stall_metadata, stall_download, no_video, file_not_found, ffprobe_failed, garbage_data, encrypted, truncated_container, wrong_container, timeout, error
+	File       string          `json:"file"`
+	Audio      []AudioTrack    `json:"audio"`
+	Subtitles  []SubtitleTrack `json:"subtitles"`
+	Video      *VideoInfo      `json:"video"`
+	Languages  []string        `json:"languages"`
+	ElapsedMs  int64           `json:"elapsed_ms"`
+	Error      string          `json:"error"`
 
 	// File listing & threat analysis
 	Files *TorrentFiles `json:"files,omitempty"`
 
 	// Swarm health at time of scan
 	Swarm *SwarmInfo `json:"swarm,omitempty"`
+
+	// CAM/telesync suspicion from sampled frames and audio (needs ffmpeg)
+	SourceQualitySuspect bool     `json:"source_quality_suspect"`
+	SourceQualitySignals []string `json:"source_quality_signals,omitempty"` // letterbox_drift, low_contrast, keystone, audio_noise_floor, mono_audio, low_bitrate_audio
+
+	// Still image or slideshow posing as a video (spam), from frame differences (needs ffmpeg)
+	FakeVideoSuspect bool    `json:"fake_video_suspect"`
+	StaticFrameRatio float64 `json:"static_frame_ratio,omitempty"` // share of sampled frame pairs with no motion
+
+	// Music/audio-only torrents (no video file)
+	AudioRelease *AudioRelease `json:"audio_release,omitempty"`
+
+	// Perceptual hashes of early keyframes, for duplicate detection (needs ffmpeg)
+	Fingerprints []FrameHash `json:"fingerprints,omitempty"`
+
+	// Keyframe contact sheet (only with --thumbnails)
+	ContactSheet *ContactSheet `json:"contact_sheet,omitempty"`
 }
 
 // Normalize ensures slice fields are never nil (always [] in JSON, not null).
@@ -48,17 +68,36 @@ type AudioTrack struct {
 	Lang     string `json:"lang"`
 	Codec    string `json:"codec"`
 	Channels int    `json:"channels"`
+	Bitrate  int    `json:"bitrate,omitempty"` // bits per second, when the container reports it
 	Title    string `json:"title"`
 	Default  bool   `json:"default"`
+	Role     string `json:"role"`           // main, commentary, description, hearing_impaired, karaoke, music
+	External bool   `json:"external"`       // true for sidecar files (e.g. Movie.en.mka)
+	File     string `json:"file,omitempty"` // sidecar path within the torrent (external tracks only)
+
+	SuspectedUpmix      bool    `json:"suspected_upmix,omitempty"`      // 5.1/7.1 whose surround/center channels are silent or copies of the front pair
+	UpmixConfidence     float64 `json:"upmix_confidence,omitempty"`     // 1 when judged over spread windows, 0.5 from the opening only
+	Loudness            float64 `json:"loudness,omitempty"`             // EBU R128 integrated loudness (LUFS) of the downloaded window
+	Silent              bool    `json:"silent,omitempty"`               // dummy track with no audible content
+	DuplicateOf         *int    `json:"duplicate_of,omitempty"`         // index in audio of the track this one copies
+	DuplicateConfidence float64 `json:"duplicate_confidence,omitempty"` // 1 when compared over spread windows, 0.5 over a short head
 }
 
 // SubtitleTrack represents a single subtitle stream extracted by ffprobe.
 type SubtitleTrack struct {
-	Lang    string `json:"lang"`
-	Codec   string `json:"codec"`
-	Title   string `json:"title"`
-	Forced  bool   `json:"forced"`
-	Default bool   `json:"default"`
+	Lang     string  `json:"lang"`
+	Codec    string  `json:"codec"`
+	Title    string  `json:"title"`
+	Forced   bool    `json:"forced"`
+	Default  bool    `json:"default"`
+	IsText   bool    `json:"is_text"`        // text-based (SRT, ASS, WebVTT...)
+	IsBitmap bool    `json:"is_bitmap"`      // image-based (PGS, VobSub, DVB)
+	SDH      bool    `json:"sdh"`            // subtitles for the deaf and hard of hearing
+	CueCount int     `json:"cue_count"`      // cues seen in the downloaded data (approximate)
+	FirstCue float64 `json:"first_cue"`      // seconds, first cue in the downloaded data (0 without cues)
+	LastCue  float64 `json:"last_cue"`       // seconds, last cue in the downloaded data (0 without cues)
+	External bool    `json:"external"`       // true for sidecar files (e.g. Movie.en.srt)
+	File     string  `json:"file,omitempty"` // sidecar path within the torrent (external tracks only)
 }
 
 // VideoInfo represents the primary video stream metadata.
@@ -71,6 +110,95 @@ type VideoInfo struct {
 	FrameRate float64 `json:"frameRate"`          // e.g. 23.976
 	Profile   string  `json:"profile"`            // e.g. "Main 10", "High"
 	Duration  float64 `json:"duration,omitempty"` // seconds
+
+	SampleAspectRatio  string  `json:"sample_aspect_ratio,omitempty"`  // pixel aspect, e.g. "32:27"; omitted for square pixels
+	FieldOrder         string  `json:"field_order,omitempty"`          // ffprobe: progressive, tt, bb, tb, bt
+	ScanType           string  `json:"scan_type,omitempty"`            // progressive, interlaced, telecined
+	ScanTypeConfidence float64 `json:"scan_type_confidence,omitempty"` // 0.5 when only from field_order
+
+	// Frame analysis of the downloaded segment (needs ffmpeg)
+	EstimatedNativeHeight  int     `json:"estimated_native_height,omitempty"`  // production height; < height means upscaled
+	NativeHeightConfidence float64 `json:"native_height_confidence,omitempty"` // 0.0 - 1.0
+	ActiveWidth            int     `json:"active_width,omitempty"`             // picture width without black bars
+	ActiveHeight           int     `json:"active_height,omitempty"`            // picture height without black bars
+	DisplayAspectRatio     float64 `json:"display_aspect_ratio,omitempty"`     // of the active picture, e.g. 2.39
+}
+
+// AudioRelease summarizes a music/audio-only torrent.
+type AudioRelease struct {
+	Summary    string           `json:"summary"` // e.g. "FLAC 24/96", "MP3 320", "MP3 VBR ~245", "Mixed"
+	Artist     string           `json:"artist,omitempty"`
+	Album      string           `json:"album,omitempty"`
+	Codec      string           `json:"codec,omitempty"` // empty when tracks use different codecs
+	SampleRate int              `json:"sample_rate,omitempty"`
+	BitDepth   int              `json:"bit_depth,omitempty"`
+	Lossless   bool             `json:"lossless"`
+	TrackCount int              `json:"track_count"`        // audio files in the torrent
+	Duration   float64          `json:"duration,omitempty"` // seconds, sum of probed tracks
+	Tracks     []AudioFileTrack `json:"tracks"`             // probed tracks (capped)
+	CueSheets  []CueSheet       `json:"cue_sheets"`
+	RipLogs    []RipLog         `json:"rip_logs"`
+
+	// Spectral analysis of lossless releases (needs ffmpeg)
+	SuspectedTranscode     bool `json:"suspected_transcode"`                // most analyzed tracks look transcoded from lossy
+	EstimatedSourceBitrate int  `json:"estimated_source_bitrate,omitempty"` // bits/s, median of suspected tracks
+}
+
+// AudioFileTrack represents a single audio file of an audio-only torrent.
+type AudioFileTrack struct {
+	Path       string            `json:"path"`
+	Size       int64             `json:"size"`
+	Codec      string            `json:"codec"`
+	SampleRate int               `json:"sample_rate"`
+	BitDepth   int               `json:"bit_depth,omitempty"` // lossless/PCM only
+	Bitrate    int               `json:"bitrate"`             // bits per second
+	Channels   int               `json:"channels"`
+	Lossless   bool              `json:"lossless"`
+	Duration   float64           `json:"duration,omitempty"` // seconds
+	Tags       map[string]string `json:"tags"`               // artist, album_artist, album, title, track, disc, date, genre
+
+	// Spectral analysis (lossless tracks only, needs ffmpeg)
+	SpectralCutoff         int  `json:"spectral_cutoff,omitempty"`          // Hz, highest frequency with content
+	SuspectedTranscode     bool `json:"suspected_transcode"`                // lowpass cliff typical of a lossy encoder
+	EstimatedSourceBitrate int  `json:"estimated_source_bitrate,omitempty"` // bits/s of the suspected lossy source
+}
+
+// CueSheet is the parsed content of a .cue file.
+type CueSheet struct {
+	Path         string   `json:"path"`
+	Performer    string   `json:"performer,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Files        []string `json:"files"`         // FILE entries
+	Tracks       int      `json:"tracks"`        // TRACK entries
+	MissingFiles []string `json:"missing_files"` // FILE entries not present in the torrent
+}
+
+// RipLog is the parsed verification summary of a CD ripper .log file.
+type RipLog struct {
+	Path            string `json:"path"`
+	Ripper          string `json:"ripper"`           // EAC, XLD, whipper, CUERipper, dBpoweramp, unknown
+	Tracks          int    `json:"tracks"`           // tracks listed in the log
+	AccurateRip     int    `json:"accurate_rip"`     // tracks verified by AccurateRip
+	CRCMismatches   int    `json:"crc_mismatches"`   // tracks where test and copy CRC differ
+	ReadErrors      int    `json:"read_errors"`      // read errors / suspicious positions
+	ChecksumPresent bool   `json:"checksum_present"` // log carries a signature/checksum
+	Status          string `json:"status"`           // ok, errors, unverified
+}
+
+// FrameHash holds the perceptual hashes of one keyframe as 16-digit hex.
+type FrameHash struct {
+	PHash string `json:"phash"` // DCT hash: robust to re-encoding and scaling
+	DHash string `json:"dhash"` // gradient hash: robust to brightness changes
+}
+
+// ContactSheet is a tiled image of keyframes from the downloaded data.
+// Exactly one of Path and Data is set.
+type ContactSheet struct {
+	Path   string `json:"path,omitempty"` // image file written next to the report
+	Data   string `json:"data,omitempty"` // base64 image (pipe mode)
+	Format string `json:"format"`         // jpg, webp
+	MIME   string `json:"mime"`
+	Grid   string `json:"grid"` // columns x rows, e.g. "3x3"; trailing tiles are blank when fewer keyframes were downloaded
 }
 
 // TorrentFiles contains the complete file listing of a torrent with threat analysis.
@@ -101,6 +229,7 @@ type SwarmInfo struct {
 	ActivePeers        int   `json:"active_peers"`
 	TotalPeers         int   `json:"total_peers"`
 	Seeds              int   `json:"seeds"`
-	DownloadBytesTotal int64 `json:"download_bytes_total"` // cumulative bytes downloaded
-	UploadBytesTotal   int64 `json:"upload_bytes_total"`   // cumulative bytes uploaded
+	DownloadBytesTotal int64 `json:"download_bytes_total"`           // cumulative bytes downloaded
+	UploadBytesTotal   int64 `json:"upload_bytes_total"`             // cumulative bytes uploaded
+	ClientBlockedPeers int   `json:"client_blocked_peers,omitempty"` // distinct peer IPs the client's blocklist refused during this scan, for any torrent
 }
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"time"
+)
+
+const (
+	upmixWindows    = 3     // windows decoded per multichannel track, spread over the readable span
+	upmixWindowSec  = 8     // seconds per window
+	upmixMinRange   = 60.0  // seconds of readable audio needed to spread the windows
+	upmixMinWindows = 2     // windows that must look upmixed for a suspect verdict
+	upmixRate       = 16000 // sample rate the channels are decoded at
+	upmixMinSeconds = 5     // decoded audio needed for a verdict on a window
+	upmixSilentDB   = 40.0  // channel this far below the front pair counts as silent
+	upmixFloorDB    = -80.0 // channel below this level counts as silent
+	upmixFrontMinDB = -50.0 // front pair must be at least this loud for a verdict
+	upmixCopyCorr   = 0.98  // correlation with a front channel that counts as a copy
+	upmixHeadConf   = 0.5   // confidence of a verdict from back-to-back windows at the start
+)
+
+// decodeChannels decodes up to seconds of an audio stream (the streamIndex-th
+// audio stream), from start seconds into the file, to planar float PCM at
+// sampleRate, one slice per channel in ffmpeg's channel order (FL FR FC LFE
+// BL BR [SL SR] for 5.1/7.1). As with decodePCM, input is read up to span
+// seconds (0 = no bound) and whatever was decoded before the partial data
+// ran out is returned.
+func decodeChannels(ctx context.Context, ffmpegPath, filePath string, span, start float64, streamIndex, channels, seconds, sampleRate int) ([][]float64, error) {
+	if channels <= 0 {
+		return nil, fmt.Errorf("invalid channel count %d", channels)
+	}
+	decCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	args := append([]string{"-v", "quiet"}, seekInputArgs(filePath, span, start)...)
+	args = append(args,
+		"-map", fmt.Sprintf("0:a:%d", streamIndex),
+		"-t", fmt.Sprint(seconds),
+		"-ac", fmt.Sprint(channels),
+		"-ar", fmt.Sprint(sampleRate),
+		"-f", "f32le", "-acodec", "pcm_f32le",
+		"-",
+	)
+	cmd := exec.CommandContext(decCtx, ffmpegPath, args...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	runErr := cmd.Run()
+
+	data := stdout.Bytes()
+	frames := len(data) / (4 * channels)
+	if frames == 0 {
+		if runErr != nil {
+			return nil, fmt.Errorf("ffmpeg decode failed: %w", runErr)
+		}
+		return nil, fmt.Errorf("no audio decoded")
+	}
+
+	out := make([][]float64, channels)
+	for c := range out {
+		out[c] = make([]float64, frames)
+	}
+	for i := 0; i < frames; i++ {
+		for c := 0; c < channels; c++ {
+			off := 4 * (i*channels + c)
+			out[c][i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[off:])))
+		}
+	}
+	return out, nil
+}
+
+// levelDB returns the RMS level of samples in dBFS.
+func levelDB(samples []float64) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s * s
+	}
+	return 10 * math.Log10(sum/float64(max(1, len(samples)))+1e-20)
+}
+
+// correlation returns the Pearson correlation of two equally long signals
+// (0 when either is constant).
+func correlation(a, b []float64) float64 {
+	n := min(len(a), len(b))
+	if n == 0 {
+		return 0
+	}
+	var sa, sb float64
+	for i := 0; i < n; i++ {
+		sa += a[i]
+		sb += b[i]
+	}
+	ma, mb := sa/float64(n), sb/float64(n)
+	var cov, va, vb float64
+	for i := 0; i < n; i++ {
+		da, db := a[i]-ma, b[i]-mb
+		cov += da * db
+		va += da * da
+		vb += db * db
+	}
+	if va == 0 || vb == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(va*vb)
+}
+
+// detectUpmix checks whether a multichannel track is stereo dressed up as
+// surround: every channel other than the front pair and LFE is either silent
+// or a copy of the left, right or summed (L+R) front signal. Only 5.1 and
+// 7.1 layouts (6 and 8 channels) are judged. Returns false when there is not
+// enough audio or the front pair is too quiet for a verdict.
+func detectUpmix(channels [][]float64, sampleRate int) (suspect, ok bool) {
+	if len(channels) != 6 && len(channels) != 8 {
+		return false, false
+	}
+	if len(channels[0]) < upmixMinSeconds*sampleRate {
+		return false, false
+	}
+	fl, fr := channels[0], channels[1]
+	front := max(levelDB(fl), levelDB(fr))
+	if front < upmixFrontMinDB {
+		return false, false
+	}
+	mid := make([]float64, len(fl))
+	for i := range mid {
+		mid[i] = (fl[i] + fr[i]) / 2
+	}
+
+	for c := 2; c < len(channels); c++ {
+		if c == 3 {
+			continue // LFE carries only bass, even in genuine mixes it may be silent
+		}
+		ch := channels[c]
+		level := levelDB(ch)
+		if level < upmixFloorDB || level < front-upmixSilentDB {
+			continue
+		}
+		if correlation(ch, fl) >= upmixCopyCorr || correlation(ch, fr) >= upmixCopyCorr || correlation(ch, mid) >= upmixCopyCorr {
+			continue
+		}
+		return false, true // a channel with its own content: genuine surround
+	}
+	return true, true
+}
+
+// upmixVerdict combines the detectUpmix verdicts of several windows of a
+// track. Openings are often mono logos or silence, so one window with
+// surround content of its own clears the track, and a suspect verdict needs
+// upmixMinWindows windows loud enough to judge.
+func upmixVerdict(windows [][][]float64, sampleRate int) (suspect, ok bool) {
+	judged := 0
+	for _, channels := range windows {
+		suspect, ok := detectUpmix(channels, sampleRate)
+		if !ok {
+			continue
+		}
+		if !suspect {
+			return false, true
+		}
+		judged++
+	}
+	return judged >= upmixMinWindows, judged >= upmixMinWindows
+}
+
+// upmixStarts returns the start times of the windows judged within the
+// first limit seconds, and the confidence of a verdict drawn from them.
+// Windows are spread over the readable audio when there is enough of it.
+// A shorter head is judged with back-to-back windows from the start, which
+// may only cover a stereo opening, so the verdict gets upmixHeadConf. Nil
+// when not even upmixMinWindows windows fit.
+func upmixStarts(limit float64) ([]float64, float64) {
+	if starts := spreadWindows(limit, upmixMinRange, upmixWindows, upmixWindowSec); starts != nil {
+		return starts, 1
+	}
+	n := min(upmixWindows, int(limit/upmixWindowSec))
+	if n < upmixMinWindows {
+		return nil, 0
+	}
+	starts := make([]float64, n)
+	for k := range starts {
+		starts[k] = float64(k * upmixWindowSec)
+	}
+	return starts, upmixHeadConf
+}
+
+// ApplyUpmixDetection decodes a few windows spread over each embedded
+// 5.1/7.1 audio track and flags suspected_upmix on those whose surround and
+// center channels are silent or copies of the front pair. The windows lie
+// within span seconds, the gap-free head of the download (0 = the whole
+// file); when that is too short to spread them the head is judged with
+// lower upmix_confidence (see upmixStarts). Needs ffmpeg. Modifies the
+// result in-place.
+func ApplyUpmixDetection(ctx context.Context, ffmpegPath string, result *ScanResult, filePath string, span float64) {
+	if result == nil || ffmpegPath == "" {
+		return
+	}
+	limit := analysisLimit(result, span)
+	starts, confidence := upmixStarts(limit)
+	for i := range result.Audio {
+		t := &result.Audio[i]
+		if t.External || (t.Channels != 6 && t.Channels != 8) {
+			continue
+		}
+		if starts == nil {
+			log.Printf("  [%s] upmix check skipped for audio track %d: %.0fs of readable audio", TruncHash(result.InfoHash), i, limit)
+			continue
+		}
+		var windows [][][]float64
+		for _, start := range starts {
+			channels, err := decodeChannels(ctx, ffmpegPath, filePath, span, start, i, t.Channels, upmixWindowSec, upmixRate)
+			if err != nil {
+				log.Printf("  [%s] upmix window at %.0fs skipped for audio track %d: %v", TruncHash(result.InfoHash), start, i, err)
+				continue
+			}
+			windows = append(windows, channels)
+		}
+		if suspect, ok := upmixVerdict(windows, upmixRate); ok {
+			t.SuspectedUpmix = suspect
+			t.UpmixConfidence = confidence
+			log.Printf("  [%s] audio track %d (%dch): suspected upmix=%v (confidence %.1f)", TruncHash(result.InfoHash), i, t.Channels, suspect, confidence)
+		}
+	}
+}
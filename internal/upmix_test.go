@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noiseSignal returns n samples of random noise at the given amplitude.
+func noiseSignal(rng *rand.Rand, n int, amp float64) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = amp * (rng.Float64()*2 - 1)
+	}
+	return s
+}
+
+func scaled(s []float64, gain float64) []float64 {
+	out := make([]float64, len(s))
+	for i, v := range s {
+		out[i] = v * gain
+	}
+	return out
+}
+
+func TestDetectUpmix(t *testing.T) {
+	const rate = 8000
+	n := 10 * rate
+	rng := rand.New(rand.NewSource(1))
+	fl, fr := noiseSignal(rng, n, 0.3), noiseSignal(rng, n, 0.3)
+	mid := make([]float64, n)
+	for i := range mid {
+		mid[i] = (fl[i] + fr[i]) / 2
+	}
+	silent := make([]float64, n)
+	bass := make([]float64, n)
+	for i := range bass {
+		bass[i] = 0.2 * math.Sin(2*math.Pi*50*float64(i)/rate)
+	}
+
+	tests := []struct {
+		name     string
+		channels [][]float64
+		suspect  bool
+		ok       bool
+	}{
+		{"genuine 5.1", [][]float64{fl, fr, noiseSignal(rng, n, 0.3), bass, noiseSignal(rng, n, 0.1), noiseSignal(rng, n, 0.1)}, false, true},
+		{"stereo copied to surrounds", [][]float64{fl, fr, mid, bass, scaled(fl, 0.7), scaled(fr, 0.7)}, true, true},
+		{"stereo with silent channels", [][]float64{fl, fr, silent, silent, silent, silent}, true, true},
+		{"7.1 with one real surround", [][]float64{fl, fr, mid, bass, fl, fr, noiseSignal(rng, n, 0.1), fr}, false, true},
+		{"silent track", [][]float64{silent, silent, silent, silent, silent, silent}, false, false},
+		{"too short", [][]float64{fl[:rate], fr[:rate], mid[:rate], bass[:rate], fl[:rate], fr[:rate]}, false, false},
+		{"stereo", [][]float64{fl, fr}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suspect, ok := detectUpmix(tt.channels, rate)
+			if suspect != tt.suspect || ok != tt.ok {
+				t.Errorf("detectUpmix = (%v, %v), want (%v, %v)", suspect, ok, tt.suspect, tt.ok)
+			}
+		})
+	}
+}
+
+func TestUpmixVerdict(t *testing.T) {
+	const rate = 8000
+	n := 8 * rate
+	rng := rand.New(rand.NewSource(3))
+	silent := make([]float64, n)
+	window := func(surround bool) [][]float64 {
+		fl, fr := noiseSignal(rng, n, 0.3), noiseSignal(rng, n, 0.3)
+		if surround {
+			return [][]float64{fl, fr, noiseSignal(rng, n, 0.3), silent, noiseSignal(rng, n, 0.1), noiseSignal(rng, n, 0.1)}
+		}
+		return [][]float64{fl, fr, silent, silent, scaled(fl, 0.7), scaled(fr, 0.7)}
+	}
+	quiet := [][]float64{silent, silent, silent, silent, silent, silent}
+
+	tests := []struct {
+		name    string
+		windows [][][]float64
+		suspect bool
+		ok      bool
+	}{
+		// A mono/stereo studio logo in the opening must not condemn a real 5.1 mix.
+		{"upmixed opening, surround body", [][][]float64{window(false), window(true), window(true)}, false, true},
+		{"upmixed throughout", [][][]float64{window(false), window(false), window(false)}, true, true},
+		{"one window judged", [][][]float64{quiet, window(false), quiet}, false, false},
+		{"two windows judged", [][][]float64{quiet, window(false), window(false)}, true, true},
+		{"no windows", nil, false, false},
+	}
+	for _, tt := range tests {
+		if suspect, ok := upmixVerdict(tt.windows, rate); suspect != tt.suspect || ok != tt.ok {
+			t.Errorf("%s: upmixVerdict = (%v, %v), want (%v, %v)", tt.name, suspect, ok, tt.suspect, tt.ok)
+		}
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	a := noiseSignal(rng, 1000, 1)
+	if c := correlation(a, scaled(a, -0.5)); math.Abs(c+1) > 1e-9 {
+		t.Errorf("expected -1 for inverted copy, got %f", c)
+	}
+	if c := correlation(a, noiseSignal(rng, 1000, 1)); math.Abs(c) > 0.1 {
+		t.Errorf("expected ~0 for independent noise, got %f", c)
+	}
+	if c := correlation(a, make([]float64, 1000)); c != 0 {
+		t.Errorf("expected 0 for constant signal, got %f", c)
+	}
+}
+
+func TestUpmixStarts(t *testing.T) {
+	starts, conf := upmixStarts(600)
+	if len(starts) != upmixWindows || conf != 1 || starts[0] < 60 {
+		t.Errorf("long head: got %v (confidence %v), want spread windows", starts, conf)
+	}
+
+	// Too short to spread: back-to-back windows from the start, lower confidence
+	starts, conf = upmixStarts(30)
+	if len(starts) != upmixWindows || conf != upmixHeadConf || starts[0] != 0 || starts[len(starts)-1]+upmixWindowSec > 30 {
+		t.Errorf("30s head: got %v (confidence %v)", starts, conf)
+	}
+
+	if starts, _ := upmixStarts(upmixWindowSec * (upmixMinWindows - 1)); starts != nil {
+		t.Errorf("head shorter than %d windows should not be judged, got %v", upmixMinWindows, starts)
+	}
+	if starts, _ := upmixStarts(0); starts != nil {
+		t.Errorf("unknown limit should not be judged, got %v", starts)
+	}
+}
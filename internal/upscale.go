@@ -0,0 +1,129 @@
+package internal
+
+import "math"
+
+// nativeHeights are the production resolutions an upscale may come from.
+var nativeHeights = []int{480, 576, 720, 1080, 1440}
+
+const (
+	upscaleColumns     = 64 // columns sampled per frame
+	upscaleMaxFFT      = 2048
+	upscaleMinStddev   = 8.0  // frames flatter than this (black, fades) are skipped
+	upscaleExcessDB    = 8.0  // extra drop at a candidate's Nyquist that indicates an upscale
+	upscaleConfidentDB = 20.0 // excess drop treated as a certain upscale
+)
+
+// columnSpectrum returns the mean vertical power spectrum (dB) of sampled
+// columns over all non-flat frames, and the FFT size used.
+func columnSpectrum(frames []grayFrame) ([]float64, int) {
+	if len(frames) == 0 {
+		return nil, 0
+	}
+	n := 1
+	for n*2 <= frames[0].height && n*2 <= upscaleMaxFFT {
+		n *= 2
+	}
+	if n < 256 {
+		return nil, 0
+	}
+
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+	power := make([]float64, n/2)
+	buf := make([]complex128, n)
+	count := 0
+
+	for _, f := range frames {
+		if f.stddev() < upscaleMinStddev {
+			continue
+		}
+		y0 := (f.height - n) / 2
+		margin := f.width / 20
+		for c := 0; c < upscaleColumns; c++ {
+			x := margin + c*(f.width-2*margin-1)/(upscaleColumns-1)
+			var mean float64
+			for i := 0; i < n; i++ {
+				mean += f.at(x, y0+i)
+			}
+			mean /= float64(n)
+			for i := 0; i < n; i++ {
+				buf[i] = complex((f.at(x, y0+i)-mean)*window[i], 0)
+			}
+			fft(buf)
+			for k := range power {
+				power[k] += real(buf[k])*real(buf[k]) + imag(buf[k])*imag(buf[k])
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		return nil, 0
+	}
+
+	db := make([]float64, len(power))
+	for k, p := range power {
+		db[k] = 10 * math.Log10(p/float64(count)+1e-12)
+	}
+	return db, n
+}
+
+// bandMean returns the mean of spectrum values between two fractions of bin kc.
+func bandMean(db []float64, kc, from, to float64) float64 {
+	lo := max(1, int(kc*from))
+	hi := min(len(db), int(math.Ceil(kc*to)))
+	if hi <= lo {
+		return db[min(lo, len(db)-1)]
+	}
+	var sum float64
+	for _, v := range db[lo:hi] {
+		sum += v
+	}
+	return sum / float64(hi-lo)
+}
+
+// estimateNativeHeight estimates the resolution a picture was produced at.
+// Upscaling cannot create detail above the source's Nyquist frequency, so
+// the vertical spectrum of an upscale falls to the noise floor across the
+// resize filter's transition band around height/codedHeight of the coded
+// Nyquist. For each candidate native height, the drop from just below that
+// frequency to the stopband above it is compared with the drop across the
+// band below (the content's natural slope). The candidate with the largest
+// excess drop wins if it is significant. Returns the coded height when no
+// upscale is found, and false when the frames are unusable.
+func estimateNativeHeight(frames []grayFrame) (int, float64, bool) {
+	db, n := columnSpectrum(frames)
+	if db == nil {
+		return 0, 0, false
+	}
+	coded := frames[0].height
+
+	best, bestExcess := coded, math.Inf(-1)
+	for _, h := range nativeHeights {
+		if float64(h) >= float64(coded)*0.9 {
+			continue
+		}
+		kc := float64(n/2) * float64(h) / float64(coded) // bin of the candidate's Nyquist
+		if kc*1.3 >= float64(len(db)) {
+			continue
+		}
+		pass := bandMean(db, kc, 0.7, 0.9)
+		natural := bandMean(db, kc, 0.45, 0.7) - pass
+		excess := pass - bandMean(db, kc, 1.3, 1.6) - max(0, natural)
+		if excess > bestExcess {
+			best, bestExcess = h, excess
+		}
+	}
+
+	if bestExcess >= upscaleExcessDB {
+		confidence := 0.5 + 0.5*min(1, (bestExcess-upscaleExcessDB)/(upscaleConfidentDB-upscaleExcessDB))
+		return best, math.Round(min(confidence, 0.99)*100) / 100, true
+	}
+	// No upscale signature: native, more confidently the flatter the spectrum
+	confidence := 0.9
+	if !math.IsInf(bestExcess, -1) {
+		confidence = min(0.9, max(0.5, 0.9-0.4*bestExcess/upscaleExcessDB))
+	}
+	return coded, math.Round(confidence*100) / 100, true
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noiseFrame builds a width×height frame of white noise produced at
+// nativeHeight rows and upscaled to height with a Lanczos-3 filter, like a
+// typical encoder-side resize.
+func noiseFrame(rng *rand.Rand, width, height, nativeHeight int) grayFrame {
+	native := make([]float64, width*nativeHeight)
+	for i := range native {
+		native[i] = 128 + 40*rng.NormFloat64()
+	}
+	lanczos := func(x float64) float64 {
+		if x == 0 {
+			return 1
+		}
+		if math.Abs(x) >= 3 {
+			return 0
+		}
+		px := math.Pi * x
+		return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+	}
+
+	pix := make([]byte, width*height)
+	for y := 0; y < height; y++ {
+		src := (float64(y)+0.5)*float64(nativeHeight)/float64(height) - 0.5
+		base := int(math.Floor(src))
+		for x := 0; x < width; x++ {
+			var v, wsum float64
+			for k := base - 2; k <= base+3; k++ {
+				w := lanczos(src - float64(k))
+				v += w * native[max(0, min(nativeHeight-1, k))*width+x]
+				wsum += w
+			}
+			pix[y*width+x] = byte(max(0, min(255, v/wsum)))
+		}
+	}
+	return grayFrame{width: width, height: height, pix: pix}
+}
+
+func TestEstimateNativeHeight_Upscaled(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	frames := []grayFrame{noiseFrame(rng, 256, 1080, 720), noiseFrame(rng, 256, 1080, 720)}
+
+	height, confidence, ok := estimateNativeHeight(frames)
+	if !ok {
+		t.Fatal("expected a verdict")
+	}
+	if height != 720 {
+		t.Errorf("expected native height 720, got %d (confidence %.2f)", height, confidence)
+	}
+	if confidence < 0.5 {
+		t.Errorf("expected confidence >= 0.5, got %.2f", confidence)
+	}
+}
+
+func TestEstimateNativeHeight_Fake4K(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	height, _, ok := estimateNativeHeight([]grayFrame{noiseFrame(rng, 128, 2160, 1080)})
+	if !ok || height != 1080 {
+		t.Errorf("expected 1080p source in 2160p frame, got %d (ok=%v)", height, ok)
+	}
+}
+
+func TestEstimateNativeHeight_Native(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	frames := []grayFrame{noiseFrame(rng, 256, 1080, 1080), noiseFrame(rng, 256, 1080, 1080)}
+
+	height, _, ok := estimateNativeHeight(frames)
+	if !ok {
+		t.Fatal("expected a verdict")
+	}
+	if height != 1080 {
+		t.Errorf("expected native 1080, got %d", height)
+	}
+}
+
+func TestEstimateNativeHeight_FlatFrames(t *testing.T) {
+	flat := grayFrame{width: 256, height: 1080, pix: make([]byte, 256*1080)}
+	if _, _, ok := estimateNativeHeight([]grayFrame{flat}); ok {
+		t.Error("expected no verdict for black frames")
+	}
+}
@@ -3,8 +3,10 @@ package internal
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -36,6 +38,31 @@ type UserConfig struct {
 	StallTimeout int `json:"stall_timeout"` // seconds before killing stalled torrent
 	MaxTimeout   int `json:"max_timeout"`   // absolute max seconds per torrent
 
+	// Resource limits (0 = unlimited)
+	DownloadRateKB int `json:"download_rate_kb,omitempty"` // global download rate in KB/s
+	UploadRateKB   int `json:"upload_rate_kb,omitempty"`   // global upload rate in KB/s
+	MaxConns       int `json:"max_conns,omitempty"`        // established peer connections per worker
+	MaxHalfOpen    int `json:"max_half_open,omitempty"`    // half-open peer connections per worker
+	DiskBudgetMB   int `json:"disk_budget_mb,omitempty"`   // temp-disk space shared by all workers
+
+	// Network
+	Proxy         string `json:"proxy,omitempty"`          // socks5://, http:// or https:// proxy URL
+	ProxyFallback bool   `json:"proxy_fallback,omitempty"` // scan without the proxy when it is unreachable
+	BindAddress   string `json:"bind_address,omitempty"`   // IP or interface name to listen and dial from
+	ListenPorts   string `json:"listen_ports,omitempty"`   // listen port or range, e.g. "6881-6889"
+	DisableIPv6   bool   `json:"disable_ipv6,omitempty"`
+	DisableUTP    bool   `json:"disable_utp,omitempty"`
+	DisableTCP    bool   `json:"disable_tcp,omitempty"`
+	Encryption    string `json:"encryption,omitempty"` // prefer, require or off
+
+	// Peer discovery
+	Trackers        []string `json:"trackers,omitempty"`         // extra announce URLs
+	TrackersFile    string   `json:"trackers_file,omitempty"`    // file with one announce URL per line
+	ReplaceTrackers bool     `json:"replace_trackers,omitempty"` // announce only to the trackers above
+	DHTNodes        []string `json:"dht_nodes,omitempty"`        // DHT bootstrap nodes (host:port)
+	DHTStateFile    string   `json:"dht_state_file,omitempty"`   // persisted DHT routing table
+	Blocklist       string   `json:"blocklist,omitempty"`        // P2P or DAT peer blocklist, optionally gzipped
+
 	// Output
 	VerboseLevel int `json:"verbose_level"` // 0=normal (progress+logfile), 1=verbose (all to stderr)
 
@@ -130,6 +157,7 @@ func (c *UserConfig) Save() error {
 
 // ApplyToConfig merges user config into a runtime Config.
 // Only applies if the user has run `truespec config` at least once.
+// Invalid listen ports are skipped with a warning.
 func (c *UserConfig) ApplyToConfig(cfg *Config) {
 	if !c.Configured {
 		return
@@ -148,6 +176,65 @@ func (c *UserConfig) ApplyToConfig(cfg *Config) {
 	if c.MaxTimeout > 0 {
 		cfg.MaxTimeout = time.Duration(c.MaxTimeout) * time.Second
 	}
+	if c.DownloadRateKB > 0 {
+		cfg.DownloadRateKB = c.DownloadRateKB
+	}
+	if c.UploadRateKB > 0 {
+		cfg.UploadRateKB = c.UploadRateKB
+	}
+	if c.MaxConns > 0 {
+		cfg.MaxConns = c.MaxConns
+	}
+	if c.MaxHalfOpen > 0 {
+		cfg.MaxHalfOpen = c.MaxHalfOpen
+	}
+	if c.DiskBudgetMB > 0 {
+		cfg.DiskBudgetMB = c.DiskBudgetMB
+	}
+	if c.Proxy != "" {
+		cfg.Proxy = c.Proxy
+	}
+	if c.ProxyFallback {
+		cfg.ProxyFallback = true
+	}
+	if c.BindAddress != "" {
+		cfg.BindAddress = c.BindAddress
+	}
+	if lo, hi, err := ParsePortRange(c.ListenPorts); err != nil {
+		log.Printf("Warning: ignoring listen_ports in %s: %v", UserConfigPath(), err)
+	} else if lo > 0 {
+		cfg.PortMin, cfg.PortMax = lo, hi
+	}
+	if c.DisableIPv6 {
+		cfg.DisableIPv6 = true
+	}
+	if c.DisableUTP {
+		cfg.DisableUTP = true
+	}
+	if c.DisableTCP {
+		cfg.DisableTCP = true
+	}
+	if c.Encryption != "" {
+		cfg.Encryption = c.Encryption
+	}
+	if len(c.Trackers) > 0 {
+		cfg.ExtraTrackers = c.Trackers
+	}
+	if c.TrackersFile != "" {
+		cfg.TrackersFile = c.TrackersFile
+	}
+	if c.ReplaceTrackers {
+		cfg.ReplaceTrackers = true
+	}
+	if len(c.DHTNodes) > 0 {
+		cfg.DHTNodes = c.DHTNodes
+	}
+	if c.DHTStateFile != "" {
+		cfg.DHTStateFile = c.DHTStateFile
+	}
+	if c.Blocklist != "" {
+		cfg.Blocklist = c.Blocklist
+	}
 
 	cfg.VerboseLevel = c.VerboseLevel
 }
@@ -180,6 +267,43 @@ func (c *UserConfig) ShowConfig() string {
 	s += fmt.Sprintf("\n  Concurrency:          %d\n", c.Concurrency)
 	s += fmt.Sprintf("  Stall timeout:        %ds\n", c.StallTimeout)
 	s += fmt.Sprintf("  Max timeout:          %ds\n", c.MaxTimeout)
+	s += fmt.Sprintf("  Download rate:        %s\n", limitOrUnlimited(c.DownloadRateKB, "KB/s"))
+	s += fmt.Sprintf("  Upload rate:          %s\n", limitOrUnlimited(c.UploadRateKB, "KB/s"))
+	s += fmt.Sprintf("  Peer connections:     %s\n", limitOrUnlimited(c.MaxConns, "per worker"))
+	s += fmt.Sprintf("  Half-open conns:      %s\n", limitOrUnlimited(c.MaxHalfOpen, "per worker"))
+	s += fmt.Sprintf("  Temp disk budget:     %s\n", limitOrUnlimited(c.DiskBudgetMB, "MB"))
+	if c.Proxy != "" {
+		s += fmt.Sprintf("  Proxy:                %s\n", RedactProxy(c.Proxy))
+		s += fmt.Sprintf("    Direct fallback:    %s\n", yn(c.ProxyFallback))
+	}
+	if c.BindAddress != "" {
+		s += fmt.Sprintf("  Bind address:         %s\n", c.BindAddress)
+	}
+	if c.ListenPorts != "" {
+		s += fmt.Sprintf("  Listen ports:         %s\n", c.ListenPorts)
+	}
+	if c.DisableIPv6 || c.DisableUTP || c.DisableTCP {
+		s += fmt.Sprintf("  IPv6/uTP/TCP:         %s/%s/%s\n", yn(!c.DisableIPv6), yn(!c.DisableUTP), yn(!c.DisableTCP))
+	}
+	if c.Encryption != "" {
+		s += fmt.Sprintf("  Encryption:           %s\n", c.Encryption)
+	}
+	if len(c.Trackers) > 0 || c.TrackersFile != "" {
+		mode := "added to defaults"
+		if c.ReplaceTrackers {
+			mode = "replacing defaults"
+		}
+		s += fmt.Sprintf("  Extra trackers:       %d, file %s (%s)\n", len(c.Trackers), valueOrNA(c.TrackersFile), mode)
+	}
+	if len(c.DHTNodes) > 0 {
+		s += fmt.Sprintf("  DHT bootstrap nodes:  %s\n", strings.Join(c.DHTNodes, ", "))
+	}
+	if c.DHTStateFile != "" {
+		s += fmt.Sprintf("  DHT state file:       %s\n", c.DHTStateFile)
+	}
+	if c.Blocklist != "" {
+		s += fmt.Sprintf("  Peer blocklist:       %s\n", c.Blocklist)
+	}
 	s += fmt.Sprintf("\n  Output mode:          %s\n", VerboseLevelLabel(c.VerboseLevel))
 	if c.VerboseLevel == VerboseNormal {
 		s += fmt.Sprintf("  Log directory:        %s\n", LogDirPath())
@@ -210,3 +334,11 @@ func valueOrNA(s string) string {
 }
 
 // atomicRename is defined in fileutil.go (shared between stats.go and userconfig.go)
+
+// limitOrUnlimited formats a resource limit, where 0 means no limit.
+func limitOrUnlimited(v int, unit string) string {
+	if v <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d %s", v, unit)
+}
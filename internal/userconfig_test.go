@@ -2,6 +2,7 @@ package internal
 
 import (
 	"encoding/json"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -104,6 +105,56 @@ func TestApplyToConfig_Configured(t *testing.T) {
 	}
 }
 
+func TestApplyToConfig_ResourceLimits(t *testing.T) {
+	ucfg := DefaultUserConfig()
+	ucfg.Configured = true
+	ucfg.DownloadRateKB = 2048
+	ucfg.UploadRateKB = 256
+	ucfg.MaxConns = 30
+	ucfg.MaxHalfOpen = 10
+	ucfg.DiskBudgetMB = 500
+
+	cfg := DefaultConfig()
+	ucfg.ApplyToConfig(&cfg)
+
+	if cfg.DownloadRateKB != 2048 || cfg.UploadRateKB != 256 {
+		t.Errorf("expected rates 2048/256, got %d/%d", cfg.DownloadRateKB, cfg.UploadRateKB)
+	}
+	if cfg.MaxConns != 30 || cfg.MaxHalfOpen != 10 {
+		t.Errorf("expected conns 30/10, got %d/%d", cfg.MaxConns, cfg.MaxHalfOpen)
+	}
+	if cfg.DiskBudgetMB != 500 {
+		t.Errorf("expected disk budget 500, got %d", cfg.DiskBudgetMB)
+	}
+}
+
+func TestApplyToConfig_InvalidListenPorts(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	ucfg := DefaultUserConfig()
+	ucfg.Configured = true
+	ucfg.ListenPorts = "6889-6881"
+
+	cfg := DefaultConfig()
+	cfg.PortMin, cfg.PortMax = 0, 0
+	ucfg.ApplyToConfig(&cfg)
+
+	if cfg.PortMin != 0 || cfg.PortMax != 0 {
+		t.Errorf("invalid listen_ports should not apply, got %d-%d", cfg.PortMin, cfg.PortMax)
+	}
+	if !strings.Contains(logs.String(), "listen_ports") {
+		t.Errorf("expected a warning about listen_ports, got %q", logs.String())
+	}
+
+	ucfg.ListenPorts = "6881-6889"
+	ucfg.ApplyToConfig(&cfg)
+	if cfg.PortMin != 6881 || cfg.PortMax != 6889 {
+		t.Errorf("expected ports 6881-6889, got %d-%d", cfg.PortMin, cfg.PortMax)
+	}
+}
+
 func TestApplyToConfig_NotConfigured(t *testing.T) {
 	ucfg := DefaultUserConfig()
 	ucfg.Configured = false
@@ -7,7 +7,6 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"path/filepath"
 	"sync"
 	"time"
@@ -26,6 +25,7 @@ const (
 // VTClient is a VirusTotal API v3 client with rate limiting.
 type VTClient struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
 	mu         sync.Mutex
 	lastReq    time.Time
@@ -92,10 +92,9 @@ type vtAnalysisResponse struct {
 // NewVTClient creates a new VirusTotal client with the given API key.
 func NewVTClient(apiKey string) *VTClient {
 	return &VTClient{
-		apiKey: apiKey,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		apiKey:     apiKey,
+		baseURL:    vtBaseURL,
+		httpClient: newOutboundClient(30 * time.Second),
 	}
 }
 
@@ -116,7 +115,7 @@ func (c *VTClient) rateLimit() {
 func (c *VTClient) LookupHash(ctx context.Context, sha256 string) (*VTFileReport, error) {
 	c.rateLimit()
 
-	url := fmt.Sprintf("%s/files/%s", vtBaseURL, sha256)
+	url := fmt.Sprintf("%s/files/%s", c.baseURL, sha256)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
@@ -151,15 +150,12 @@ func (c *VTClient) LookupHash(ctx context.Context, sha256 string) (*VTFileReport
 }
 
 // UploadFile uploads a file to VT for scanning. File must be ≤ 20MB.
+// filePath is a path returned by the Downloader (on disk or a memory URL)
+// and size its length in the torrent.
 // Returns the analysis ID for polling, or an error.
-func (c *VTClient) UploadFile(ctx context.Context, filePath string) (string, error) {
-	// Check file size
-	info, err := os.Stat(filePath)
-	if err != nil {
-		return "", fmt.Errorf("stat file: %w", err)
-	}
-	if info.Size() > vtMaxUploadB {
-		return "", fmt.Errorf("file too large for upload: %d bytes (max %dMB)", info.Size(), vtMaxUploadMB)
+func (c *VTClient) UploadFile(ctx context.Context, filePath string, size int64) (string, error) {
+	if size > vtMaxUploadB {
+		return "", fmt.Errorf("file too large for upload: %d bytes (max %dMB)", size, vtMaxUploadMB)
 	}
 
 	c.rateLimit()
@@ -178,7 +174,7 @@ func (c *VTClient) UploadFile(ctx context.Context, filePath string) (string, err
 			return
 		}
 
-		f, err := os.Open(filePath)
+		f, err := openDownloadedFile(filePath)
 		if err != nil {
 			pw.CloseWithError(err)
 			return
@@ -191,7 +187,7 @@ func (c *VTClient) UploadFile(ctx context.Context, filePath string) (string, err
 		}
 	}()
 
-	url := fmt.Sprintf("%s/files", vtBaseURL)
+	url := fmt.Sprintf("%s/files", c.baseURL)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, pr)
 	if err != nil {
 		return "", fmt.Errorf("create upload request: %w", err)
@@ -200,7 +196,7 @@ func (c *VTClient) UploadFile(ctx context.Context, filePath string) (string, err
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	// Use a longer timeout for uploads
-	uploadClient := &http.Client{Timeout: 2 * time.Minute}
+	uploadClient := newOutboundClient(2 * time.Minute)
 	resp, err := uploadClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("VT upload: %w", err)
@@ -243,7 +239,7 @@ func (c *VTClient) PollAnalysis(ctx context.Context, analysisID string) (*VTFile
 		case <-ticker.C:
 			c.rateLimit()
 
-			url := fmt.Sprintf("%s/analyses/%s", vtBaseURL, analysisID)
+			url := fmt.Sprintf("%s/analyses/%s", c.baseURL, analysisID)
 			req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 			if err != nil {
 				return nil, fmt.Errorf("create poll request: %w", err)
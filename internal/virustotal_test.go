@@ -1,13 +1,18 @@
 package internal
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
 )
 
 func TestVTClient_LookupHash_Found(t *testing.T) {
@@ -139,12 +144,68 @@ func TestVTClient_UploadFile_TooLarge(t *testing.T) {
 	}
 	f.Close()
 
-	_, err = client.UploadFile(context.Background(), path)
+	_, err = client.UploadFile(context.Background(), path, 21*1024*1024)
 	if err == nil {
 		t.Error("expected error for file > 20MB")
 	}
 }
 
+func TestVTClient_UploadFile_MemoryURL(t *testing.T) {
+	s := newMemoryStorage(0)
+	info := memTestInfo()
+	var hash metainfo.Hash
+	hash[0] = 0xCD
+	impl, _ := s.OpenTorrent(context.Background(), info, hash)
+	data := []byte(strings.Repeat("ABCDEFGHIJKLMNOP", 3))[:40]
+	for i := 0; i < 3; i++ {
+		p := impl.Piece(info.Piece(i))
+		p.WriteAt(data[i*16:min(i*16+16, 40)], 0)
+		p.MarkComplete()
+	}
+	srv, err := startMemoryServer(s)
+	if err != nil {
+		t.Skipf("loopback listener unavailable: %v", err)
+	}
+	defer srv.Close()
+
+	var uploaded []byte
+	var name string
+	vt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/files" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		f, hdr, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("read form file: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		uploaded, _ = io.ReadAll(f)
+		name = hdr.Filename
+		w.Write([]byte(`{"data":{"id":"analysis-1","type":"analysis"}}`))
+	}))
+	defer vt.Close()
+
+	client := NewVTClient("test-key")
+	client.baseURL = vt.URL
+
+	u := srv.base + "/" + hash.HexString() + "/8-30/setup.exe"
+	id, err := client.UploadFile(context.Background(), u, 30)
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if id != "analysis-1" {
+		t.Errorf("analysis ID = %q, want analysis-1", id)
+	}
+	if name != "setup.exe" {
+		t.Errorf("uploaded name = %q, want setup.exe", name)
+	}
+	if !bytes.Equal(uploaded, data[8:38]) {
+		t.Errorf("uploaded %q, want %q", uploaded, data[8:38])
+	}
+}
+
 func TestDedup(t *testing.T) {
 	input := []string{"a", "b", "a", "c", "b", "d"}
 	result := dedup(input)
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"path/filepath"
 )
 
@@ -105,7 +104,7 @@ func EnrichWithVirusTotal(ctx context.Context, cfg VTScanConfig, files *TorrentF
 		// Upload to VT
 		log.Printf("  [%s] VT: uploading %s to VirusTotal...", TruncHash(infoHash), filepath.Base(f.Path))
 
-		analysisID, err := client.UploadFile(ctx, fullPath)
+		analysisID, err := client.UploadFile(ctx, fullPath, f.Size)
 		if err != nil {
 			log.Printf("  [%s] VT: upload failed: %v", TruncHash(infoHash), err)
 			f.VT = &VTFileReport{Status: "vt_error"}
@@ -168,7 +167,7 @@ func updateThreatLevelWithVT(files *TorrentFiles) {
 
 // fileSHA256 computes the SHA256 hash of a file.
 func fileSHA256(path string) (string, error) {
-	f, err := os.Open(path)
+	f, err := openDownloadedFile(path)
 	if err != nil {
 		return "", err
 	}
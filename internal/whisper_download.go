@@ -35,8 +35,8 @@ const (
 
 // HTTP clients with timeouts (never use http.DefaultClient for downloads)
 var (
-	apiClient = &http.Client{Timeout: 30 * time.Second}
-	dlClient  = &http.Client{Timeout: 10 * time.Minute}
+	apiClient = newOutboundClient(30 * time.Second)
+	dlClient  = newOutboundClient(10 * time.Minute)
 )
 
 // errBuildFromSource signals that no prebuilt binary exists and the platform
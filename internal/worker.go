@@ -16,17 +16,38 @@ import (
 
 // WorkerInput is sent via stdin to the worker subprocess.
 type WorkerInput struct {
-	InfoHash       string `json:"info_hash"`
-	Index          int    `json:"index"` // for logging "[idx/total]"
-	Total          int    `json:"total"` // for logging
-	FFprobePath    string `json:"ffprobe_path"`
-	TempDir        string `json:"temp_dir"`
-	StallTimeout   int    `json:"stall_timeout_s"`
-	MaxTimeout     int    `json:"max_timeout_s"`
-	TimeoutSeconds int    `json:"timeout_seconds"` // absolute timeout for this worker
-	MinBytesMKV    int    `json:"min_bytes_mkv"`
-	MinBytesMP4    int    `json:"min_bytes_mp4"`
-	MaxRetries     int    `json:"max_retries"`
+	InfoHash       string   `json:"info_hash"`
+	Index          int      `json:"index"` // for logging "[idx/total]"
+	Total          int      `json:"total"` // for logging
+	FFprobePath    string   `json:"ffprobe_path"`
+	TempDir        string   `json:"temp_dir"`
+	StallTimeout   int      `json:"stall_timeout_s"`
+	MaxTimeout     int      `json:"max_timeout_s"`
+	TimeoutSeconds int      `json:"timeout_seconds"` // absolute timeout for this worker
+	MinBytesMKV    int      `json:"min_bytes_mkv"`
+	MinBytesMP4    int      `json:"min_bytes_mp4"`
+	MaxRetries     int      `json:"max_retries"`
+	MemoryCapMB    int      `json:"memory_cap_mb,omitempty"`
+	DownloadRate   int      `json:"download_rate,omitempty"` // bytes/s, this worker's share
+	UploadRate     int      `json:"upload_rate,omitempty"`   // bytes/s, this worker's share
+	MaxConns       int      `json:"max_conns,omitempty"`
+	MaxHalfOpen    int      `json:"max_half_open,omitempty"`
+	DiskBudget     bool     `json:"disk_budget,omitempty"` // reserve disk space from the parent over fds 3 and 4
+	Proxy          string   `json:"proxy,omitempty"`
+	BindAddress    string   `json:"bind_address,omitempty"`
+	PortMin        int      `json:"port_min,omitempty"`
+	PortMax        int      `json:"port_max,omitempty"`
+	DisableIPv6    bool     `json:"disable_ipv6,omitempty"`
+	DisableUTP     bool     `json:"disable_utp,omitempty"`
+	DisableTCP     bool     `json:"disable_tcp,omitempty"`
+	Encryption     string   `json:"encryption,omitempty"`
+	Trackers       []string `json:"trackers,omitempty"`
+	DHTNodes       []string `json:"dht_nodes,omitempty"`
+	DHTStateFile   string   `json:"dht_state_file,omitempty"`
+	Blocklist      string   `json:"blocklist,omitempty"`
+	Thumbnails     int      `json:"thumbnails,omitempty"`
+	ThumbFormat    string   `json:"thumbnail_format,omitempty"`
+	ThumbDir       string   `json:"thumbnail_dir,omitempty"`
 }
 
 // WorkerOutput is written to the original stdout file descriptor.
@@ -56,6 +77,11 @@ func RunWorker(input WorkerInput) WorkerOutput {
 		os.Remove(filepath.Join(subdir, f))
 	}
 
+	// Route the worker's HTTP clients (VirusTotal) through the proxy too
+	if err := SetProxy(input.Proxy); err != nil {
+		return WorkerOutput{Result: errorResult(input.InfoHash, err, start)}
+	}
+
 	// Crear Downloader para este worker
 	dl, err := NewDownloader(DownloadConfig{
 		TempDir:      subdir,
@@ -63,6 +89,24 @@ func RunWorker(input WorkerInput) WorkerOutput {
 		MaxTimeout:   time.Duration(input.MaxTimeout) * time.Second,
 		MinBytesMKV:  input.MinBytesMKV,
 		MinBytesMP4:  input.MinBytesMP4,
+		MemoryCap:    int64(input.MemoryCapMB) * 1024 * 1024,
+		DownloadRate: input.DownloadRate,
+		UploadRate:   input.UploadRate,
+		MaxConns:     input.MaxConns,
+		MaxHalfOpen:  input.MaxHalfOpen,
+		DiskReserve:  workerDiskReserve(input.DiskBudget),
+		Proxy:        input.Proxy,
+		BindAddress:  input.BindAddress,
+		PortMin:      input.PortMin,
+		PortMax:      input.PortMax,
+		DisableIPv6:  input.DisableIPv6,
+		DisableUTP:   input.DisableUTP,
+		DisableTCP:   input.DisableTCP,
+		Encryption:   input.Encryption,
+		Trackers:     input.Trackers,
+		DHTNodes:     input.DHTNodes,
+		DHTStateFile: input.DHTStateFile,
+		Blocklist:    input.Blocklist,
 	})
 	if err != nil {
 		return WorkerOutput{
@@ -80,6 +124,10 @@ func RunWorker(input WorkerInput) WorkerOutput {
 		MinBytesMKV:       input.MinBytesMKV,
 		MinBytesMP4:       input.MinBytesMP4,
 		MaxFFprobeRetries: input.MaxRetries,
+		MemoryCapMB:       input.MemoryCapMB,
+		Thumbnails:        input.Thumbnails,
+		ThumbnailFormat:   input.ThumbFormat,
+		ThumbnailDir:      input.ThumbDir,
 	}
 
 	// Create context with timeout to respect parent cancellation
@@ -119,7 +167,11 @@ func workerTag(index, total int) string {
 // processOneIsolated executes a torrent scan in an isolated subprocess.
 // It spawns the subprocess, communicates via stdin/stdout, and handles crashes.
 // logWriter receives the worker's stderr (via prefixWriter); nil defaults to os.Stderr.
-func processOneIsolated(ctx context.Context, exePath string, input WorkerInput, logWriter io.Writer) (WorkerOutput, error) {
+// With a budget, the worker's disk reservations are made from it under a key
+// unique to this scan (see serveDiskBudget) and released once the worker,
+// and with it the torrent, is gone.
+func processOneIsolated(ctx context.Context, exePath string, input WorkerInput, logWriter io.Writer, budget *diskBudget) (WorkerOutput, error) {
+	input.DiskBudget = budget != nil
 	// Serializar input
 	inputJSON, err := json.Marshal(input)
 	if err != nil {
@@ -147,6 +199,18 @@ func processOneIsolated(ctx context.Context, exePath string, input WorkerInput,
 		w:      stderrDest,
 	}
 
+	if budget != nil {
+		key := budgetKey(input)
+		stop, err := serveDiskBudget(ctx, cmd, budget, key)
+		if err != nil {
+			return WorkerOutput{}, fmt.Errorf("disk budget pipes: %w", err)
+		}
+		defer func() {
+			stop()
+			budget.release(key)
+		}()
+	}
+
 	// Iniciar proceso
 	if err := cmd.Start(); err != nil {
 		// Si falla el lanzamiento, intentar fallback in-process
@@ -194,6 +258,12 @@ func processOneIsolated(ctx context.Context, exePath string, input WorkerInput,
 	return workerErrorResult(input.InfoHash, wErr.Error()), nil
 }
 
+// budgetKey names the disk reservation of one isolated scan. The same hash
+// may be scanned twice at once, so the worker index is part of it.
+func budgetKey(input WorkerInput) string {
+	return fmt.Sprintf("%s#%d", input.InfoHash, input.Index)
+}
+
 // processOneInProcess is the fallback that processes a torrent in-process
 // with the shared Downloader (original behavior).
 func processOneInProcess(ctx context.Context, dl *Downloader, cfg Config, hash string, idx, total int) (ScanResult, int64, int64) {